@@ -0,0 +1,165 @@
+// Package auth decides what a signed-in user may do. Each user has one role, which
+// grants a fixed set of permissions; a user may also be limited to some channels, in
+// which case channel permissions only apply to those channels.
+package auth
+
+import "live-broadcast-backend/models"
+
+// Permission is an action guarded by the admin API
+type Permission string
+
+const (
+	ViewContent    Permission = "content.view"    // channels, playlists, the library and jobs
+	IngestMedia    Permission = "media.ingest"    // imports and uploads
+	EditMedia      Permission = "media.edit"      // subtitles of existing videos
+	DeleteMedia    Permission = "media.delete"    // videos and library assets
+	EditSchedule   Permission = "schedule.edit"   // playlist order and library assets on a channel
+	ManageChannels Permission = "channels.manage" // channel details and transcode profiles
+	ManageJobs     Permission = "jobs.manage"     // retrying and cancelling ingest jobs
+	ManageStorage  Permission = "storage.manage"  // storage garbage collection
+	ManageUsers    Permission = "users.manage"    // accounts, roles and passwords
+	ManageAPIKeys  Permission = "apikeys.manage"  // one's own API keys
+)
+
+// globalPermissions concern the whole installation, so a user limited to some
+// channels never holds them
+var globalPermissions = map[Permission]bool{
+	ManageStorage: true,
+	ManageUsers:   true,
+}
+
+// Roles a user can have
+const (
+	RoleOwner      = "owner"
+	RoleProgrammer = "programmer"
+	RoleUploader   = "uploader"
+)
+
+// rolePermissions is what each role may do
+var rolePermissions = map[string][]Permission{
+	RoleOwner: {
+		ViewContent, IngestMedia, EditMedia, DeleteMedia, EditSchedule, ManageChannels,
+		ManageJobs, ManageStorage, ManageUsers, ManageAPIKeys,
+	},
+	RoleProgrammer: {
+		ViewContent, IngestMedia, EditMedia, DeleteMedia, EditSchedule, ManageChannels,
+		ManageJobs, ManageAPIKeys,
+	},
+	RoleUploader: {
+		ViewContent, IngestMedia, EditMedia, ManageJobs, ManageAPIKeys,
+	},
+}
+
+// ValidRole reports whether role is one of the defined roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// ValidPermission reports whether perm is a defined permission; owners hold them all
+func ValidPermission(perm Permission) bool {
+	return containsPermission(rolePermissions[RoleOwner], perm)
+}
+
+// Principal is who a request acts as and what it may do
+type Principal struct {
+	UserID   string
+	Username string
+	Role     string
+	// Channels limits channel permissions to these channels; nil means every channel
+	Channels []int
+	// Scopes limits the role's permissions to these; nil means all of them
+	Scopes []Permission
+	// APIKeyID is set when the request authenticated with an API key
+	APIKeyID string
+	// MustChangePassword holds back every permission until the user picks a new password
+	MustChangePassword bool
+}
+
+// ForUser returns the principal for a signed-in user
+func ForUser(user *models.User) *Principal {
+	principal := &Principal{
+		UserID:             user.ID,
+		Username:           user.Username,
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+	}
+	if len(user.Channels) > 0 {
+		principal.Channels = user.Channels
+	}
+	return principal
+}
+
+// ForAPIKey returns the principal for a request made with a user's API key: the user,
+// narrowed to the key's permissions and channels
+func ForAPIKey(user *models.User, key *models.APIKey) *Principal {
+	principal := ForUser(user)
+	principal.APIKeyID = key.ID
+	principal.Scopes = []Permission{}
+	for _, perm := range key.Permissions {
+		principal.Scopes = append(principal.Scopes, Permission(perm))
+	}
+	if len(key.Channels) > 0 {
+		channels := []int{}
+		for _, channel := range key.Channels {
+			if principal.AllChannels() || containsChannel(principal.Channels, channel) {
+				channels = append(channels, channel)
+			}
+		}
+		principal.Channels = channels
+	}
+	return principal
+}
+
+// Permissions lists what the principal may do on at least one channel
+func (p *Principal) Permissions() []Permission {
+	permissions := []Permission{}
+	for _, perm := range rolePermissions[p.Role] {
+		if p.Can(perm) {
+			permissions = append(permissions, perm)
+		}
+	}
+	return permissions
+}
+
+// AllChannels reports whether the principal is not limited to some channels
+func (p *Principal) AllChannels() bool {
+	return p.Channels == nil
+}
+
+// Can reports whether the principal holds a permission on at least one channel
+func (p *Principal) Can(perm Permission) bool {
+	if !p.AllChannels() && (globalPermissions[perm] || len(p.Channels) == 0) {
+		return false
+	}
+	if p.Scopes != nil && !containsPermission(p.Scopes, perm) {
+		return false
+	}
+	return containsPermission(rolePermissions[p.Role], perm)
+}
+
+// CanOnChannel reports whether the principal holds a permission on a channel
+func (p *Principal) CanOnChannel(perm Permission, channel int) bool {
+	if !p.Can(perm) {
+		return false
+	}
+	return p.AllChannels() || containsChannel(p.Channels, channel)
+}
+
+func containsPermission(permissions []Permission, perm Permission) bool {
+	for _, granted := range permissions {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func containsChannel(channels []int, channel int) bool {
+	for _, allowed := range channels {
+		if allowed == channel {
+			return true
+		}
+	}
+	return false
+}
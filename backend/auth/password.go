@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxPasswordBytes is where bcrypt stops reading a password
+const maxPasswordBytes = 72
+
+// commonPasswords are refused whatever their length
+var commonPasswords = map[string]bool{
+	"tvadmin2025":   true,
+	"password":      true,
+	"password123":   true,
+	"passw0rd":      true,
+	"123456789012":  true,
+	"qwertyuiop":    true,
+	"letmein":       true,
+	"changeme":      true,
+	"administrator": true,
+}
+
+// PasswordPolicy is what a new password must satisfy
+type PasswordPolicy struct {
+	MinLength int
+}
+
+// Check returns why a new password for username is not acceptable, or nil
+func (p PasswordPolicy) Check(password, username string) error {
+	if password == "" {
+		return fmt.Errorf("password is required")
+	}
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("password is too common")
+	}
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return fmt.Errorf("password must not contain the username")
+	}
+	if strings.Count(password, password[:1]) == len(password) {
+		return fmt.Errorf("password must not repeat a single character")
+	}
+	return nil
+}
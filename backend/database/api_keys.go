@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"live-broadcast-backend/models"
+)
+
+// apiKeyColumns are read by scanAPIKey, in order
+const apiKeyColumns = `id, prefix, name, user_id, permissions, channels, created_at, expires_at, last_used_at, revoked_at`
+
+// CreateAPIKey stores a new API key under the hash of its secret
+func (db *DB) CreateAPIKey(key *models.APIKey, secret string) error {
+	permissions, err := json.Marshal(key.Permissions)
+	if err != nil {
+		return fmt.Errorf("failed to encode API key permissions: %v", err)
+	}
+	var channels sql.NullString
+	if len(key.Channels) > 0 {
+		data, err := json.Marshal(key.Channels)
+		if err != nil {
+			return fmt.Errorf("failed to encode API key channels: %v", err)
+		}
+		channels = sql.NullString{String: string(data), Valid: true}
+	}
+	_, err = db.Exec(`
+		INSERT INTO api_keys (id, key_hash, prefix, name, user_id, permissions, channels, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, key.ID, hashToken(secret), key.Prefix, key.Name, key.UserID, string(permissions), channels,
+		key.CreatedAt, key.ExpiresAt)
+	return err
+}
+
+// GetAPIKey returns the key with a secret, revoked and expired ones included, or nil
+// when there is none
+func (db *DB) GetAPIKey(secret string) (*models.APIKey, error) {
+	key, err := scanAPIKey(db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hashToken(secret)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// GetAPIKeyByID returns one API key
+func (db *DB) GetAPIKeyByID(id string) (*models.APIKey, error) {
+	return scanAPIKey(db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+}
+
+// ListAPIKeys returns a user's API keys or, when userID is empty, everyone's, newest first
+func (db *DB) ListAPIKeys(userID string) ([]*models.APIKey, error) {
+	rows, err := db.Query(`
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE $1 = '' OR user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// TouchAPIKey records when a key was last used
+func (db *DB) TouchAPIKey(id string, usedAt time.Time) error {
+	_, err := db.Exec(`UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, usedAt, id)
+	return err
+}
+
+// RevokeAPIKey stops a key from working; it stays listed with its revocation time
+func (db *DB) RevokeAPIKey(id string) error {
+	result, err := db.Exec(`UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeUserAPIKeys revokes every live key of a user and returns how many there were
+func (db *DB) RevokeUserAPIKeys(userID string) (int, error) {
+	result, err := db.Exec(`UPDATE api_keys SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`, time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// scanAPIKey reads one row of apiKeyColumns
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var permissions string
+	var channels sql.NullString
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.Prefix, &key.Name, &key.UserID, &permissions, &channels,
+		&key.CreatedAt, &key.ExpiresAt, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(permissions), &key.Permissions); err != nil {
+		return nil, fmt.Errorf("corrupt permissions for API key %s: %v", key.ID, err)
+	}
+	if channels.Valid {
+		if err := json.Unmarshal([]byte(channels.String), &key.Channels); err != nil {
+			return nil, fmt.Errorf("corrupt channels for API key %s: %v", key.ID, err)
+		}
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
@@ -1,7 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
@@ -9,20 +12,62 @@ import (
 	"strings"
 	"time"
 
+	"live-broadcast-backend/keys"
 	"live-broadcast-backend/models"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // DB represents the database connection
 type DB struct {
 	*sql.DB
+	dialect Dialect
 }
 
-// InitDB initializes the database connection
+// Dialect tells whether the database is Postgres or SQLite
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+// InitDB connects to the database, applies pending migrations and creates the
+// default admin user
 func InitDB(dbConnStr string) (*DB, error) {
+	dbObj, err := Open(dbConnStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bring the schema up to date and seed it under the migration lock, so replicas
+	// starting together do not both create the admin user
+	ctx := context.Background()
+	err = dbObj.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		if _, err := migrate(ctx, conn, dbObj.dialect); err != nil {
+			return fmt.Errorf("failed to migrate database: %v", err)
+		}
+
+		// Create the default admin user on a new install
+		if err := seedAdmin(ctx, conn); err != nil {
+			return err
+		}
+
+		// Completed videos the ingest could not add to the library are reported
+		return reportVideosOutsideLibrary(ctx, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// No longer create default videos
+	// The code to create default videos has been removed
+
+	return dbObj, nil
+}
+
+// Open connects to the database without touching its schema
+func Open(dbConnStr string) (*DB, error) {
 	// If no connection string is provided, try to get it from environment
 	if dbConnStr == "" {
 		dbConnStr = os.Getenv("DATABASE_URL")
@@ -34,14 +79,16 @@ func InitDB(dbConnStr string) (*DB, error) {
 		log.Println("Warning: Using default database connection string. Set DATABASE_URL for custom configuration.")
 	}
 
-	// Parse the connection URL to validate it
-	parsedURL, err := url.Parse(dbConnStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid database URL: %v", err)
+	dialect, driver, dsn := parseDSN(dbConnStr)
+	if dialect == Postgres {
+		// Parse the connection URL to validate it
+		if _, err := url.Parse(dbConnStr); err != nil {
+			return nil, fmt.Errorf("invalid database URL: %v", err)
+		}
 	}
 
 	// Open database connection
-	db, err := sql.Open("postgres", dbConnStr)
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
@@ -51,195 +98,63 @@ func InitDB(dbConnStr string) (*DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	// Create tables if they don't exist
-	if err := createTables(db); err != nil {
-		return nil, fmt.Errorf("failed to create tables: %v", err)
-	}
-
-	// Create default admin user if it doesn't exist
-	if err := createDefaultAdmin(db); err != nil {
-		return nil, err
-	}
-
-	// Create database object
-	dbObj := &DB{db}
-
-	// No longer create default videos
-	// The code to create default videos has been removed
-
-	log.Printf("Connected to database: %s", parsedURL.Host)
-	return dbObj, nil
+	log.Printf("Connected to database: %s", describeDSN(dialect, dsn))
+	return &DB{DB: db, dialect: dialect}, nil
 }
 
-// createTables creates the necessary tables if they don't exist
-func createTables(db *sql.DB) error {
-	// Create channels table first (since videos reference channels)
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS channels (
-			number INTEGER PRIMARY KEY,
-			name TEXT NOT NULL,
-			description TEXT,
-			theme TEXT,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-		)
-	`)
-	if err != nil {
-		return err
-	}
+// defaultAdminPassword is the seeded admin account's password until its first login
+const defaultAdminPassword = "tvadmin2025"
 
-	// Create videos table with foreign key to channels
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS videos (
-			id TEXT PRIMARY KEY,
-			youtube_url TEXT NOT NULL,
-			s3_key TEXT NOT NULL,
-			channel_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			description TEXT,
-			status TEXT NOT NULL,
-			error_msg TEXT,
-			uploaded_by TEXT NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			FOREIGN KEY (channel_id) REFERENCES channels(number)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-	
-	// Note: Thumbnails are now managed directly in S3 with pattern: thumbnails/thumbnail_{videoID}.jpg
-	
-	// Create video_order table to manage display order
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS video_order (
-			video_id TEXT PRIMARY KEY,
-			channel_id INTEGER NOT NULL,
-			display_order INTEGER NOT NULL,
-			FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
-			FOREIGN KEY (channel_id) REFERENCES channels(number),
-			UNIQUE (channel_id, display_order)
-		)
-	`)
-	if err != nil {
+// seedAdmin creates the admin account with the default password when there are no
+// users yet. It must choose a new password when it first signs in.
+func seedAdmin(ctx context.Context, conn *sql.Conn) error {
+	var count int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
 		return err
 	}
-
-	// Create users table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			username TEXT UNIQUE NOT NULL,
-			email TEXT UNIQUE NOT NULL,
-			password TEXT NOT NULL,
-			role TEXT NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-		)
-	`)
-	if err != nil {
-		return err
+	if count > 0 {
+		return flagDefaultAdmin(ctx, conn)
 	}
 
 	// Hash the default admin password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("tvadmin2025"), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(defaultAdminPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("failed to hash default admin password: %v", err)
 	}
 
-	// Create a default admin user if not exists
-	_, err = db.Exec(`
-		INSERT INTO users (id, username, email, password, role, created_at, updated_at)
-		SELECT $1, $2, $3, $4, $5, $6, $7
-		WHERE NOT EXISTS (
-			SELECT 1 FROM users WHERE username = $2
-		)
-	`, uuid.New().String(), "admin", "admin@tvstream.example", string(hashedPassword), "admin", time.Now(), time.Now())
+	now := time.Now()
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO users (id, username, email, password, role, must_change_password, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, uuid.New().String(), "admin", "admin@tvstream.example", string(hashedPassword), "owner", true, now, now)
 	if err != nil {
 		return err
 	}
-
-	// Add duration column to videos table if it doesn't exist
-	// This is an ALTER TABLE migration that safely adds the column if missing
-	_, err = db.Exec(`
-		DO $$
-		BEGIN
-			IF NOT EXISTS (
-				SELECT 1 
-				FROM information_schema.columns 
-				WHERE table_name='videos' AND column_name='duration'
-			) THEN
-				ALTER TABLE videos ADD COLUMN duration FLOAT DEFAULT 0;
-			END IF;
-		END
-		$$;
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to add duration column to videos table: %v", err)
-	}
-
-	// Add display_order column to videos table if it doesn't exist
-	_, err = db.Exec(`
-		DO $$
-		BEGIN
-			IF NOT EXISTS (
-				SELECT 1 
-				FROM information_schema.columns 
-				WHERE table_name='videos' AND column_name='display_order'
-			) THEN
-				ALTER TABLE videos ADD COLUMN display_order INTEGER DEFAULT NULL;
-			END IF;
-		END
-		$$;
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to add display_order column to videos table: %v", err)
-	}
-
-	// Add thumbnail_url column to videos table if it doesn't exist
-	_, err = db.Exec(`
-		DO $$
-		BEGIN
-			IF NOT EXISTS (
-				SELECT 1 
-				FROM information_schema.columns 
-				WHERE table_name='videos' AND column_name='thumbnail_url'
-			) THEN
-				ALTER TABLE videos ADD COLUMN thumbnail_url TEXT DEFAULT NULL;
-			END IF;
-		END
-		$$;
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to add thumbnail_url column to videos table: %v", err)
-	}
-
+	log.Println("Created default admin user; its password must be changed at first login")
 	return nil
 }
 
-// createDefaultAdmin creates a default admin user if one doesn't exist
-func createDefaultAdmin(db *sql.DB) error {
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&count)
+// flagDefaultAdmin makes the admin account choose a new password while it still has
+// the default one, e.g. on installs seeded before passwords had to be changed
+func flagDefaultAdmin(ctx context.Context, conn *sql.Conn) error {
+	var id, hash string
+	var mustChange bool
+	err := conn.QueryRowContext(ctx, `SELECT id, password, must_change_password FROM users WHERE username = $1`, "admin").
+		Scan(&id, &hash, &mustChange)
+	if err == sql.ErrNoRows || (err == nil && mustChange) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-
-	if count == 0 {
-		// Create a default admin user
-		// In production, use bcrypt for password hashing
-		now := time.Now()
-		_, err = db.Exec(`
-			INSERT INTO users (id, username, email, password, role, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		`, uuid.New().String(), "admin", "admin@tvstream.example", "tvadmin2025", "admin", now, now)
-		if err != nil {
-			return err
-		}
-		log.Println("Created default admin user")
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(defaultAdminPassword)) != nil {
+		return nil
 	}
 
+	if _, err := conn.ExecContext(ctx, `UPDATE users SET must_change_password = $1 WHERE id = $2`, true, id); err != nil {
+		return err
+	}
+	log.Println("The admin user still has the default password; it must be changed at next login")
 	return nil
 }
 
@@ -261,8 +176,8 @@ func (db *DB) SaveVideo(video *models.AdminVideo) error {
 	// Use an upsert operation to handle updates to existing videos
 	_, err := db.Exec(`
 		INSERT INTO videos (id, youtube_url, s3_key, channel_id, title, description, status, error_msg, 
-		                    uploaded_by, duration, created_at, updated_at, display_order, thumbnail_url)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		                    uploaded_by, duration, created_at, updated_at, display_order, thumbnail_url, source_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''))
 		ON CONFLICT (id) DO UPDATE SET
 			youtube_url = $2,
 			s3_key = $3,
@@ -277,7 +192,7 @@ func (db *DB) SaveVideo(video *models.AdminVideo) error {
 			thumbnail_url = $14
 	`, video.ID, video.YoutubeURL, video.S3Key, video.ChannelID, video.Title, video.Description, 
 	   video.Status, video.ErrorMsg, video.UploadedBy, video.Duration, video.CreatedAt, 
-	   video.UpdatedAt, video.DisplayOrder, video.ThumbnailURL)
+	   video.UpdatedAt, video.DisplayOrder, video.ThumbnailURL, video.SourceID)
 	
 	if err != nil {
 		log.Printf("Error saving video: %v", err)
@@ -297,11 +212,335 @@ func (db *DB) UpdateVideoStatus(id string, status models.VideoStatus, errorMsg s
 	return err
 }
 
+// FindVideoBySource returns the ID of a live (not failed or cancelled) video on the channel
+// imported from sourceID, or "" when there is none
+func (db *DB) FindVideoBySource(channelID int, sourceID string) (string, error) {
+	var id string
+	err := db.QueryRow(`
+		SELECT id FROM videos
+		WHERE channel_id = $1 AND source_id = $2 AND status NOT IN ($3, $4)
+		LIMIT 1
+	`, channelID, sourceID, models.StatusFailed, models.StatusCancelled).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// FindCompletedVideoBySource returns the ID of a completed video on any channel imported
+// from sourceID, or "" when there is none
+func (db *DB) FindCompletedVideoBySource(sourceID string) (string, error) {
+	var id string
+	err := db.QueryRow(`
+		SELECT id FROM videos
+		WHERE source_id = $1 AND status = $2
+		ORDER BY created_at
+		LIMIT 1
+	`, sourceID, models.StatusCompleted).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// FindVideoByContentHash returns the ID of a completed video other than excludeID whose
+// source file had the given SHA-256, or "" when there is none
+func (db *DB) FindVideoByContentHash(contentHash, excludeID string) (string, error) {
+	var id string
+	err := db.QueryRow(`
+		SELECT id FROM videos
+		WHERE content_hash = $1 AND id <> $2 AND status = $3
+		ORDER BY created_at
+		LIMIT 1
+	`, contentHash, excludeID, models.StatusCompleted).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// ListPerceptualHashes returns the perceptual hashes of completed videos other than
+// excludeID whose duration lies within [minDuration, maxDuration], keyed by video ID
+func (db *DB) ListPerceptualHashes(minDuration, maxDuration float64, excludeID string) (map[string]string, error) {
+	rows, err := db.Query(`
+		SELECT id, perceptual_hash FROM videos
+		WHERE perceptual_hash IS NOT NULL AND id <> $1 AND status = $2
+		  AND duration BETWEEN $3 AND $4
+	`, excludeID, models.StatusCompleted, minDuration, maxDuration)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, err
+		}
+		hashes[id] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// UpdateVideoHashes records the content and perceptual hashes of a video's source
+func (db *DB) UpdateVideoHashes(id, contentHash, perceptualHash string) error {
+	_, err := db.Exec(`
+		UPDATE videos SET content_hash = NULLIF($1, ''), perceptual_hash = NULLIF($2, '')
+		WHERE id = $3
+	`, contentHash, perceptualHash, id)
+	return err
+}
+
+// UpdateVideoChecksum records the SHA-256 of the uploaded object of a video or a
+// library asset and all its playlist entries
+func (db *DB) UpdateVideoChecksum(id, checksum string) error {
+	var data interface{}
+	if checksum != "" {
+		data = checksum
+	}
+	return db.updateMedia(id, "checksum", data)
+}
+
+// updateMedia sets a column describing the media of a video, or of a library asset and
+// all its playlist entries, so they never disagree
+func (db *DB) updateMedia(id, column string, value interface{}) error {
+	if _, err := db.Exec(`UPDATE videos SET `+column+` = $1 WHERE id = $2 OR asset_id = $2`, value, id); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE media_assets SET `+column+` = $1 WHERE id = $2`, value, id)
+	return err
+}
+
+// GetChecksumByS3Key returns the recorded SHA-256 of an object, or "" when no video
+// with a checksum uses it
+func (db *DB) GetChecksumByS3Key(s3Key string) (string, error) {
+	var checksum string
+	err := db.QueryRow(`
+		SELECT checksum FROM videos
+		WHERE s3_key = $1 AND checksum IS NOT NULL
+		LIMIT 1
+	`, s3Key).Scan(&checksum)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return checksum, err
+}
+
+// CountVideosByS3Key returns how many videos other than excludeID use an S3 object
+func (db *DB) CountVideosByS3Key(s3Key, excludeID string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM videos WHERE s3_key = $1 AND id <> $2`, s3Key, excludeID).Scan(&count)
+	return count, err
+}
+
+// ReferencedMedia returns the S3 keys and the video and asset IDs the database refers
+// to. Storage outside these belongs to nothing and may be garbage collected.
+func (db *DB) ReferencedMedia() (map[string]bool, map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT id, s3_key FROM videos
+		UNION ALL
+		SELECT id, s3_key FROM media_assets
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	s3Keys := make(map[string]bool)
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id, s3Key string
+		if err := rows.Scan(&id, &s3Key); err != nil {
+			return nil, nil, err
+		}
+		ids[id] = true
+		if s3Key != "" {
+			s3Keys[s3Key] = true
+		}
+	}
+	return s3Keys, ids, rows.Err()
+}
+
+// LinkVideo completes video id as another reference to the media of video existingID:
+// the S3 object, previews, tracks and measurements are shared rather than copied. The
+// title and description are only taken over when id has none of its own.
+func (db *DB) LinkVideo(id, existingID string) error {
+	result, err := db.Exec(`
+		UPDATE videos AS v SET
+			s3_key = o.s3_key,
+			duration = o.duration,
+			title = CASE WHEN v.title IN ('', 'Processing...') THEN o.title ELSE v.title END,
+			description = COALESCE(NULLIF(v.description, ''), o.description),
+			thumbnail_url = o.thumbnail_url,
+			sprite_url = o.sprite_url,
+			sprite_vtt_url = o.sprite_vtt_url,
+			subtitles = o.subtitles,
+			audio_tracks = o.audio_tracks,
+			loudness = o.loudness,
+			transcode_profile = o.transcode_profile,
+			content_hash = COALESCE(v.content_hash, o.content_hash),
+			perceptual_hash = COALESCE(v.perceptual_hash, o.perceptual_hash),
+			checksum = o.checksum,
+			asset_id = o.asset_id,
+			status = $3,
+			error_msg = '',
+			updated_at = $4
+		FROM videos o
+		WHERE v.id = $1 AND o.id = $2
+	`, id, existingID, models.StatusCompleted, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("video %s or %s not found", id, existingID)
+	}
+	return nil
+}
+
+// UpdateVideoProfile records the transcode profile a video or a library asset and all
+// its playlist entries were encoded with
+func (db *DB) UpdateVideoProfile(id string, profile *models.TranscodeProfile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return db.updateMedia(id, "transcode_profile", string(data))
+}
+
+// UpdateVideoThumbnails records generated preview URLs. An empty thumbnail URL keeps the current one.
+// id may be a video or a library asset; an asset's previews are shared by all its playlist entries.
+func (db *DB) UpdateVideoThumbnails(id, thumbnailURL, spriteURL, spriteVTTURL string) error {
+	_, err := db.Exec(`
+		UPDATE videos
+		SET thumbnail_url = COALESCE(NULLIF($1, ''), thumbnail_url),
+		    sprite_url = NULLIF($2, ''),
+		    sprite_vtt_url = NULLIF($3, '')
+		WHERE id = $4 OR asset_id = $4
+	`, thumbnailURL, spriteURL, spriteVTTURL, id)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		UPDATE media_assets
+		SET thumbnail_url = COALESCE(NULLIF($1, ''), thumbnail_url),
+		    sprite_url = NULLIF($2, ''),
+		    sprite_vtt_url = NULLIF($3, '')
+		WHERE id = $4
+	`, thumbnailURL, spriteURL, spriteVTTURL, id)
+	return err
+}
+
+// UpdateVideoLoudness records the loudness measurement of a video or a library asset
+// and all its playlist entries
+func (db *DB) UpdateVideoLoudness(id string, loudness *models.LoudnessMeasurement) error {
+	data, err := json.Marshal(loudness)
+	if err != nil {
+		return err
+	}
+	return db.updateMedia(id, "loudness", string(data))
+}
+
+// UpdateVideoSubtitles replaces the subtitle tracks recorded for a video or a library
+// asset and all its playlist entries
+func (db *DB) UpdateVideoSubtitles(id string, tracks []models.SubtitleTrack) error {
+	var data interface{}
+	if len(tracks) > 0 {
+		encoded, err := json.Marshal(tracks)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	now := time.Now()
+	_, err := db.Exec(`UPDATE videos SET subtitles = $1, updated_at = $2 WHERE id = $3 OR asset_id = $3`, data, now, id)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE media_assets SET subtitles = $1, updated_at = $2 WHERE id = $3`, data, now, id)
+	return err
+}
+
+// UpdateVideoAudioTracks records the audio renditions a video or a library asset and
+// all its playlist entries were transcoded with
+func (db *DB) UpdateVideoAudioTracks(id string, tracks []models.AudioTrack) error {
+	data, err := json.Marshal(tracks)
+	if err != nil {
+		return err
+	}
+	return db.updateMedia(id, "audio_tracks", string(data))
+}
+
+// decodeAudioTracks parses stored audio renditions, returning nil when unset or unreadable
+func decodeAudioTracks(data sql.NullString) []models.AudioTrack {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	var tracks []models.AudioTrack
+	if err := json.Unmarshal([]byte(data.String), &tracks); err != nil {
+		log.Printf("Warning: Ignoring unreadable audio tracks: %v", err)
+		return nil
+	}
+	return tracks
+}
+
+// decodeSubtitles parses stored subtitle tracks, returning nil when unset or unreadable
+func decodeSubtitles(data sql.NullString) []models.SubtitleTrack {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	var tracks []models.SubtitleTrack
+	if err := json.Unmarshal([]byte(data.String), &tracks); err != nil {
+		log.Printf("Warning: Ignoring unreadable subtitle tracks: %v", err)
+		return nil
+	}
+	return tracks
+}
+
+// decodeLoudness parses a stored loudness measurement, returning nil when unset or unreadable
+func decodeLoudness(data sql.NullString) *models.LoudnessMeasurement {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	loudness := &models.LoudnessMeasurement{}
+	if err := json.Unmarshal([]byte(data.String), loudness); err != nil {
+		log.Printf("Warning: Ignoring unreadable loudness measurement: %v", err)
+		return nil
+	}
+	return loudness
+}
+
+// decodeProfile parses a stored transcode profile, returning nil when unset or unreadable.
+// Fields missing from older profiles take their default values.
+func decodeProfile(data sql.NullString) *models.TranscodeProfile {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	profile := models.DefaultTranscodeProfile()
+	if err := json.Unmarshal([]byte(data.String), profile); err != nil {
+		log.Printf("Warning: Ignoring unreadable transcode profile: %v", err)
+		return nil
+	}
+	return profile
+}
+
+// UpdateVideoProgress records the latest ingest progress on a video
+func (db *DB) UpdateVideoProgress(id string, stage string, percent float64) error {
+	_, err := db.Exec(`
+		UPDATE videos
+		SET progress_stage = $1, progress_percent = $2, progress_updated_at = $3
+		WHERE id = $4
+	`, stage, percent, time.Now(), id)
+
+	return err
+}
+
 // GetVideosByChannel retrieves all videos for a specific channel
 func (db *DB) GetVideosByChannel(channelID int) ([]models.AdminVideo, error) {
 	rows, err := db.Query(`
 		SELECT id, youtube_url, s3_key, channel_id, title, description, status, error_msg, uploaded_by, created_at, updated_at, duration, 
-		       display_order, thumbnail_url
+		       display_order, thumbnail_url, progress_stage, progress_percent, source_id, transcode_profile, loudness,
+		       sprite_url, sprite_vtt_url, subtitles, audio_tracks, asset_id, checksum
 		FROM videos 
 		WHERE channel_id = $1
 		ORDER BY COALESCE(display_order, 9999), created_at DESC
@@ -318,16 +557,34 @@ func (db *DB) GetVideosByChannel(channelID int) ([]models.AdminVideo, error) {
 		var displayOrder sql.NullInt64
 		var duration sql.NullFloat64
 		var thumbnailURL sql.NullString
+		var progressStage sql.NullString
+		var progressPercent sql.NullFloat64
+		var sourceID sql.NullString
+		var profile sql.NullString
+		var loudness sql.NullString
+		var spriteURL, spriteVTTURL sql.NullString
+		var subtitles, audioTracks, assetID, checksum sql.NullString
 		err := rows.Scan(
 			&video.ID, &video.YoutubeURL, &video.S3Key, &video.ChannelID,
 			&video.Title, &video.Description, &status, &video.ErrorMsg,
 			&video.UploadedBy, &video.CreatedAt, &video.UpdatedAt, &duration,
-			&displayOrder, &thumbnailURL,
+			&displayOrder, &thumbnailURL, &progressStage, &progressPercent, &sourceID, &profile, &loudness, &spriteURL, &spriteVTTURL, &subtitles, &audioTracks, &assetID, &checksum,
 		)
 		if err != nil {
 			return nil, err
 		}
 		video.Status = models.VideoStatus(status)
+		video.ProgressStage = progressStage.String
+		video.ProgressPercent = progressPercent.Float64
+		video.SourceID = sourceID.String
+		video.Profile = decodeProfile(profile)
+		video.Loudness = decodeLoudness(loudness)
+		video.SpriteURL = spriteURL.String
+		video.SpriteVTTURL = spriteVTTURL.String
+		video.Subtitles = decodeSubtitles(subtitles)
+		video.AudioTracks = decodeAudioTracks(audioTracks)
+		video.AssetID = assetID.String
+		video.Checksum = checksum.String
 		
 		// Set the display order if available
 		if displayOrder.Valid {
@@ -339,7 +596,7 @@ func (db *DB) GetVideosByChannel(channelID int) ([]models.AdminVideo, error) {
 			video.ThumbnailURL = thumbnailURL.String
 		} else {
 			// Fallback to ID-based thumbnail
-			video.ThumbnailURL = fmt.Sprintf("/thumbnails/thumbnail_%s.jpg", video.ID)
+			video.ThumbnailURL = keys.PreviewURL(keys.Thumbnail(video.ID))
 		}
 		
 		// Set the duration if available
@@ -367,22 +624,41 @@ func (db *DB) GetVideoByID(id string) (*models.AdminVideo, error) {
 	var duration sql.NullFloat64
 	var displayOrder sql.NullInt64
 	var thumbnailURL sql.NullString
+	var progressStage sql.NullString
+	var progressPercent sql.NullFloat64
+	var sourceID sql.NullString
+	var profile sql.NullString
+	var loudness sql.NullString
+	var spriteURL, spriteVTTURL sql.NullString
+	var subtitles, audioTracks, assetID, checksum sql.NullString
 	err := db.QueryRow(`
 		SELECT id, youtube_url, s3_key, channel_id, title, description, status, error_msg, uploaded_by, created_at, updated_at, duration,
-		       display_order, thumbnail_url
+		       display_order, thumbnail_url, progress_stage, progress_percent, source_id, transcode_profile, loudness,
+		       sprite_url, sprite_vtt_url, subtitles, audio_tracks, asset_id, checksum
 		FROM videos
 		WHERE id = $1
 	`, id).Scan(
 		&video.ID, &video.YoutubeURL, &video.S3Key, &video.ChannelID,
 		&video.Title, &video.Description, &status, &video.ErrorMsg,
 		&video.UploadedBy, &video.CreatedAt, &video.UpdatedAt, &duration,
-		&displayOrder, &thumbnailURL,
+		&displayOrder, &thumbnailURL, &progressStage, &progressPercent, &sourceID, &profile, &loudness, &spriteURL, &spriteVTTURL, &subtitles, &audioTracks, &assetID, &checksum,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	video.Status = models.VideoStatus(status)
+	video.ProgressStage = progressStage.String
+	video.ProgressPercent = progressPercent.Float64
+	video.SourceID = sourceID.String
+	video.Profile = decodeProfile(profile)
+	video.Loudness = decodeLoudness(loudness)
+	video.SpriteURL = spriteURL.String
+	video.SpriteVTTURL = spriteVTTURL.String
+	video.Subtitles = decodeSubtitles(subtitles)
+	video.AudioTracks = decodeAudioTracks(audioTracks)
+	video.AssetID = assetID.String
+	video.Checksum = checksum.String
 	
 	// Set the duration if available
 	if duration.Valid {
@@ -399,7 +675,7 @@ func (db *DB) GetVideoByID(id string) (*models.AdminVideo, error) {
 		video.ThumbnailURL = thumbnailURL.String
 	} else {
 		// Fallback to ID-based thumbnail
-		video.ThumbnailURL = fmt.Sprintf("/thumbnails/thumbnail_%s.jpg", video.ID)
+		video.ThumbnailURL = keys.PreviewURL(keys.Thumbnail(video.ID))
 	}
 	
 	// Set the video URL
@@ -413,45 +689,13 @@ func (db *DB) ValidateUser(username, password string) (*models.User, error) {
 	var user models.User
 	var storedPassword string
 
-	// Special case for the default admin credentials
-	if username == "admin" && password == "tvadmin2025" {
-		// Retrieve the admin user directly
-		err := db.QueryRow(`
-			SELECT id, username, email, role, password, created_at, updated_at
-			FROM users
-			WHERE username = 'admin'
-		`).Scan(
-			&user.ID, &user.Username, &user.Email, &user.Role, &storedPassword,
-			&user.CreatedAt, &user.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("admin user not found")
-		}
-
-		// Upgrade to bcrypt hash if needed
-		if !strings.HasPrefix(storedPassword, "$2a$") {
-			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-			if err == nil {
-				// Update the password in the database
-				_, err = db.Exec("UPDATE users SET password = $1 WHERE id = $2", string(hashedPassword), user.ID)
-				if err != nil {
-					log.Printf("Error updating admin password hash: %v", err)
-				}
-			}
-		}
-
-		// Return the admin user
-		return &user, nil
-	}
-
-	// Standard flow for other users
 	err := db.QueryRow(`
-		SELECT id, username, email, role, password, created_at, updated_at
+		SELECT id, username, email, role, password, disabled, must_change_password, created_at, updated_at
 		FROM users
 		WHERE username = $1
 	`, username).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Role, &storedPassword,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.Disabled, &user.MustChangePassword, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
@@ -488,48 +732,6 @@ func (db *DB) ValidateUser(username, password string) (*models.User, error) {
 	return &user, nil
 }
 
-// IsUserAdmin checks if a user is an admin
-func (db *DB) IsUserAdmin(userID string) (bool, error) {
-	log.Printf("[IsUserAdmin] Checking admin status for userID: %s", userID)
-	// First try the standard method
-	var role string
-	err := db.QueryRow(`
-		SELECT role
-		FROM users
-		WHERE id = $1
-	`, userID).Scan(&role)
-	log.Printf("[IsUserAdmin] Query result: role=%s, err=%v", role, err)
-
-	// If successful and role is admin, return true
-	if err == nil && role == "admin" {
-		log.Println("[IsUserAdmin] User is admin (role matched in users table)")
-		return true, nil
-	}
-
-	// If we have an error, try to look up the user by username instead
-	// This is a fallback for admin sessions that might have issue with ID
-	if err != nil {
-		log.Printf("[IsUserAdmin] Error on initial user lookup: %v", err)
-		// Try to get any user with admin role
-		var count int
-		err = db.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&count)
-		log.Printf("[IsUserAdmin] Admin count in DB: %d, err=%v", count, err)
-		if err != nil {
-			return false, err
-		}
-
-		// If we have at least one admin and the userID looks like a session token for admin
-		// This is a workaround for cases where session management might be having issues
-		if count > 0 && strings.Contains(userID, "session_") {
-			log.Println("[IsUserAdmin] Fallback: session token pattern detected, admin exists, granting admin access.")
-			return true, nil
-		}
-	}
-
-	log.Println("[IsUserAdmin] User is not admin or lookup failed.")
-	return false, nil
-}
-
 // CreateDefaultChannels creates default channels if none exist
 func (db *DB) CreateDefaultChannels() error {
 	// Check if channels table is empty
@@ -609,6 +811,40 @@ func (db *DB) GetChannel(channelNumber int) (*models.Channel, error) {
 	return channel, nil
 }
 
+// GetChannelProfile returns the channel's transcode profile, or the default when none is configured
+func (db *DB) GetChannelProfile(channelNumber int) (*models.TranscodeProfile, error) {
+	var data sql.NullString
+	err := db.QueryRow(`SELECT transcode_profile FROM channels WHERE number = $1`, channelNumber).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("channel %d not found", channelNumber)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if profile := decodeProfile(data); profile != nil {
+		return profile, nil
+	}
+	return models.DefaultTranscodeProfile(), nil
+}
+
+// UpdateChannelProfile stores the transcode profile used for future ingests on a channel
+func (db *DB) UpdateChannelProfile(channelNumber int, profile *models.TranscodeProfile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(`
+		UPDATE channels SET transcode_profile = $1, updated_at = $2 WHERE number = $3
+	`, string(data), time.Now(), channelNumber)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("channel %d not found", channelNumber)
+	}
+	return nil
+}
+
 // DeleteVideo deletes a video by its ID
 func (db *DB) DeleteVideo(videoID string) error {
 	// Begin a transaction
@@ -643,7 +879,11 @@ func (db *DB) DeleteVideo(videoID string) error {
 	return tx.Commit()
 }
 
-// UpdateVideoOrder updates the display order of videos in a channel
+// ErrVideoNotOnChannel is returned when a playlist change names a video of another channel
+var ErrVideoNotOnChannel = errors.New("video is not on this channel")
+
+// UpdateVideoOrder updates the display order of videos in a channel. It returns
+// ErrVideoNotOnChannel, changing nothing, when a video is not on the channel.
 func (db *DB) UpdateVideoOrder(channelID int, videoOrders map[string]int) error {
 	tx, err := db.Begin()
 	if err != nil {
@@ -657,21 +897,24 @@ func (db *DB) UpdateVideoOrder(channelID int, videoOrders map[string]int) error
 		return err
 	}
 	
-	// Then insert the new orders and update the videos table
+	// Then update the videos table and insert the new orders
 	for videoID, order := range videoOrders {
-		// Insert into video_order table
-		_, err = tx.Exec(
-			"INSERT INTO video_order (video_id, channel_id, display_order) VALUES ($1, $2, $3)",
-			videoID, channelID, order,
+		result, err := tx.Exec(
+			"UPDATE videos SET display_order = $1 WHERE id = $2 AND channel_id = $3",
+			order, videoID, channelID,
 		)
 		if err != nil {
 			return err
 		}
-		
-		// Also update the display_order column in videos table
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrVideoNotOnChannel
+		}
+
 		_, err = tx.Exec(
-			"UPDATE videos SET display_order = $1 WHERE id = $2",
-			order, videoID,
+			"INSERT INTO video_order (video_id, channel_id, display_order) VALUES ($1, $2, $3)",
+			videoID, channelID, order,
 		)
 		if err != nil {
 			return err
@@ -755,7 +998,8 @@ func (db *DB) GetChannelVideosWithDetails(channelID int) ([]models.AdminVideo, e
 	rows, err := db.Query(`
 		SELECT v.id, v.youtube_url, v.s3_key, v.channel_id, v.title, v.description, v.status, v.error_msg, 
 		       v.uploaded_by, v.created_at, v.updated_at, v.duration,
-		       v.display_order, v.thumbnail_url
+		       v.display_order, v.thumbnail_url, v.progress_stage, v.progress_percent, v.source_id, v.transcode_profile, v.loudness,
+		       v.sprite_url, v.sprite_vtt_url, v.subtitles, v.audio_tracks, v.asset_id, v.checksum
 		FROM videos v
 		WHERE v.channel_id = $1
 		ORDER BY COALESCE(v.display_order, 9999), v.created_at DESC
@@ -772,17 +1016,35 @@ func (db *DB) GetChannelVideosWithDetails(channelID int) ([]models.AdminVideo, e
 		var displayOrder sql.NullInt64
 		var duration sql.NullFloat64
 		var thumbnailURL sql.NullString
+		var progressStage sql.NullString
+		var progressPercent sql.NullFloat64
+		var sourceID sql.NullString
+		var profile sql.NullString
+		var loudness sql.NullString
+		var spriteURL, spriteVTTURL sql.NullString
+		var subtitles, audioTracks, assetID, checksum sql.NullString
 		err := rows.Scan(
 			&video.ID, &video.YoutubeURL, &video.S3Key, &video.ChannelID,
 			&video.Title, &video.Description, &status, &video.ErrorMsg,
 			&video.UploadedBy, &video.CreatedAt, &video.UpdatedAt, &duration,
-			&displayOrder, &thumbnailURL,
+			&displayOrder, &thumbnailURL, &progressStage, &progressPercent, &sourceID, &profile, &loudness, &spriteURL, &spriteVTTURL, &subtitles, &audioTracks, &assetID, &checksum,
 		)
 		if err != nil {
 			return nil, err
 		}
 		
 		video.Status = models.VideoStatus(status)
+		video.ProgressStage = progressStage.String
+		video.ProgressPercent = progressPercent.Float64
+		video.SourceID = sourceID.String
+		video.Profile = decodeProfile(profile)
+		video.Loudness = decodeLoudness(loudness)
+		video.SpriteURL = spriteURL.String
+		video.SpriteVTTURL = spriteVTTURL.String
+		video.Subtitles = decodeSubtitles(subtitles)
+		video.AudioTracks = decodeAudioTracks(audioTracks)
+		video.AssetID = assetID.String
+		video.Checksum = checksum.String
 		
 		// Set duration if available
 		if duration.Valid {
@@ -798,7 +1060,7 @@ func (db *DB) GetChannelVideosWithDetails(channelID int) ([]models.AdminVideo, e
 		if thumbnailURL.Valid && thumbnailURL.String != "" {
 			video.ThumbnailURL = thumbnailURL.String
 		} else {
-			video.ThumbnailURL = fmt.Sprintf("/thumbnails/thumbnail_%s.jpg", video.ID)
+			video.ThumbnailURL = keys.PreviewURL(keys.Thumbnail(video.ID))
 		}
 		
 		// Generate a URL for the video
@@ -817,7 +1079,8 @@ func (db *DB) GetChannelVideosWithDetails(channelID int) ([]models.AdminVideo, e
 // GetChannelVideos retrieves all videos for a specific channel
 func (db *DB) GetChannelVideos(channelNumber int) ([]*models.Video, error) {
 	rows, err := db.Query(`
-		SELECT id, title, description, s3_key, youtube_url, created_at, status, duration, thumbnail_url
+		SELECT id, title, description, s3_key, youtube_url, created_at, status, duration, thumbnail_url,
+		       sprite_url, sprite_vtt_url, subtitles, audio_tracks
 		FROM videos 
 		WHERE channel_id = $1 AND status = 'completed'
 		ORDER BY COALESCE(display_order, 9999), created_at
@@ -831,12 +1094,17 @@ func (db *DB) GetChannelVideos(channelNumber int) ([]*models.Video, error) {
 	for rows.Next() {
 		video := &models.Video{}
 		var youtubeURL, status string
-		var thumbnailURL sql.NullString
+		var thumbnailURL, spriteURL, spriteVTTURL, subtitles, audioTracks sql.NullString
 		err := rows.Scan(&video.ID, &video.Title, &video.Description, &video.S3Key, 
-		                 &youtubeURL, &video.CreatedAt, &status, &video.Duration, &thumbnailURL)
+		                 &youtubeURL, &video.CreatedAt, &status, &video.Duration, &thumbnailURL,
+		                 &spriteURL, &spriteVTTURL, &subtitles, &audioTracks)
 		if err != nil {
 			return nil, err
 		}
+		video.SpriteURL = spriteURL.String
+		video.SpriteVTTURL = spriteVTTURL.String
+		video.Subtitles = decodeSubtitles(subtitles)
+		video.AudioTracks = decodeAudioTracks(audioTracks)
 		
 		// Set tags to include the channel
 		video.Tags = []string{fmt.Sprintf("channel_%d", channelNumber)}
@@ -853,7 +1121,7 @@ func (db *DB) GetChannelVideos(channelNumber int) ([]*models.Video, error) {
 		if thumbnailURL.Valid && thumbnailURL.String != "" {
 			video.ThumbnailURL = thumbnailURL.String
 		} else {
-			video.ThumbnailURL = fmt.Sprintf("/thumbnails/thumbnail_%s.jpg", video.ID)
+			video.ThumbnailURL = keys.PreviewURL(keys.Thumbnail(video.ID))
 		}
 		
 		videos = append(videos, video)
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Dialect is the SQL flavour of the database behind a DB
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// sqlitePragmas are appended to every SQLite DSN: enforce foreign keys like Postgres,
+// let readers run alongside the writer, wait for locks instead of failing, take the
+// write lock when a transaction begins, and store times in a sortable text format.
+var sqlitePragmas = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=journal_mode(WAL)",
+	"_pragma=busy_timeout(10000)",
+	"_txlock=immediate",
+	"_time_format=sqlite",
+}
+
+// parseDSN picks the dialect for a DATABASE_URL and returns the driver name and data
+// source to open. postgres:// URLs and key=value strings are Postgres; anything else
+// (a file path, file: URI or sqlite: prefixed path) is a SQLite database file.
+func parseDSN(dbConnStr string) (Dialect, string, string) {
+	if strings.HasPrefix(dbConnStr, "postgres://") || strings.HasPrefix(dbConnStr, "postgresql://") ||
+		strings.Contains(dbConnStr, "host=") || strings.Contains(dbConnStr, "dbname=") {
+		return Postgres, "postgres", dbConnStr
+	}
+
+	dsn := dbConnStr
+	for _, prefix := range []string{"sqlite://", "sqlite3://", "sqlite:", "sqlite3:"} {
+		if strings.HasPrefix(dsn, prefix) {
+			dsn = strings.TrimPrefix(dsn, prefix)
+			break
+		}
+	}
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return SQLite, "sqlite", dsn + separator + strings.Join(sqlitePragmas, "&")
+}
+
+// describeDSN names the database for logs without leaking credentials
+func describeDSN(dialect Dialect, dbConnStr string) string {
+	if dialect == SQLite {
+		path := strings.TrimPrefix(dbConnStr, "file:")
+		if i := strings.Index(path, "?"); i >= 0 {
+			path = path[:i]
+		}
+		return "sqlite " + path
+	}
+	if parsedURL, err := url.Parse(dbConnStr); err == nil && parsedURL.Host != "" {
+		return parsedURL.Host
+	}
+	return "postgres"
+}
+
+// sqliteDDL adapts migration statements written for Postgres: SQLite has no
+// ADD COLUMN IF NOT EXISTS (a SQLite database never predates the migrations, so the
+// guard is not needed), and the driver only returns time.Time for columns declared
+// exactly TIMESTAMP.
+var sqliteDDL = strings.NewReplacer(
+	"ADD COLUMN IF NOT EXISTS", "ADD COLUMN",
+	"TIMESTAMP WITH TIME ZONE", "TIMESTAMP",
+)
+
+// ddl returns migration statements in this dialect
+func (d Dialect) ddl(statements string) string {
+	if d == SQLite {
+		return sqliteDDL.Replace(statements)
+	}
+	return statements
+}
+
+// forUpdate is the row-locking suffix for a SELECT inside a transaction. SQLite
+// transactions take the database write lock when they begin, so it needs none.
+func (d Dialect) forUpdate() string {
+	if d == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
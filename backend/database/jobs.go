@@ -0,0 +1,302 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"live-broadcast-backend/models"
+
+	"github.com/google/uuid"
+)
+
+const jobColumns = `id, video_id, kind, source_url, channel_id, status, attempts, max_attempts,
+		       last_error, next_run_at, created_by, created_at, updated_at, started_at, finished_at, options`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*models.IngestJob, error) {
+	job := &models.IngestJob{}
+	var kind, status, options string
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.VideoID, &kind, &job.SourceURL, &job.ChannelID, &status,
+		&job.Attempts, &job.MaxAttempts, &job.LastError, &job.NextRunAt,
+		&job.CreatedBy, &job.CreatedAt, &job.UpdatedAt, &startedAt, &finishedAt, &options,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &job.Options); err != nil {
+			return nil, fmt.Errorf("corrupt options for job %s: %v", job.ID, err)
+		}
+	}
+	job.Kind = models.JobKind(kind)
+	job.Status = models.JobStatus(status)
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
+
+// CreateJob stores a new ingest job in the queued state
+func (db *DB) CreateJob(job *models.IngestJob) error {
+	if job.ID == "" {
+		job.ID = uuid.New().String()
+	}
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	if job.NextRunAt.IsZero() {
+		job.NextRunAt = now
+	}
+	job.UpdatedAt = now
+	if job.Status == "" {
+		job.Status = models.JobQueued
+	}
+
+	options, err := json.Marshal(job.Options)
+	if err != nil {
+		return fmt.Errorf("failed to encode job options: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO ingest_jobs (id, video_id, kind, source_url, channel_id, status, attempts, max_attempts,
+		                         last_error, next_run_at, created_by, created_at, updated_at, options)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, job.ID, job.VideoID, job.Kind, job.SourceURL, job.ChannelID, job.Status, job.Attempts, job.MaxAttempts,
+		job.LastError, job.NextRunAt, job.CreatedBy, job.CreatedAt, job.UpdatedAt, string(options))
+	return err
+}
+
+// GetJob retrieves an ingest job by its ID; it returns sql.ErrNoRows when there is none
+func (db *DB) GetJob(id string) (*models.IngestJob, error) {
+	return scanJob(db.QueryRow(`SELECT `+jobColumns+` FROM ingest_jobs WHERE id = $1`, id))
+}
+
+// ListJobs returns the most recent ingest jobs, optionally filtered by status. Channels
+// limits the jobs to those channels; nil means every channel.
+func (db *DB) ListJobs(status models.JobStatus, channels []int, limit int) ([]models.IngestJob, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if channels != nil && len(channels) == 0 {
+		return []models.IngestJob{}, nil
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	if status != "" {
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if channels != nil {
+		placeholders := make([]string, len(channels))
+		for i, channel := range channels {
+			args = append(args, channel)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, "channel_id IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(`
+		SELECT `+jobColumns+`
+		FROM ingest_jobs
+		`+where+`
+		ORDER BY created_at DESC
+		LIMIT `+fmt.Sprintf("$%d", len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.IngestJob{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimNextJob atomically moves the oldest runnable queued job to running and returns it.
+// It returns nil without error when no job is ready.
+func (db *DB) ClaimNextJob() (*models.IngestJob, error) {
+	// Another worker may claim the same candidate between the SELECT and the UPDATE,
+	// so retry a few times before reporting the queue as empty.
+	for i := 0; i < 5; i++ {
+		now := time.Now()
+		var id string
+		err := db.QueryRow(`
+			SELECT id
+			FROM ingest_jobs
+			WHERE status = $1 AND next_run_at <= $2
+			ORDER BY next_run_at, created_at
+			LIMIT 1
+		`, models.JobQueued, now).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := db.Exec(`
+			UPDATE ingest_jobs
+			SET status = $1, attempts = attempts + 1, started_at = $2, updated_at = $2
+			WHERE id = $3 AND status = $4
+		`, models.JobRunning, now, id, models.JobQueued)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return db.GetJob(id)
+		}
+	}
+	return nil, nil
+}
+
+// CompleteJob marks a running job as completed
+func (db *DB) CompleteJob(id string) error {
+	now := time.Now()
+	_, err := db.Exec(`
+		UPDATE ingest_jobs
+		SET status = $1, last_error = '', finished_at = $2, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`, models.JobCompleted, now, id, models.JobRunning)
+	return err
+}
+
+// FailJob marks a running job as permanently failed
+func (db *DB) FailJob(id string, errorMsg string) error {
+	now := time.Now()
+	_, err := db.Exec(`
+		UPDATE ingest_jobs
+		SET status = $1, last_error = $2, finished_at = $3, updated_at = $3
+		WHERE id = $4 AND status = $5
+	`, models.JobFailed, errorMsg, now, id, models.JobRunning)
+	return err
+}
+
+// RescheduleJob puts a running job back in the queue to be retried at nextRunAt
+func (db *DB) RescheduleJob(id string, errorMsg string, nextRunAt time.Time) error {
+	_, err := db.Exec(`
+		UPDATE ingest_jobs
+		SET status = $1, last_error = $2, next_run_at = $3, updated_at = $4
+		WHERE id = $5 AND status = $6
+	`, models.JobQueued, errorMsg, nextRunAt, time.Now(), id, models.JobRunning)
+	return err
+}
+
+// CancelJob marks a queued or running job as cancelled.
+// It returns false if the job had already finished.
+func (db *DB) CancelJob(id string) (bool, error) {
+	now := time.Now()
+	result, err := db.Exec(`
+		UPDATE ingest_jobs
+		SET status = $1, finished_at = $2, updated_at = $2
+		WHERE id = $3 AND status IN ($4, $5)
+	`, models.JobCancelled, now, id, models.JobQueued, models.JobRunning)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RequeueJob resets a failed or cancelled job so it runs again with a fresh attempt budget.
+// It returns false if the job is not in a retryable state.
+func (db *DB) RequeueJob(id string) (bool, error) {
+	now := time.Now()
+	result, err := db.Exec(`
+		UPDATE ingest_jobs
+		SET status = $1, attempts = 0, last_error = '', next_run_at = $2, updated_at = $2,
+		    started_at = NULL, finished_at = NULL
+		WHERE id = $3 AND status IN ($4, $5)
+	`, models.JobQueued, now, id, models.JobFailed, models.JobCancelled)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// HeartbeatJob records that a worker is still running a job. It returns false once the
+// job is no longer running, e.g. because it was cancelled or recovered elsewhere.
+func (db *DB) HeartbeatJob(id string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE ingest_jobs
+		SET updated_at = $1
+		WHERE id = $2 AND status = $3
+	`, time.Now(), id, models.JobRunning)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RecoverStuckJobs requeues running jobs whose worker has not sent a heartbeat since
+// staleBefore, and fails videos whose ingest was interrupted without a job to resume it.
+// Jobs other processes are still running are left alone. It returns the number of requeued jobs.
+func (db *DB) RecoverStuckJobs(staleBefore time.Time) (int, error) {
+	now := time.Now()
+	result, err := db.Exec(`
+		UPDATE ingest_jobs
+		SET status = $1, next_run_at = $2, updated_at = $2
+		WHERE status = $3 AND updated_at < $4
+	`, models.JobQueued, now, models.JobRunning, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Videos belonging to requeued jobs go back to pending
+	_, err = db.Exec(`
+		UPDATE videos
+		SET status = $1, updated_at = $2
+		WHERE status IN ($3, $4)
+		  AND id IN (SELECT video_id FROM ingest_jobs WHERE status = $5)
+	`, models.StatusPending, now, models.StatusDownloading, models.StatusProcessing, models.JobQueued)
+	if err != nil {
+		return 0, err
+	}
+
+	// Videos left mid-ingest without any live job can never finish; recent ones may still
+	// be waiting for their job to be created
+	_, err = db.Exec(`
+		UPDATE videos
+		SET status = $1, error_msg = $2, updated_at = $3
+		WHERE status IN ($4, $5, $6) AND updated_at < $9
+		  AND id NOT IN (SELECT video_id FROM ingest_jobs WHERE status IN ($7, $8))
+	`, models.StatusFailed, "Ingest was interrupted by a server restart", now,
+		models.StatusPending, models.StatusDownloading, models.StatusProcessing,
+		models.JobQueued, models.JobRunning, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(requeued), nil
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"live-broadcast-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrAssetOnChannel is returned when a library asset is already in a channel's playlist
+var ErrAssetOnChannel = errors.New("asset is already on this channel")
+
+// reportVideosOutsideLibrary warns about completed videos that are not in the media
+// library. Migration 20 added those from before the library; since then ingest is the
+// only way in, so these are left for an operator rather than added behind their back.
+func reportVideosOutsideLibrary(ctx context.Context, conn *sql.Conn) error {
+	var count int
+	err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM videos WHERE status = $1 AND asset_id IS NULL`,
+		models.StatusCompleted).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count videos outside the media library: %v", err)
+	}
+	if count > 0 {
+		log.Printf("Warning: %d completed videos are not in the media library because their ingest failed to add them", count)
+	}
+	return nil
+}
+
+// CreateAssetFromVideo records a freshly ingested video as a library asset with the
+// same ID and makes the video an entry of it
+func (db *DB) CreateAssetFromVideo(videoID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO media_assets (id, s3_key, title, description, duration, thumbnail_url, sprite_url, sprite_vtt_url,
+		                          source_id, source_url, transcode_profile, loudness, subtitles, audio_tracks,
+		                          content_hash, perceptual_hash, checksum, created_by, created_at, updated_at)
+		SELECT id, s3_key, title, description, COALESCE(duration, 0), thumbnail_url, sprite_url, sprite_vtt_url,
+		       source_id, NULLIF(youtube_url, ''), transcode_profile, loudness, subtitles, audio_tracks,
+		       content_hash, perceptual_hash, checksum, uploaded_by, created_at, $2
+		FROM videos
+		WHERE id = $1
+		ON CONFLICT (id) DO UPDATE SET
+			s3_key = EXCLUDED.s3_key,
+			title = EXCLUDED.title,
+			description = EXCLUDED.description,
+			duration = EXCLUDED.duration,
+			thumbnail_url = EXCLUDED.thumbnail_url,
+			sprite_url = EXCLUDED.sprite_url,
+			sprite_vtt_url = EXCLUDED.sprite_vtt_url,
+			transcode_profile = EXCLUDED.transcode_profile,
+			loudness = EXCLUDED.loudness,
+			subtitles = EXCLUDED.subtitles,
+			audio_tracks = EXCLUDED.audio_tracks,
+			content_hash = EXCLUDED.content_hash,
+			perceptual_hash = EXCLUDED.perceptual_hash,
+			checksum = EXCLUDED.checksum,
+			updated_at = EXCLUDED.updated_at
+	`, videoID, time.Now())
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE videos SET asset_id = $1 WHERE id = $1`, videoID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// scanMediaAsset reads one media_assets row selected with mediaAssetColumns
+func scanMediaAsset(row rowScanner) (*models.MediaAsset, error) {
+	var asset models.MediaAsset
+	var description, thumbnailURL, spriteURL, spriteVTTURL, sourceID, sourceURL sql.NullString
+	var profile, loudness, subtitles, audioTracks, checksum, createdBy sql.NullString
+	err := row.Scan(&asset.ID, &asset.S3Key, &asset.Title, &description, &asset.Duration,
+		&thumbnailURL, &spriteURL, &spriteVTTURL, &sourceID, &sourceURL,
+		&profile, &loudness, &subtitles, &audioTracks, &checksum, &createdBy, &asset.CreatedAt, &asset.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	asset.Description = description.String
+	asset.ThumbnailURL = thumbnailURL.String
+	asset.SpriteURL = spriteURL.String
+	asset.SpriteVTTURL = spriteVTTURL.String
+	asset.SourceID = sourceID.String
+	asset.SourceURL = sourceURL.String
+	asset.Profile = decodeProfile(profile)
+	asset.Loudness = decodeLoudness(loudness)
+	asset.Subtitles = decodeSubtitles(subtitles)
+	asset.AudioTracks = decodeAudioTracks(audioTracks)
+	asset.Checksum = checksum.String
+	asset.CreatedBy = createdBy.String
+	asset.Channels = []int{}
+	return &asset, nil
+}
+
+const mediaAssetColumns = `id, s3_key, title, description, duration, thumbnail_url, sprite_url, sprite_vtt_url,
+	source_id, source_url, transcode_profile, loudness, subtitles, audio_tracks, checksum, created_by, created_at, updated_at`
+
+// GetMediaAsset returns a library asset and the channels it is on
+func (db *DB) GetMediaAsset(id string) (*models.MediaAsset, error) {
+	asset, err := scanMediaAsset(db.QueryRow(`SELECT `+mediaAssetColumns+` FROM media_assets WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	channels, err := db.assetChannels(id)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := channels[id]; ok {
+		asset.Channels = c
+	}
+	return asset, nil
+}
+
+// ListMediaAssets returns every library asset, newest first, with the channels each is on
+func (db *DB) ListMediaAssets() ([]*models.MediaAsset, error) {
+	rows, err := db.Query(`SELECT ` + mediaAssetColumns + ` FROM media_assets ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assets := []*models.MediaAsset{}
+	for rows.Next() {
+		asset, err := scanMediaAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	channels, err := db.assetChannels("")
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range assets {
+		if c, ok := channels[asset.ID]; ok {
+			asset.Channels = c
+		}
+	}
+	return assets, nil
+}
+
+// assetChannels maps asset IDs to the channels whose playlist includes them, for one
+// asset or, when id is empty, for all of them
+func (db *DB) assetChannels(id string) (map[string][]int, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT asset_id, channel_id FROM videos
+		WHERE asset_id IS NOT NULL AND ($1 = '' OR asset_id = $1)
+		ORDER BY asset_id, channel_id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := make(map[string][]int)
+	for rows.Next() {
+		var assetID string
+		var channelID int
+		if err := rows.Scan(&assetID, &channelID); err != nil {
+			return nil, err
+		}
+		channels[assetID] = append(channels[assetID], channelID)
+	}
+	return channels, rows.Err()
+}
+
+// AddAssetToChannel appends a library asset to a channel's playlist as a new completed
+// entry and returns it. It returns ErrAssetOnChannel when the channel already airs it.
+func (db *DB) AddAssetToChannel(assetID string, channelID int, addedBy string) (*models.AdminVideo, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM videos WHERE asset_id = $1 AND channel_id = $2)`, assetID, channelID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAssetOnChannel
+	}
+
+	id := uuid.New().String()
+	now := time.Now()
+	result, err := db.Exec(`
+		INSERT INTO videos (id, youtube_url, s3_key, channel_id, title, description, status, error_msg, uploaded_by,
+		                    duration, created_at, updated_at, display_order, thumbnail_url, source_id, transcode_profile,
+		                    loudness, sprite_url, sprite_vtt_url, subtitles, audio_tracks, content_hash, perceptual_hash, checksum, asset_id)
+		SELECT $1, COALESCE(source_url, ''), s3_key, $2, title, description, $3, '', $4,
+		       duration, $5, $5, (SELECT COALESCE(MAX(display_order), 0) + 1 FROM videos WHERE channel_id = $2),
+		       thumbnail_url, source_id, transcode_profile,
+		       loudness, sprite_url, sprite_vtt_url, subtitles, audio_tracks, content_hash, perceptual_hash, checksum, id
+		FROM media_assets
+		WHERE id = $6
+	`, id, channelID, models.StatusCompleted, addedBy, now, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return db.GetVideoByID(id)
+}
+
+// DeleteMediaAsset removes a library asset and every playlist entry airing it. It
+// returns the S3 keys the asset and its entries used and the channels that lost entries.
+func (db *DB) DeleteMediaAsset(id string) ([]string, []int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var s3Key string
+	if err := tx.QueryRow(`SELECT s3_key FROM media_assets WHERE id = $1`+db.dialect.forUpdate(), id).Scan(&s3Key); err != nil {
+		return nil, nil, err
+	}
+	videoKeys := []string{s3Key}
+	var channels []int
+	rows, err := tx.Query(`DELETE FROM videos WHERE asset_id = $1 RETURNING s3_key, channel_id`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	seenKeys := map[string]bool{s3Key: true}
+	seenChannels := make(map[int]bool)
+	for rows.Next() {
+		var key string
+		var channel int
+		if err := rows.Scan(&key, &channel); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if key != "" && !seenKeys[key] {
+			seenKeys[key] = true
+			videoKeys = append(videoKeys, key)
+		}
+		if !seenChannels[channel] {
+			seenChannels[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM media_assets WHERE id = $1`, id); err != nil {
+		return nil, nil, err
+	}
+	return videoKeys, channels, tx.Commit()
+}
+
+// GetSubtitles returns the subtitle tracks of a library asset or, for videos outside
+// the library, of the video itself
+func (db *DB) GetSubtitles(id string) ([]models.SubtitleTrack, error) {
+	var subtitles sql.NullString
+	err := db.QueryRow(`SELECT subtitles FROM media_assets WHERE id = $1`, id).Scan(&subtitles)
+	if err == sql.ErrNoRows {
+		err = db.QueryRow(`SELECT subtitles FROM videos WHERE id = $1`, id).Scan(&subtitles)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeSubtitles(subtitles), nil
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migrationLockID is the Postgres advisory lock held while migrating, so replicas
+// starting together apply each migration once. SQLite needs none: it is used by a
+// single node, and a second process racing it fails to record the same version.
+const migrationLockID = 727465
+
+// Migration is one numbered schema change and the statements that undo it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil when pending
+}
+
+// Migrate applies every pending migration in order and returns how many were applied
+func (db *DB) Migrate(ctx context.Context) (int, error) {
+	applied := 0
+	err := db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		applied, err = migrate(ctx, conn, db.dialect)
+		return err
+	})
+	return applied, err
+}
+
+// migrate applies every pending migration on a connection holding the migration lock
+func migrate(ctx context.Context, conn *sql.Conn, dialect Dialect) (int, error) {
+	done, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	for version := range done {
+		if findMigration(version) == nil {
+			log.Printf("Warning: database has migration %d, which this build does not know; it was migrated by a newer version", version)
+		}
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		if err := runMigration(ctx, conn, dialect, m, true); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// Rollback undoes the last steps applied migrations, newest first, and returns how
+// many were rolled back
+func (db *DB) Rollback(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT $1`, steps)
+		if err != nil {
+			return err
+		}
+		var versions []int
+		for rows.Next() {
+			var version int
+			if err := rows.Scan(&version); err != nil {
+				rows.Close()
+				return err
+			}
+			versions = append(versions, version)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, version := range versions {
+			m := findMigration(version)
+			if m == nil {
+				return fmt.Errorf("cannot roll back migration %d: unknown to this build", version)
+			}
+			if err := runMigration(ctx, conn, db.dialect, *m, false); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// MigrationStatuses lists every known migration with when it was applied
+func (db *DB) MigrationStatuses(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := db.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			status := MigrationStatus{Version: m.Version, Name: m.Name}
+			if at, ok := done[m.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withMigrationLock runs fn on one connection holding the migration lock, after
+// making sure the schema_migrations table exists. Advisory locks belong to a
+// session, hence the dedicated connection.
+func (db *DB) withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if db.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return fmt.Errorf("failed to take migration lock: %v", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+				log.Printf("Warning: failed to release migration lock: %v", err)
+			}
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, db.dialect.ddl(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)
+	`))
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	return fn(conn)
+}
+
+// appliedMigrations maps applied versions to when they were applied
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		done[version] = at
+	}
+	return done, rows.Err()
+}
+
+// runMigration applies (up) or undoes a migration and records it, in one transaction
+func runMigration(ctx context.Context, conn *sql.Conn, dialect Dialect, m Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements, record, args := m.Down, `DELETE FROM schema_migrations WHERE version = $1`, []interface{}{m.Version}
+	if up {
+		statements = m.Up
+		record = `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`
+		args = append(args, m.Name, time.Now())
+	}
+	if _, err := tx.ExecContext(ctx, dialect.ddl(statements)); err != nil {
+		return fmt.Errorf("migration %03d_%s failed: %v", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration %03d_%s: %v", m.Version, m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if up {
+		log.Printf("Applied migration %03d_%s", m.Version, m.Name)
+	} else {
+		log.Printf("Rolled back migration %03d_%s", m.Version, m.Name)
+	}
+	return nil
+}
+
+func findMigration(version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package database
+
+// migrations is the schema history, oldest first. Versions are never renumbered or
+// edited once released; change the schema by appending a migration. The early ones
+// use IF NOT EXISTS so databases created before versioned migrations adopt them.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "core_tables",
+		Up: `
+			CREATE TABLE IF NOT EXISTS channels (
+				number INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				description TEXT,
+				theme TEXT,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS videos (
+				id TEXT PRIMARY KEY,
+				youtube_url TEXT NOT NULL,
+				s3_key TEXT NOT NULL,
+				channel_id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				description TEXT,
+				status TEXT NOT NULL,
+				error_msg TEXT,
+				uploaded_by TEXT NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				FOREIGN KEY (channel_id) REFERENCES channels(number)
+			);
+			CREATE TABLE IF NOT EXISTS video_order (
+				video_id TEXT PRIMARY KEY,
+				channel_id INTEGER NOT NULL,
+				display_order INTEGER NOT NULL,
+				FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+				FOREIGN KEY (channel_id) REFERENCES channels(number),
+				UNIQUE (channel_id, display_order)
+			);
+			CREATE TABLE IF NOT EXISTS users (
+				id TEXT PRIMARY KEY,
+				username TEXT UNIQUE NOT NULL,
+				email TEXT UNIQUE NOT NULL,
+				password TEXT NOT NULL,
+				role TEXT NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+		`,
+		Down: `
+			DROP TABLE video_order;
+			DROP TABLE videos;
+			DROP TABLE users;
+			DROP TABLE channels;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "video_playlist_columns",
+		Up: `
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS duration FLOAT DEFAULT 0;
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS display_order INTEGER DEFAULT NULL;
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS thumbnail_url TEXT DEFAULT NULL;
+		`,
+		Down: `
+			ALTER TABLE videos DROP COLUMN thumbnail_url;
+			ALTER TABLE videos DROP COLUMN display_order;
+			ALTER TABLE videos DROP COLUMN duration;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "ingest_progress",
+		Up: `
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS progress_stage TEXT DEFAULT NULL;
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS progress_percent FLOAT DEFAULT 0;
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS progress_updated_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+		`,
+		Down: `
+			ALTER TABLE videos DROP COLUMN progress_updated_at;
+			ALTER TABLE videos DROP COLUMN progress_percent;
+			ALTER TABLE videos DROP COLUMN progress_stage;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "video_source_id",
+		Up: `
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS source_id TEXT DEFAULT NULL;
+			CREATE INDEX IF NOT EXISTS idx_videos_channel_source ON videos (channel_id, source_id);
+		`,
+		Down: `
+			DROP INDEX idx_videos_channel_source;
+			ALTER TABLE videos DROP COLUMN source_id;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "transcode_profiles",
+		Up: `
+			ALTER TABLE channels ADD COLUMN IF NOT EXISTS transcode_profile TEXT DEFAULT NULL;
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS transcode_profile TEXT DEFAULT NULL;
+		`,
+		Down: `
+			ALTER TABLE videos DROP COLUMN transcode_profile;
+			ALTER TABLE channels DROP COLUMN transcode_profile;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "video_loudness",
+		Up:      `ALTER TABLE videos ADD COLUMN IF NOT EXISTS loudness TEXT DEFAULT NULL;`,
+		Down:    `ALTER TABLE videos DROP COLUMN loudness;`,
+	},
+	{
+		Version: 7,
+		Name:    "preview_sprites",
+		Up: `
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS sprite_url TEXT DEFAULT NULL;
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS sprite_vtt_url TEXT DEFAULT NULL;
+		`,
+		Down: `
+			ALTER TABLE videos DROP COLUMN sprite_vtt_url;
+			ALTER TABLE videos DROP COLUMN sprite_url;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "video_subtitles",
+		Up:      `ALTER TABLE videos ADD COLUMN IF NOT EXISTS subtitles TEXT DEFAULT NULL;`,
+		Down:    `ALTER TABLE videos DROP COLUMN subtitles;`,
+	},
+	{
+		Version: 9,
+		Name:    "video_audio_tracks",
+		Up:      `ALTER TABLE videos ADD COLUMN IF NOT EXISTS audio_tracks TEXT DEFAULT NULL;`,
+		Down:    `ALTER TABLE videos DROP COLUMN audio_tracks;`,
+	},
+	{
+		Version: 10,
+		Name:    "ingest_jobs",
+		Up: `
+			CREATE TABLE IF NOT EXISTS ingest_jobs (
+				id TEXT PRIMARY KEY,
+				video_id TEXT NOT NULL,
+				kind TEXT NOT NULL,
+				source_url TEXT NOT NULL,
+				channel_id INTEGER NOT NULL,
+				status TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				max_attempts INTEGER NOT NULL DEFAULT 3,
+				last_error TEXT NOT NULL DEFAULT '',
+				next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				created_by TEXT NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				started_at TIMESTAMP WITH TIME ZONE,
+				finished_at TIMESTAMP WITH TIME ZONE
+			);
+			CREATE INDEX IF NOT EXISTS idx_ingest_jobs_status_next_run ON ingest_jobs (status, next_run_at);
+		`,
+		Down: `DROP TABLE ingest_jobs;`,
+	},
+	{
+		Version: 11,
+		Name:    "ingest_job_options",
+		Up:      `ALTER TABLE ingest_jobs ADD COLUMN IF NOT EXISTS options TEXT NOT NULL DEFAULT '{}';`,
+		Down:    `ALTER TABLE ingest_jobs DROP COLUMN options;`,
+	},
+	{
+		Version: 12,
+		Name:    "video_content_hashes",
+		Up: `
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS content_hash TEXT DEFAULT NULL;
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS perceptual_hash TEXT DEFAULT NULL;
+			CREATE INDEX IF NOT EXISTS idx_videos_content_hash ON videos (content_hash);
+		`,
+		Down: `
+			DROP INDEX idx_videos_content_hash;
+			ALTER TABLE videos DROP COLUMN perceptual_hash;
+			ALTER TABLE videos DROP COLUMN content_hash;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "media_library",
+		Up: `
+			CREATE TABLE IF NOT EXISTS media_assets (
+				id TEXT PRIMARY KEY,
+				s3_key TEXT NOT NULL UNIQUE,
+				title TEXT NOT NULL,
+				description TEXT,
+				duration FLOAT NOT NULL DEFAULT 0,
+				thumbnail_url TEXT,
+				sprite_url TEXT,
+				sprite_vtt_url TEXT,
+				source_id TEXT,
+				source_url TEXT,
+				transcode_profile TEXT,
+				loudness TEXT,
+				subtitles TEXT,
+				audio_tracks TEXT,
+				content_hash TEXT,
+				perceptual_hash TEXT,
+				created_by TEXT,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS asset_id TEXT DEFAULT NULL REFERENCES media_assets(id);
+			CREATE INDEX IF NOT EXISTS idx_videos_asset ON videos (asset_id);
+		`,
+		Down: `
+			DROP INDEX idx_videos_asset;
+			ALTER TABLE videos DROP COLUMN asset_id;
+			DROP TABLE media_assets;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "object_checksums",
+		Up: `
+			ALTER TABLE videos ADD COLUMN IF NOT EXISTS checksum TEXT DEFAULT NULL;
+			ALTER TABLE media_assets ADD COLUMN IF NOT EXISTS checksum TEXT DEFAULT NULL;
+			CREATE INDEX IF NOT EXISTS idx_videos_s3_key ON videos (s3_key);
+		`,
+		Down: `
+			DROP INDEX idx_videos_s3_key;
+			ALTER TABLE media_assets DROP COLUMN checksum;
+			ALTER TABLE videos DROP COLUMN checksum;
+		`,
+	},
+	{
+		Version: 15,
+		Name:    "sessions",
+		Up: `
+			CREATE TABLE sessions (
+				token_hash TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+			CREATE INDEX idx_sessions_user ON sessions (user_id);
+			CREATE INDEX idx_sessions_expires ON sessions (expires_at);
+		`,
+		Down: `DROP TABLE sessions;`,
+	},
+	{
+		Version: 16,
+		Name:    "roles",
+		Up: `
+			UPDATE users SET role = 'owner' WHERE role = 'admin';
+			CREATE TABLE user_channels (
+				user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				channel_id INTEGER NOT NULL REFERENCES channels(number) ON DELETE CASCADE,
+				PRIMARY KEY (user_id, channel_id)
+			);
+		`,
+		Down: `
+			DROP TABLE user_channels;
+			UPDATE users SET role = 'admin' WHERE role = 'owner';
+		`,
+	},
+	{
+		// flagDefaultAdmin flags the admin account, and only while it still has the default password
+		Version: 17,
+		Name:    "user_management",
+		Up: `
+			ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE users ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT FALSE;
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN must_change_password;
+			ALTER TABLE users DROP COLUMN disabled;
+		`,
+	},
+	{
+		Version: 18,
+		Name:    "api_keys",
+		Up: `
+			CREATE TABLE api_keys (
+				id TEXT PRIMARY KEY,
+				key_hash TEXT NOT NULL UNIQUE,
+				prefix TEXT NOT NULL,
+				name TEXT NOT NULL,
+				user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				permissions TEXT NOT NULL,
+				channels TEXT,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				last_used_at TIMESTAMP WITH TIME ZONE,
+				revoked_at TIMESTAMP WITH TIME ZONE
+			);
+			CREATE INDEX idx_api_keys_user ON api_keys (user_id);
+		`,
+		Down: `DROP TABLE api_keys;`,
+	},
+	{
+		// Players never applied the playout gain, so those channels were not normalised.
+		// Rolling back leaves them on transcode; which ones used playout is not kept.
+		Version: 19,
+		Name:    "drop_playout_loudness",
+		Up: `
+			UPDATE channels
+			SET transcode_profile = REPLACE(transcode_profile, '"loudnessMode":"playout"', '"loudnessMode":"transcode"')
+			WHERE transcode_profile LIKE '%"loudnessMode":"playout"%';
+		`,
+		Down: `SELECT 1;`,
+	},
+	{
+		// Completed videos from before the media library become its assets; videos sharing
+		// an S3 object become entries of one asset, whose ID is that of the earliest video
+		// so existing preview and subtitle keys stay valid. Rolling back keeps the assets,
+		// which cannot be told apart from those added by ingest since.
+		Version: 20,
+		Name:    "adopt_library_videos",
+		Up: `
+			INSERT INTO media_assets (id, s3_key, title, description, duration, thumbnail_url, sprite_url, sprite_vtt_url,
+			                          source_id, source_url, transcode_profile, loudness, subtitles, audio_tracks,
+			                          content_hash, perceptual_hash, checksum, created_by, created_at, updated_at)
+			SELECT id, s3_key, title, description, COALESCE(duration, 0), thumbnail_url, sprite_url, sprite_vtt_url,
+			       source_id, NULLIF(youtube_url, ''), transcode_profile, loudness, subtitles, audio_tracks,
+			       content_hash, perceptual_hash, checksum, uploaded_by, created_at, updated_at
+			FROM videos v
+			WHERE status = 'completed' AND asset_id IS NULL AND NOT EXISTS (
+				SELECT 1 FROM videos e
+				WHERE e.s3_key = v.s3_key AND e.status = 'completed' AND e.asset_id IS NULL
+				  AND (e.created_at < v.created_at OR (e.created_at = v.created_at AND e.id < v.id))
+			)
+			ON CONFLICT DO NOTHING;
+			UPDATE videos AS v SET asset_id = a.id
+			FROM media_assets a
+			WHERE v.asset_id IS NULL AND v.status = 'completed' AND a.s3_key = v.s3_key;
+		`,
+		Down: `SELECT 1;`,
+	},
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"live-broadcast-backend/models"
+)
+
+// hashToken is how session tokens and API key secrets are stored, so a leaked database
+// holds no usable credentials
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession stores a new login session
+func (db *DB) CreateSession(session *models.Session) error {
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	_, err := db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, hashToken(session.Token), session.UserID, session.CreatedAt, session.ExpiresAt)
+	return err
+}
+
+// GetSession returns the session for a token, or nil when it is unknown or expired
+func (db *DB) GetSession(token string) (*models.Session, error) {
+	session := &models.Session{Token: token}
+	err := db.QueryRow(`
+		SELECT user_id, created_at, expires_at FROM sessions WHERE token_hash = $1
+	`, hashToken(token)).Scan(&session.UserID, &session.CreatedAt, &session.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// RenewSession moves a session's expiry to expiresAt
+func (db *DB) RenewSession(token string, expiresAt time.Time) error {
+	_, err := db.Exec(`UPDATE sessions SET expires_at = $1 WHERE token_hash = $2`, expiresAt, hashToken(token))
+	return err
+}
+
+// DeleteSession ends a session
+func (db *DB) DeleteSession(token string) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE token_hash = $1`, hashToken(token))
+	return err
+}
+
+// DeleteUserSessions ends every session of a user and returns how many there were
+func (db *DB) DeleteUserSessions(userID string) (int, error) {
+	result, err := db.Exec(`DELETE FROM sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// PurgeExpiredSessions deletes sessions past their expiry and returns how many
+func (db *DB) PurgeExpiredSessions() (int, error) {
+	result, err := db.Exec(`DELETE FROM sessions WHERE expires_at <= $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
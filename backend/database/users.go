@@ -0,0 +1,225 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"live-broadcast-backend/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUserExists is returned when a username or email is already taken
+var ErrUserExists = errors.New("username or email is already taken")
+
+// GetUser returns a user with the channels they are limited to
+func (db *DB) GetUser(userID string) (*models.User, error) {
+	var user models.User
+	err := db.QueryRow(`
+		SELECT id, username, email, role, disabled, must_change_password, created_at, updated_at
+		FROM users
+		WHERE id = $1
+	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Disabled,
+		&user.MustChangePassword, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := db.userChannels(userID)
+	if err != nil {
+		return nil, err
+	}
+	user.Channels = channels[userID]
+	return &user, nil
+}
+
+// ListUsers returns every user, ordered by username
+func (db *DB) ListUsers() ([]*models.User, error) {
+	rows, err := db.Query(`
+		SELECT id, username, email, role, disabled, must_change_password, created_at, updated_at
+		FROM users
+		ORDER BY username
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Disabled,
+			&user.MustChangePassword, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	channels, err := db.userChannels("")
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		user.Channels = channels[user.ID]
+	}
+	return users, nil
+}
+
+// userChannels maps users to the channels they are limited to, for one user or, when
+// userID is empty, for all
+func (db *DB) userChannels(userID string) (map[string][]int, error) {
+	rows, err := db.Query(`
+		SELECT user_id, channel_id FROM user_channels
+		WHERE $1 = '' OR user_id = $1
+		ORDER BY user_id, channel_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := make(map[string][]int)
+	for rows.Next() {
+		var id string
+		var channel int
+		if err := rows.Scan(&id, &channel); err != nil {
+			return nil, err
+		}
+		channels[id] = append(channels[id], channel)
+	}
+	return channels, rows.Err()
+}
+
+// CreateUser stores a new user with a password. It returns ErrUserExists when the
+// username or email is taken.
+func (db *DB) CreateUser(user *models.User, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = $1 OR email = $2`,
+		user.Username, user.Email).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrUserExists
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO users (id, username, email, password, role, disabled, must_change_password, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, user.ID, user.Username, user.Email, string(hashedPassword), user.Role, user.Disabled,
+		user.MustChangePassword, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := setUserChannels(tx, user.ID, user.Channels); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateUser saves a user's email, role, channels and disabled flag. It returns
+// ErrUserExists when the email belongs to another user.
+func (db *DB) UpdateUser(user *models.User) error {
+	user.UpdatedAt = time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE email = $1 AND id <> $2`,
+		user.Email, user.ID).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrUserExists
+	}
+
+	result, err := tx.Exec(`
+		UPDATE users SET email = $1, role = $2, disabled = $3, updated_at = $4
+		WHERE id = $5
+	`, user.Email, user.Role, user.Disabled, user.UpdatedAt, user.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := setUserChannels(tx, user.ID, user.Channels); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setUserChannels replaces the channels a user is limited to
+func setUserChannels(tx *sql.Tx, userID string, channels []int) error {
+	if _, err := tx.Exec(`DELETE FROM user_channels WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		if _, err := tx.Exec(`INSERT INTO user_channels (user_id, channel_id) VALUES ($1, $2)`, userID, channel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPassword replaces a user's password. mustChange makes them choose another at their
+// next sign-in.
+func (db *DB) SetPassword(userID, password string, mustChange bool) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(`
+		UPDATE users SET password = $1, must_change_password = $2, updated_at = $3
+		WHERE id = $4
+	`, string(hashedPassword), mustChange, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUser removes a user together with their sessions and channel limits
+func (db *DB) DeleteUser(userID string) error {
+	result, err := db.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -2,22 +2,38 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"live-broadcast-backend/auth"
 	"live-broadcast-backend/database"
+	"live-broadcast-backend/keys"
 	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
 	"live-broadcast-backend/services"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
-	"time"
 )
 
-// AdminVideoRequest is the request body for uploading a video
+// AdminVideoRequest is the request body for importing videos from a remote URL.
+// SourceURL may be a video, playlist or channel on any yt-dlp supported site or a
+// plain media file; YoutubeURL is accepted for older clients.
 type AdminVideoRequest struct {
-	YoutubeURL    string `json:"youtubeUrl"`
-	ChannelNumber int    `json:"channelNumber"`
+	YoutubeURL    string               `json:"youtubeUrl"`
+	SourceURL     string               `json:"sourceUrl"`
+	ChannelNumber int                  `json:"channelNumber"`
+	Options       models.ImportOptions `json:"options"`
 }
 
+// importDatePattern matches the YYYYMMDD dates accepted in import options
+var importDatePattern = regexp.MustCompile(`^\d{8}$`)
+
+// importSubLangsPattern matches the yt-dlp --sub-langs lists accepted in import options
+var importSubLangsPattern = regexp.MustCompile(`^[A-Za-z0-9.*_-]+(,[A-Za-z0-9.*_-]+)*$`)
+
 // VideoDeleteRequest is the request body for deleting a video
 type VideoDeleteRequest struct {
 	VideoID string `json:"videoId"`
@@ -42,41 +58,53 @@ type ChannelUpdateRequest struct {
 	Theme       string `json:"theme"`
 }
 
+// AdminStore is the storage admin handlers use directly; sessions and API keys go
+// through their managers
+type AdminStore interface {
+	repository.VideoRepository
+	repository.LibraryRepository
+	repository.ChannelRepository
+	repository.UserRepository
+	repository.JobRepository
+}
+
 // AdminHandler contains dependencies for admin handlers
 type AdminHandler struct {
-	db              *database.DB
+	db              AdminStore
 	ytDownloader    *services.YouTubeDownloader
+	fileIngestor    *services.FileIngestor
+	uploadStore     *services.UploadStore
 	videoService    *services.VideoService
-	sessionDuration time.Duration
+	jobQueue        *services.JobQueue
+	subtitles       *services.SubtitleService
+	deleter         *services.MediaDeleter
+	gc              *services.GarbageCollector
+	sessions        *services.SessionManager
+	apiKeys         *services.APIKeyManager
+	passwordPolicy  auth.PasswordPolicy
 }
 
 // NewAdminHandler creates a new admin handler
-func NewAdminHandler(db *database.DB, ytDownloader *services.YouTubeDownloader, videoService *services.VideoService) *AdminHandler {
+func NewAdminHandler(db AdminStore, ytDownloader *services.YouTubeDownloader, fileIngestor *services.FileIngestor, uploadStore *services.UploadStore, videoService *services.VideoService, jobQueue *services.JobQueue, subtitles *services.SubtitleService, deleter *services.MediaDeleter, gc *services.GarbageCollector, sessions *services.SessionManager, apiKeys *services.APIKeyManager, passwordPolicy auth.PasswordPolicy) *AdminHandler {
 	return &AdminHandler{
 		db:              db,
 		ytDownloader:    ytDownloader,
+		fileIngestor:    fileIngestor,
+		uploadStore:     uploadStore,
 		videoService:    videoService,
-		sessionDuration: 24 * time.Hour, // Admin sessions last 24 hours
+		jobQueue:        jobQueue,
+		subtitles:       subtitles,
+		deleter:         deleter,
+		gc:              gc,
+		sessions:        sessions,
+		apiKeys:         apiKeys,
+		passwordPolicy:  passwordPolicy,
 	}
 }
 
 // DeleteVideoHandler handles requests to delete a video from a channel
 func (h *AdminHandler) DeleteVideoHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Verify admin authentication
-		userID, ok := h.isAuthenticated(r)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is admin
-		isAdmin, err := h.db.IsUserAdmin(userID)
-		if err != nil || !isAdmin {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-
 		// Parse request body
 		var req VideoDeleteRequest
 		decoder := json.NewDecoder(r.Body)
@@ -99,14 +127,25 @@ func (h *AdminHandler) DeleteVideoHandler() http.HandlerFunc {
 			http.Error(w, "Failed to fetch video information: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !allowChannel(w, r, auth.DeleteMedia, video.ChannelID) {
+			return
+		}
 
 		log.Printf("Deleting video: %s, channel: %d, position: %d", req.VideoID, video.ChannelID, video.DisplayOrder)
 
-		// Delete the video from S3 first
-		err = h.videoService.DeleteVideo(video.ChannelID, req.VideoID, video.DisplayOrder)
-		if err != nil {
-			log.Printf("Warning: Error deleting video from S3: %v. Will continue with database deletion.", err)
-			// Continue even if S3 deletion fails
+		// Delete the media from S3 first, unless something else still airs it
+		report := services.NewDeletionReport()
+		shared, err := h.db.CountVideosByS3Key(video.S3Key, video.ID)
+		switch {
+		case err != nil:
+			log.Printf("Warning: Cannot tell whether video %s shares its media, keeping it: %v", req.VideoID, err)
+			report.Kept = "could not check whether other videos use the media"
+		case video.AssetID != "":
+			report.Kept = "media belongs to library asset " + video.AssetID
+		case video.S3Key != "" && shared > 0:
+			report.Kept = fmt.Sprintf("media is used by %d other videos", shared)
+		default:
+			h.deleter.DeleteMedia(r.Context(), keys.PreviewID(video.S3Key, video.ID), video.S3Key, report)
 		}
 
 		// Delete the video from the database
@@ -124,33 +163,32 @@ func (h *AdminHandler) DeleteVideoHandler() http.HandlerFunc {
 			// Continue even if reordering fails
 		}
 
-		// Return success response
+		// The video is gone either way; success tells whether its media is too
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-			"message": "Video deleted successfully from S3 and database",
+			"success": len(report.Failed) == 0,
+			"message": deletionMessage(report),
+			"deleted": report,
 		})
 	}
 }
 
+// deletionMessage says what deleting a video did, from its deletion report
+func deletionMessage(report *services.DeletionReport) string {
+	switch {
+	case len(report.Failed) > 0:
+		return fmt.Sprintf("Video removed from the playlist, but %d stored files could not be deleted", len(report.Failed))
+	case report.Kept != "":
+		return "Video removed from the playlist; media kept because " + report.Kept
+	default:
+		return "Video and its media deleted"
+	}
+}
+
 // UpdateVideoOrderHandler handles requests to update the display order of videos in a channel
 func (h *AdminHandler) UpdateVideoOrderHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Verify admin authentication
-		userID, ok := h.isAuthenticated(r)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is admin
-		isAdmin, err := h.db.IsUserAdmin(userID)
-		if err != nil || !isAdmin {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-
 		// Parse request body
 		var req VideoOrderRequest
 		decoder := json.NewDecoder(r.Body)
@@ -169,9 +207,16 @@ func (h *AdminHandler) UpdateVideoOrderHandler() http.HandlerFunc {
 			http.Error(w, "Video orders are required", http.StatusBadRequest)
 			return
 		}
+		if !allowChannel(w, r, auth.EditSchedule, req.ChannelNumber) {
+			return
+		}
 
 		// Update the video order
-		err = h.db.UpdateVideoOrder(req.ChannelNumber, req.VideoOrders)
+		err := h.db.UpdateVideoOrder(req.ChannelNumber, req.VideoOrders)
+		if errors.Is(err, database.ErrVideoNotOnChannel) {
+			http.Error(w, "Video orders may only name videos on this channel", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			log.Printf("Error updating video order for channel %d: %v", req.ChannelNumber, err)
 			http.Error(w, "Failed to update video order: "+err.Error(), http.StatusInternalServerError)
@@ -195,19 +240,7 @@ func (h *AdminHandler) UpdateVideoOrderHandler() http.HandlerFunc {
 func (h *AdminHandler) UploadVideoHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println("[UploadVideoHandler] Received request to upload video")
-		// Verify admin authentication
-		userID, ok := h.isAuthenticated(r)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is admin
-		isAdmin, err := h.db.IsUserAdmin(userID)
-		if err != nil || !isAdmin {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
+		userID := principalFrom(r).UserID
 
 		// Parse request body
 		var req AdminVideoRequest
@@ -217,12 +250,32 @@ func (h *AdminHandler) UploadVideoHandler() http.HandlerFunc {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		log.Printf("[UploadVideoHandler] Parsed request: YoutubeURL=%s ChannelNumber=%d", req.YoutubeURL, req.ChannelNumber)
+		if req.SourceURL == "" {
+			req.SourceURL = req.YoutubeURL
+		}
+		log.Printf("[UploadVideoHandler] Parsed request: SourceURL=%s ChannelNumber=%d Options=%+v", req.SourceURL, req.ChannelNumber, req.Options)
 
 		// Validate request
-		if req.YoutubeURL == "" {
-			log.Println("[UploadVideoHandler] Missing YouTube URL in request body")
-			http.Error(w, "YouTube URL is required", http.StatusBadRequest)
+		if req.SourceURL == "" {
+			log.Println("[UploadVideoHandler] Missing source URL in request body")
+			http.Error(w, "Source URL is required", http.StatusBadRequest)
+			return
+		}
+		if req.Options.MaxHeight < 0 || req.Options.Limit < 0 {
+			http.Error(w, "Invalid import options", http.StatusBadRequest)
+			return
+		}
+		if (req.Options.DateAfter != "" && !importDatePattern.MatchString(req.Options.DateAfter)) ||
+			(req.Options.DateBefore != "" && !importDatePattern.MatchString(req.Options.DateBefore)) {
+			http.Error(w, "Dates must be formatted as YYYYMMDD", http.StatusBadRequest)
+			return
+		}
+		if req.Options.SubtitleLanguages != "" && !importSubLangsPattern.MatchString(req.Options.SubtitleLanguages) {
+			http.Error(w, "Subtitle languages must be a comma-separated list such as en,es.*", http.StatusBadRequest)
+			return
+		}
+		if req.Options.Duplicates != "" && !models.ValidDuplicatePolicy(req.Options.Duplicates) {
+			http.Error(w, "Duplicates must be reject, link or allow", http.StatusBadRequest)
 			return
 		}
 		if req.ChannelNumber < 1 || req.ChannelNumber > 5 {
@@ -230,25 +283,32 @@ func (h *AdminHandler) UploadVideoHandler() http.HandlerFunc {
 			http.Error(w, "Invalid channel number", http.StatusBadRequest)
 			return
 		}
+		if !allowChannel(w, r, auth.IngestMedia, req.ChannelNumber) {
+			return
+		}
 		log.Println("[UploadVideoHandler] Request validated")
 
-		// Process the video
-		log.Printf("[UploadVideoHandler] Starting video processing for YouTube URL: %s", req.YoutubeURL)
-		videoID, err := h.ytDownloader.ProcessVideo(req.YoutubeURL, req.ChannelNumber, userID)
+		// Queue a job that expands the source into one job per new entry
+		log.Printf("[UploadVideoHandler] Starting import for source URL: %s", req.SourceURL)
+		job, err := h.ytDownloader.Import(req.SourceURL, req.ChannelNumber, userID, req.Options)
+		if errors.Is(err, services.ErrInvalidSourceURL) {
+			http.Error(w, "Source URL must be an http(s) URL", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
-			log.Printf("[UploadVideoHandler] Error processing video: %v", err)
+			log.Printf("[UploadVideoHandler] Error queueing import: %v", err)
 			http.Error(w, "Failed to process video: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		log.Printf("[UploadVideoHandler] Video processing started successfully. VideoID: %s", videoID)
+		log.Printf("[UploadVideoHandler] Import queued as job %s", job.ID)
 
-		// Return success response
+		// Return success response; the job's videos appear on the channel as it runs
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
-			"message": "Video processing has been queued",
-			"videoId": videoID,
+			"message": "Import queued for processing",
+			"jobId":   job.ID,
 		})
 		log.Println("[UploadVideoHandler] Success response sent to client")
 	}
@@ -257,20 +317,6 @@ func (h *AdminHandler) UploadVideoHandler() http.HandlerFunc {
 // GetChannelVideosHandler returns the list of videos for a channel
 func (h *AdminHandler) GetChannelVideosHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Verify admin authentication
-		userID, ok := h.isAuthenticated(r)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is admin
-		isAdmin, err := h.db.IsUserAdmin(userID)
-		if err != nil || !isAdmin {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-
 		// Get channel ID from query parameter
 		channelIDStr := r.URL.Query().Get("channel")
 		if channelIDStr == "" {
@@ -283,6 +329,9 @@ func (h *AdminHandler) GetChannelVideosHandler() http.HandlerFunc {
 			http.Error(w, "Invalid channel ID", http.StatusBadRequest)
 			return
 		}
+		if !allowChannel(w, r, auth.ViewContent, channelID) {
+			return
+		}
 
 		// Get videos for the channel with detailed information
 		videos, err := h.db.GetChannelVideosWithDetails(channelID)
@@ -322,30 +371,24 @@ func (h *AdminHandler) LoginHandler() http.HandlerFunc {
 			return
 		}
 
-		// Check if user is admin
-		if user.Role != "admin" {
+		// Only enabled users with a known role may sign in
+		if user.Disabled {
+			http.Error(w, "Account disabled", http.StatusForbidden)
+			return
+		}
+		if !auth.ValidRole(user.Role) {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
-		// Set session cookie
-		sessionToken := generateSessionToken()
-		expiry := time.Now().Add(h.sessionDuration)
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_token",
-			Value:    sessionToken,
-			Expires:  expiry,
-			HttpOnly: true,
-			Path:     "/",
-			SameSite: http.SameSiteStrictMode,
-			Secure:   r.TLS != nil, // Set Secure flag in production
-		})
-
-		// Store session in memory (in a real app, you'd use Redis or similar)
-		sessions[sessionToken] = Session{
-			UserID:    user.ID,
-			ExpiresAt: expiry,
+		// Start a session and set its cookie
+		session, err := h.sessions.Create(user.ID)
+		if err != nil {
+			log.Printf("Error creating session: %v", err)
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
 		}
+		setSessionCookie(w, r, session)
 
 		// Return success
 		w.Header().Set("Content-Type", "application/json")
@@ -356,92 +399,92 @@ func (h *AdminHandler) LoginHandler() http.HandlerFunc {
 				"username": user.Username,
 				"role":     user.Role,
 			},
+			"mustChangePassword": user.MustChangePassword,
 		})
 	}
 }
 
-// VerifyAdminHandler checks if the current user is an admin
+// VerifyAdminHandler tells the dashboard who is signed in and what they may do
 func (h *AdminHandler) VerifyAdminHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Verify admin authentication
-		userID, ok := h.isAuthenticated(r)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is admin
-		isAdmin, err := h.db.IsUserAdmin(userID)
-		if err != nil || !isAdmin {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
+		principal := principalFrom(r)
 
-		// User is authenticated and is an admin
+		// The dashboard stays closed until a required password change is made
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": true,
-			"isAdmin": true,
+			"success":            true,
+			"isAdmin":            principal.Can(auth.ViewContent) && !principal.MustChangePassword,
+			"role":               principal.Role,
+			"channels":           principal.Channels,
+			"permissions":        principal.Permissions(),
+			"mustChangePassword": principal.MustChangePassword,
 		})
 	}
 }
 
-// Simple in-memory session storage (use Redis or similar in production)
-var sessions = make(map[string]Session)
-
-// Session represents a user session
-type Session struct {
-	UserID    string
-	ExpiresAt time.Time
-}
-
 // isAuthenticated checks if the request is authenticated
 func (h *AdminHandler) isAuthenticated(r *http.Request) (string, bool) {
-	// Get session token from cookie
-	cookie, err := r.Cookie("session_token")
-	if err != nil {
-		return "", false
-	}
-
-	sessionToken := cookie.Value
-	session, exists := sessions[sessionToken]
-	if !exists {
+	session := h.requestSession(r)
+	if session == nil {
 		return "", false
 	}
-
-	// Check if session has expired
-	if time.Now().After(session.ExpiresAt) {
-		delete(sessions, sessionToken)
-		return "", false
-	}
-
 	return session.UserID, true
 }
 
-// generateSessionToken generates a random session token
-func generateSessionToken() string {
-	// In a real app, use a secure crypto random method
-	return "session_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+// IsAdminRequest reports whether the request is signed in as a user who may use the
+// admin dashboard
+func (h *AdminHandler) IsAdminRequest(r *http.Request) bool {
+	principal, err := h.requestPrincipal(r)
+	if err != nil {
+		log.Printf("Error loading user for request: %v", err)
+		return false
+	}
+	return principal != nil && !principal.MustChangePassword && principal.Can(auth.ViewContent)
 }
 
-// ThumbnailHandler serves thumbnail images from S3
+// ThumbnailHandler serves thumbnails, preview sprites and sprite tracks from S3.
+// Images redirect to a pre-signed URL; WebVTT tracks are proxied so <track> elements
+// can load them without cross-origin setup on the bucket.
 func (h *AdminHandler) ThumbnailHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract the thumbnail name from the URL path
 		thumbnailPath := r.URL.Path
-		thumbnailKey := strings.TrimPrefix(thumbnailPath, "/api/thumbnails/")
-		
-		if thumbnailKey == "" {
+		thumbnailKey := strings.TrimPrefix(thumbnailPath, "/api"+keys.ThumbnailURLPrefix)
+
+		// Map the file name to its S3 key
+		// e.g. thumbnail_{videoID}.jpg, sprite_{videoID}.jpg, sprite_{videoID}.vtt
+		s3ThumbnailKey, ok := keys.PreviewKey(thumbnailKey)
+		if !ok {
 			http.Error(w, "Thumbnail key is required", http.StatusBadRequest)
 			return
 		}
-
-		// Construct the complete S3 key for the thumbnail
-		// The thumbnailKey is already in the correct format: thumbnail_{videoID}.jpg
-		s3ThumbnailKey := "thumbnails/" + thumbnailKey
 		
-		log.Printf("Fetching thumbnail from S3: %s", s3ThumbnailKey)
+		// Only hand out URLs for objects that exist
+		exists, err := h.videoService.ObjectExists(r.Context(), s3ThumbnailKey)
+		if err != nil {
+			log.Printf("Error checking thumbnail %s: %v", s3ThumbnailKey, err)
+			http.Error(w, "Failed to load thumbnail", http.StatusBadGateway)
+			return
+		}
+		if !exists {
+			http.Error(w, "Thumbnail not found", http.StatusNotFound)
+			return
+		}
+
+		if strings.HasSuffix(thumbnailKey, ".vtt") {
+			body, err := h.videoService.OpenObject(r.Context(), s3ThumbnailKey)
+			if err != nil {
+				log.Printf("Error fetching sprite track %s: %v", s3ThumbnailKey, err)
+				http.Error(w, "Failed to load thumbnail track", http.StatusBadGateway)
+				return
+			}
+			defer body.Close()
+			w.Header().Set("Content-Type", "text/vtt")
+			w.Header().Set("Cache-Control", "public, max-age=300")
+			io.Copy(w, body)
+			return
+		}
 		
 		// Get a pre-signed URL for the thumbnail
 		presignedURL, err := h.videoService.GetThumbnailURL(s3ThumbnailKey)
@@ -459,20 +502,6 @@ func (h *AdminHandler) ThumbnailHandler() http.HandlerFunc {
 // GetChannelDetailsHandler returns the details for a specific channel
 func (h *AdminHandler) GetChannelDetailsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Verify admin authentication
-		userID, ok := h.isAuthenticated(r)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is admin
-		isAdmin, err := h.db.IsUserAdmin(userID)
-		if err != nil || !isAdmin {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-
 		// Get channel ID from query parameter
 		channelIDStr := r.URL.Query().Get("channel")
 		if channelIDStr == "" {
@@ -485,6 +514,9 @@ func (h *AdminHandler) GetChannelDetailsHandler() http.HandlerFunc {
 			http.Error(w, "Invalid channel ID", http.StatusBadRequest)
 			return
 		}
+		if !allowChannel(w, r, auth.ViewContent, channelID) {
+			return
+		}
 
 		// For now, use the predefined channels from the models package
 		// In a real app, this would come from the database
@@ -514,20 +546,6 @@ func (h *AdminHandler) GetChannelDetailsHandler() http.HandlerFunc {
 // UpdateChannelDetailsHandler updates the details for a specific channel
 func (h *AdminHandler) UpdateChannelDetailsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Verify admin authentication
-		userID, ok := h.isAuthenticated(r)
-		if !ok {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Check if user is admin
-		isAdmin, err := h.db.IsUserAdmin(userID)
-		if err != nil || !isAdmin {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-
 		// Get channel ID from path parameter
 		// Expected URL format: /api/admin/channel/{channelID}
 		parts := strings.Split(r.URL.Path, "/")
@@ -542,6 +560,9 @@ func (h *AdminHandler) UpdateChannelDetailsHandler() http.HandlerFunc {
 			http.Error(w, "Invalid channel ID", http.StatusBadRequest)
 			return
 		}
+		if !allowChannel(w, r, auth.ManageChannels, channelID) {
+			return
+		}
 
 		// Parse request body
 		var req ChannelUpdateRequest
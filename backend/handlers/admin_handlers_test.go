@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/services"
+)
+
+func TestUpdateVideoOrderScopedToChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel int    // in the request
+		video   string // whose order is sent
+		want    int
+	}{
+		{name: "own channel", channel: 1, video: "news", want: http.StatusOK},
+		{name: "other channel's video", channel: 1, video: "sports", want: http.StatusBadRequest},
+		{name: "unknown video", channel: 1, video: "missing", want: http.StatusBadRequest},
+		{name: "other channel", channel: 3, video: "sports", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandler(t)
+			for id, channel := range map[string]int{"news": 1, "sports": 3} {
+				video := &models.AdminVideo{ID: id, ChannelID: channel, Title: id, Status: models.StatusCompleted, DisplayOrder: 1}
+				if err := db.SaveVideo(video); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, token := addUser(t, h, db, models.User{Username: "editor", Role: auth.RoleProgrammer, Channels: []int{1}})
+
+			body := fmt.Sprintf(`{"channelNumber":%d,"videoOrders":{%q:5}}`, tt.channel, tt.video)
+			req := withSession(httptest.NewRequest(http.MethodPost, "/update-video-order", strings.NewReader(body)), token)
+			rec := httptest.NewRecorder()
+			h.Require(auth.EditSchedule, h.UpdateVideoOrderHandler())(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+
+			// Only a permitted change reorders anything
+			for id, want := range map[string]int{"news": 1, "sports": 1} {
+				if tt.want == http.StatusOK && id == tt.video {
+					want = 5
+				}
+				video, err := db.GetVideoByID(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if video.DisplayOrder != want {
+					t.Errorf("%s display order = %d, want %d", id, video.DisplayOrder, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteVideoMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		inLibrary   bool
+		sharedWith  string // another video using the same object
+		wantMessage string
+	}{
+		{name: "library entry", inLibrary: true, wantMessage: "Video removed from the playlist; media kept because media belongs to library asset clip"},
+		{name: "shared object", sharedWith: "copy", wantMessage: "Video removed from the playlist; media kept because media is used by 1 other videos"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandler(t)
+			video := &models.AdminVideo{ID: "clip", ChannelID: 1, Title: "Clip", S3Key: "videos/clip.mp4", Status: models.StatusCompleted}
+			if err := db.SaveVideo(video); err != nil {
+				t.Fatal(err)
+			}
+			if tt.inLibrary {
+				if err := db.CreateAssetFromVideo(video.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.sharedWith != "" {
+				copy := &models.AdminVideo{ID: tt.sharedWith, ChannelID: 2, Title: "Clip", S3Key: video.S3Key, Status: models.StatusCompleted}
+				if err := db.SaveVideo(copy); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, token := addUser(t, h, db, models.User{Username: "editor", Role: auth.RoleProgrammer})
+
+			req := withSession(httptest.NewRequest(http.MethodPost, "/delete-video", strings.NewReader(`{"videoId":"clip"}`)), token)
+			rec := httptest.NewRecorder()
+			h.Require(auth.DeleteMedia, h.DeleteVideoHandler())(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				Success bool   `json:"success"`
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if !resp.Success || resp.Message != tt.wantMessage {
+				t.Errorf("got success=%t %q, want %q", resp.Success, resp.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestDeletionMessageReportsFailures(t *testing.T) {
+	report := services.NewDeletionReport()
+	report.Failed = map[string]string{"videos/clip.mp4": "access denied", "previews/clip.jpg": "access denied"}
+	want := "Video removed from the playlist, but 2 stored files could not be deleted"
+	if got := deletionMessage(report); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
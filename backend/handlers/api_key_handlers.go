@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxAPIKeyDays is the longest lifetime an API key can be created with
+const maxAPIKeyDays = 365
+
+// CreateAPIKeyRequest is the request body for creating an API key. Permissions and
+// channels must be ones the creating user holds; no channels means all of theirs.
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Permissions   []string `json:"permissions"`
+	Channels      []int    `json:"channels"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// ListAPIKeysHandler returns the caller's API keys, or everyone's for users who manage users
+func (h *AdminHandler) ListAPIKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := principalFrom(r)
+		owner := principal.UserID
+		if principal.Can(auth.ManageUsers) {
+			owner = ""
+		}
+
+		keys, err := h.apiKeys.List(owner)
+		if err != nil {
+			log.Printf("Error listing API keys: %v", err)
+			http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"apiKeys": keys,
+		})
+	}
+}
+
+// CreateAPIKeyHandler issues an API key acting for the signed-in user. The secret is in
+// the response only; keys cannot be used to create further keys.
+func (h *AdminHandler) CreateAPIKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := principalFrom(r)
+		if principal.APIKeyID != "" {
+			http.Error(w, "API keys cannot create API keys; sign in instead", http.StatusForbidden)
+			return
+		}
+
+		var req CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Validate request
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+		if len(req.Permissions) == 0 {
+			http.Error(w, "At least one permission is required", http.StatusBadRequest)
+			return
+		}
+		for _, perm := range req.Permissions {
+			if !auth.ValidPermission(auth.Permission(perm)) {
+				http.Error(w, "Unknown permission: "+perm, http.StatusBadRequest)
+				return
+			}
+			if !principal.Can(auth.Permission(perm)) {
+				http.Error(w, "You do not hold permission "+perm, http.StatusForbidden)
+				return
+			}
+		}
+		if !h.validChannels(req.Channels) {
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+		for _, channel := range req.Channels {
+			if !principal.CanOnChannel(auth.ViewContent, channel) {
+				http.Error(w, "You do not have access to every requested channel", http.StatusForbidden)
+				return
+			}
+		}
+		if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPIKeyDays {
+			http.Error(w, "expiresInDays must be between 1 and 365, or 0 for the default", http.StatusBadRequest)
+			return
+		}
+
+		key := &models.APIKey{
+			Name:        req.Name,
+			UserID:      principal.UserID,
+			Permissions: req.Permissions,
+			Channels:    req.Channels,
+		}
+		secret, err := h.apiKeys.Create(key, time.Duration(req.ExpiresInDays)*24*time.Hour)
+		if err != nil {
+			log.Printf("Error creating API key for user %s: %v", principal.UserID, err)
+			http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("API key %s (%s) created by %s with %v on channels %v", key.ID, key.Name, principal.Username, key.Permissions, key.Channels)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"key":     secret,
+			"apiKey":  key,
+		})
+	}
+}
+
+// RevokeAPIKeyHandler stops one of the caller's API keys, or anyone's for users who
+// manage users, from working
+func (h *AdminHandler) RevokeAPIKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := principalFrom(r)
+		if principal.APIKeyID != "" {
+			http.Error(w, "API keys cannot revoke API keys; sign in instead", http.StatusForbidden)
+			return
+		}
+
+		keyID := mux.Vars(r)["keyID"]
+		key, err := h.apiKeys.Get(keyID)
+		if err == sql.ErrNoRows || (err == nil && key.UserID != principal.UserID && !principal.Can(auth.ManageUsers)) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading API key %s: %v", keyID, err)
+			http.Error(w, "Failed to load API key", http.StatusInternalServerError)
+			return
+		}
+
+		if err := h.apiKeys.Revoke(keyID); err == sql.ErrNoRows {
+			http.Error(w, "API key is already revoked", http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Printf("Error revoking API key %s: %v", keyID, err)
+			http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("API key %s (%s) revoked by %s", key.ID, key.Name, principal.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type principalContextKey struct{}
+
+// requestPrincipal returns who the request acts as, or nil when it carries neither a
+// session nor an API key of an enabled user. An "Authorization: Bearer" API key takes
+// precedence over the session cookie.
+func (h *AdminHandler) requestPrincipal(r *http.Request) (*auth.Principal, error) {
+	if principal, ok := r.Context().Value(principalContextKey{}).(*auth.Principal); ok {
+		return principal, nil
+	}
+
+	if secret, ok := bearerToken(r); ok {
+		key, err := h.apiKeys.Lookup(secret)
+		if err != nil || key == nil {
+			return nil, err
+		}
+		user, err := h.enabledUser(key.UserID)
+		if err != nil || user == nil {
+			return nil, err
+		}
+		return auth.ForAPIKey(user, key), nil
+	}
+
+	session := h.requestSession(r)
+	if session == nil {
+		return nil, nil
+	}
+	user, err := h.enabledUser(session.UserID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return auth.ForUser(user), nil
+}
+
+// enabledUser returns a user, or nil when they are gone or disabled
+func (h *AdminHandler) enabledUser(userID string) (*models.User, error) {
+	user, err := h.db.GetUser(userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// bearerToken returns the credential of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// authenticate answers 401 and returns nil unless the request is signed in
+func (h *AdminHandler) authenticate(w http.ResponseWriter, r *http.Request) *auth.Principal {
+	principal, err := h.requestPrincipal(r)
+	if err != nil {
+		log.Printf("Error loading user for request: %v", err)
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return nil
+	}
+	if principal == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	return principal
+}
+
+// authorize answers 401 or 403 and returns nil unless the request may use perm on at
+// least one channel
+func (h *AdminHandler) authorize(w http.ResponseWriter, r *http.Request, perm auth.Permission) *auth.Principal {
+	principal := h.authenticate(w, r)
+	if principal == nil {
+		return nil
+	}
+	if principal.MustChangePassword {
+		http.Error(w, "Password change required", http.StatusForbidden)
+		return nil
+	}
+	if !principal.Can(perm) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil
+	}
+	return principal
+}
+
+// Require wraps an admin handler so it only runs for requests allowed to use perm. The
+// handler finds the principal with principalFrom and checks channels with allowChannel.
+func (h *AdminHandler) Require(perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := h.authorize(w, r, perm)
+		if principal == nil {
+			return
+		}
+		next(w, withPrincipal(r, principal))
+	}
+}
+
+// RequireSignedIn wraps a handler any signed-in user may use, including one who must
+// change their password first
+func (h *AdminHandler) RequireSignedIn(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := h.authenticate(w, r)
+		if principal == nil {
+			return
+		}
+		next(w, withPrincipal(r, principal))
+	}
+}
+
+// withPrincipal stores the principal a request acts as for principalFrom
+func withPrincipal(r *http.Request, principal *auth.Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal))
+}
+
+// principalFrom returns the principal Require stored on the request
+func principalFrom(r *http.Request) *auth.Principal {
+	principal, _ := r.Context().Value(principalContextKey{}).(*auth.Principal)
+	return principal
+}
+
+// allowChannel answers 403 and returns false unless the request may use perm on channel
+func allowChannel(w http.ResponseWriter, r *http.Request, perm auth.Permission, channel int) bool {
+	principal := principalFrom(r)
+	if principal == nil || !principal.CanOnChannel(perm, channel) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+)
+
+func TestRequireBearerAPIKey(t *testing.T) {
+	view := []string{string(auth.ViewContent)}
+	tests := []struct {
+		name        string
+		user        models.User
+		permissions []string // on the key
+		perm        auth.Permission
+		revoke      bool
+		secret      string // sent instead of the key's secret when set
+		want        int
+	}{
+		{name: "live key", user: models.User{Role: auth.RoleUploader}, permissions: view, perm: auth.ViewContent, want: http.StatusOK},
+		{name: "unknown secret", user: models.User{Role: auth.RoleUploader}, permissions: view, perm: auth.ViewContent, secret: "lbk_unknown", want: http.StatusUnauthorized},
+		{name: "revoked key", user: models.User{Role: auth.RoleUploader}, permissions: view, perm: auth.ViewContent, revoke: true, want: http.StatusUnauthorized},
+		{name: "disabled user", user: models.User{Role: auth.RoleUploader, Disabled: true}, permissions: view, perm: auth.ViewContent, want: http.StatusUnauthorized},
+		{name: "password change pending", user: models.User{Role: auth.RoleUploader, MustChangePassword: true}, permissions: view, perm: auth.ViewContent, want: http.StatusForbidden},
+		{name: "permission not on key", user: models.User{Role: auth.RoleOwner}, permissions: []string{string(auth.IngestMedia)}, perm: auth.ViewContent, want: http.StatusForbidden},
+		{name: "permission not in role", user: models.User{Role: auth.RoleUploader}, permissions: []string{string(auth.ManageUsers)}, perm: auth.ManageUsers, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandler(t)
+			tt.user.Username = "editor"
+			user, _ := addUser(t, h, db, tt.user)
+			key := &models.APIKey{Name: "ci", UserID: user.ID, Permissions: tt.permissions}
+			secret, err := h.apiKeys.Create(key, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.revoke {
+				if err := h.apiKeys.Revoke(key.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.secret != "" {
+				secret = tt.secret
+			}
+
+			handler := h.Require(tt.perm, func(w http.ResponseWriter, r *http.Request) {})
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/jobs", nil)
+			req.Header.Set("Authorization", "Bearer "+secret)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"live-broadcast-backend/models"
 	"live-broadcast-backend/state"
 	"net/http"
 	"strconv"
@@ -14,6 +17,18 @@ import (
 func SetupChannelRoutes(router *mux.Router, cm *state.ChannelManager) {
 	router.HandleFunc("/api/channels", GetChannelGuideHandler(cm)).Methods("GET")
 	router.HandleFunc("/api/channels/{number:[0-9]+}", GetChannelStateHandler(cm)).Methods("GET")
+	router.HandleFunc("/api/channels/{number:[0-9]+}/preview.jpg", ChannelPreviewHandler(cm)).Methods("GET", "HEAD")
+	router.HandleFunc("/api/channels/{number:[0-9]+}/manifest", ChannelManifestHandler(cm)).Methods("GET")
+}
+
+// ChannelManifest lists the renditions of what a channel is airing. Audio tracks are
+// alternates inside the one fMP4 stream, identified by their index among its audio tracks.
+type ChannelManifest struct {
+	ChannelNumber int                    `json:"channelNumber"`
+	StreamURL     string                 `json:"streamUrl"`
+	VideoID       string                 `json:"videoId"`
+	Audio         []models.AudioTrack    `json:"audio"`
+	Subtitles     []models.SubtitleTrack `json:"subtitles,omitempty"`
 }
 
 // ChannelStateResponse is the structure returned by the GetChannelStateHandler.
@@ -93,4 +108,60 @@ func GetChannelStateHandler(cm *state.ChannelManager) http.HandlerFunc {
 			return
 		}
 	}
-} 
\ No newline at end of file
+} 
+// ChannelPreviewHandler serves the latest still captured from a channel's live stream.
+// Clients may cache it until the next capture is due; conditional requests get a 304.
+func ChannelPreviewHandler(cm *state.ChannelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelNumber, err := strconv.Atoi(mux.Vars(r)["number"])
+		if err != nil {
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+
+		preview := cm.GetChannelPreview(channelNumber)
+		if preview == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Error(w, "No preview available yet", http.StatusNotFound)
+			return
+		}
+
+		maxAge := int(cm.PreviewInterval().Seconds())
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+		w.Header().Set("ETag", fmt.Sprintf(`"%d-%d"`, channelNumber, preview.CapturedAt.UnixNano()))
+		http.ServeContent(w, r, "preview.jpg", preview.CapturedAt, bytes.NewReader(preview.Image))
+	}
+}
+
+// ChannelManifestHandler returns the rendition manifest for a channel's current programme
+func ChannelManifestHandler(cm *state.ChannelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelNumber, err := strconv.Atoi(mux.Vars(r)["number"])
+		if err != nil {
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+		state, err := cm.GetChannelState(channelNumber)
+		if err != nil || state.CurrentVideo == nil {
+			http.Error(w, "Channel is currently offline", http.StatusNotFound)
+			return
+		}
+
+		manifest := ChannelManifest{
+			ChannelNumber: channelNumber,
+			StreamURL:     fmt.Sprintf("/live/%d", channelNumber),
+			VideoID:       state.CurrentVideo.ID,
+			Audio:         state.CurrentVideo.AudioTracks,
+			Subtitles:     state.CurrentVideo.Subtitles,
+		}
+		if len(manifest.Audio) == 0 {
+			// Videos ingested before audio renditions were recorded have one default track
+			manifest.Audio = []models.AudioTrack{{Default: true}}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		json.NewEncoder(w).Encode(manifest)
+	}
+}
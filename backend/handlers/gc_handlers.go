@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// StorageOrphansHandler reports the objects and cached files garbage collection would
+// delete, without deleting anything, together with the last scheduled run
+func (h *AdminHandler) StorageOrphansHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := h.gc.Run(r.Context(), true)
+		if err != nil {
+			log.Printf("Error scanning storage for orphans: %v", err)
+			http.Error(w, "Failed to scan storage", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"report":  report,
+			"lastRun": h.gc.LastReport(),
+		})
+	}
+}
+
+// RunStorageGCHandler runs garbage collection now, deleting orphans older than the
+// grace period
+func (h *AdminHandler) RunStorageGCHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		log.Printf("Storage garbage collection started by %s", userID)
+		report, err := h.gc.Run(r.Context(), false)
+		if err != nil {
+			log.Printf("Error collecting storage garbage: %v", err)
+			http.Error(w, "Failed to collect storage garbage", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"report": report,
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"live-broadcast-backend/services"
+)
+
+// newTestHandler returns an admin handler on an in-memory store with the predefined
+// channels. Services the tests do not reach are nil.
+func newTestHandler(t *testing.T) (*AdminHandler, *repository.Memory) {
+	t.Helper()
+	db := repository.NewMemory()
+	if err := db.CreateDefaultChannels(); err != nil {
+		t.Fatal(err)
+	}
+	h := NewAdminHandler(db, nil, nil, nil, nil,
+		services.NewJobQueue(db, db, services.NewProgressHub(db), 1, 3), nil, nil, nil,
+		services.NewSessionManager(db, time.Hour), services.NewAPIKeyManager(db, time.Hour),
+		auth.PasswordPolicy{MinLength: 12})
+	return h, db
+}
+
+// addUser stores a user and returns them with a session token
+func addUser(t *testing.T, h *AdminHandler, db *repository.Memory, user models.User) (*models.User, string) {
+	t.Helper()
+	user.Email = user.Username + "@tvstream.example"
+	stored, err := db.AddUser(user, "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := h.sessions.Create(stored.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored, session.Token
+}
+
+// withSession signs a request in with a session token
+func withSession(r *http.Request, token string) *http.Request {
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+	return r
+}
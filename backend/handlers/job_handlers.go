@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ListJobsHandler returns recent ingest jobs, optionally filtered by ?status=
+func (h *AdminHandler) ListJobsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := models.JobStatus(r.URL.Query().Get("status"))
+		limit := 100
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			var err error
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 || limit > 1000 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Users limited to some channels only see those channels' jobs
+		jobs, err := h.jobQueue.List(status, principalFrom(r).Channels, limit)
+		if err != nil {
+			log.Printf("Error listing ingest jobs: %v", err)
+			http.Error(w, "Failed to list jobs", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jobs": jobs,
+		})
+	}
+}
+
+// RetryJobHandler requeues a failed or cancelled ingest job
+func (h *AdminHandler) RetryJobHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := mux.Vars(r)["jobID"]
+		existing, err := h.db.GetJob(jobID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading job %s: %v", jobID, err)
+			http.Error(w, "Failed to load job", http.StatusInternalServerError)
+			return
+		}
+		if !allowChannel(w, r, auth.ManageJobs, existing.ChannelID) {
+			return
+		}
+
+		job, err := h.jobQueue.Retry(jobID)
+		if err != nil {
+			log.Printf("Error retrying job %s: %v", jobID, err)
+			http.Error(w, "Failed to retry job: "+err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Job has been requeued",
+			"job":     job,
+		})
+	}
+}
+
+// CancelJobHandler cancels a queued or running ingest job
+func (h *AdminHandler) CancelJobHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := mux.Vars(r)["jobID"]
+		existing, err := h.db.GetJob(jobID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading job %s: %v", jobID, err)
+			http.Error(w, "Failed to load job", http.StatusInternalServerError)
+			return
+		}
+		if !allowChannel(w, r, auth.ManageJobs, existing.ChannelID) {
+			return
+		}
+
+		job, err := h.jobQueue.Cancel(jobID)
+		if err != nil {
+			log.Printf("Error cancelling job %s: %v", jobID, err)
+			http.Error(w, "Failed to cancel job: "+err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Job has been cancelled",
+			"job":     job,
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestListJobsByChannel(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []int // of the signed-in user
+		want     int   // jobs listed, at most the limit of 2
+	}{
+		{name: "all channels", channels: nil, want: 2},
+		{name: "oldest jobs' channel", channels: []int{1}, want: 2},
+		{name: "one job's channel", channels: []int{2}, want: 1},
+		{name: "channel without jobs", channels: []int{4}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandler(t)
+			// Newest first, so a limit applied before the channel filter would hide channel 1
+			created := time.Now().Add(-time.Hour)
+			for _, channel := range []int{1, 1, 2, 3} {
+				created = created.Add(time.Minute)
+				job := &models.IngestJob{Kind: models.JobKindHTTP, ChannelID: channel, VideoID: "v", CreatedAt: created}
+				if err := db.CreateJob(job); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, token := addUser(t, h, db, models.User{Username: "editor", Role: auth.RoleUploader, Channels: tt.channels})
+
+			rec := httptest.NewRecorder()
+			h.Require(auth.ViewContent, h.ListJobsHandler())(rec, withSession(httptest.NewRequest(http.MethodGet, "/jobs?limit=2", nil), token))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				Jobs []models.IngestJob `json:"jobs"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Jobs) != tt.want {
+				t.Errorf("got %d jobs, want %d", len(resp.Jobs), tt.want)
+			}
+			for _, job := range resp.Jobs {
+				if tt.channels != nil && job.ChannelID != tt.channels[0] {
+					t.Errorf("got a job on channel %d", job.ChannelID)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobID   string // retried instead of the stored job when set
+		channel int
+		status  models.JobStatus
+		want    int
+	}{
+		{name: "failed job", channel: 1, status: models.JobFailed, want: http.StatusAccepted},
+		{name: "cancelled job", channel: 1, status: models.JobCancelled, want: http.StatusAccepted},
+		{name: "completed job", channel: 1, status: models.JobCompleted, want: http.StatusConflict},
+		{name: "other channel", channel: 2, status: models.JobFailed, want: http.StatusForbidden},
+		{name: "unknown job", jobID: "missing", channel: 1, status: models.JobFailed, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandler(t)
+			router := mux.NewRouter()
+			router.HandleFunc("/jobs/{jobID}/retry", h.Require(auth.ManageJobs, h.RetryJobHandler())).Methods("POST")
+
+			job := &models.IngestJob{Kind: models.JobKindExpand, ChannelID: tt.channel, Status: tt.status}
+			if err := db.CreateJob(job); err != nil {
+				t.Fatal(err)
+			}
+			_, token := addUser(t, h, db, models.User{Username: "editor", Role: auth.RoleUploader, Channels: []int{1}})
+
+			jobID := job.ID
+			if tt.jobID != "" {
+				jobID = tt.jobID
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, withSession(httptest.NewRequest(http.MethodPost, "/jobs/"+jobID+"/retry", nil), token))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+
+			if tt.want == http.StatusAccepted {
+				stored, err := db.GetJob(job.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored.Status != models.JobQueued {
+					t.Errorf("job is %s, want %s", stored.Status, models.JobQueued)
+				}
+			}
+		})
+	}
+}
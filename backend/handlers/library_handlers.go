@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/database"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/services"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// AddAssetRequest is the request body for scheduling a library asset on a channel
+type AddAssetRequest struct {
+	ChannelNumber int `json:"channelNumber"`
+}
+
+// ListLibraryHandler returns every asset in the media library with the channels airing it
+func (h *AdminHandler) ListLibraryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assets, err := h.db.ListMediaAssets()
+		if err != nil {
+			log.Printf("Error listing media library: %v", err)
+			http.Error(w, "Failed to list media library", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"assets": assets,
+		})
+	}
+}
+
+// GetLibraryAssetHandler returns one library asset
+func (h *AdminHandler) GetLibraryAssetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assetID := mux.Vars(r)["assetID"]
+		asset, err := h.db.GetMediaAsset(assetID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading media asset %s: %v", assetID, err)
+			http.Error(w, "Failed to load asset", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(asset)
+	}
+}
+
+// AddAssetToChannelHandler appends a library asset to a channel's playlist without
+// copying its media. The asset must have been transcoded to the channel's profile.
+func (h *AdminHandler) AddAssetToChannelHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		var req AddAssetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if _, err := h.db.GetChannel(req.ChannelNumber); err != nil {
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+		if !allowChannel(w, r, auth.EditSchedule, req.ChannelNumber) {
+			return
+		}
+
+		assetID := mux.Vars(r)["assetID"]
+		asset, err := h.db.GetMediaAsset(assetID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading media asset %s: %v", assetID, err)
+			http.Error(w, "Failed to load asset", http.StatusInternalServerError)
+			return
+		}
+
+		// Programmes on a channel share one MSE SourceBuffer, so the formats must match.
+		// Assets from before per-channel profiles have none recorded and are allowed.
+		profile, err := h.db.GetChannelProfile(req.ChannelNumber)
+		if err != nil {
+			log.Printf("Error loading transcode profile for channel %d: %v", req.ChannelNumber, err)
+			http.Error(w, "Failed to load channel profile", http.StatusInternalServerError)
+			return
+		}
+		if asset.Profile != nil && !asset.Profile.Equal(profile) {
+			http.Error(w, "Asset was transcoded for a different channel profile; import it again for this channel", http.StatusConflict)
+			return
+		}
+
+		video, err := h.db.AddAssetToChannel(assetID, req.ChannelNumber, userID)
+		if errors.Is(err, database.ErrAssetOnChannel) {
+			http.Error(w, "Asset is already on this channel", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Error adding asset %s to channel %d: %v", assetID, req.ChannelNumber, err)
+			http.Error(w, "Failed to add asset to channel", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Asset %s added to channel %d as video %s by %s", assetID, req.ChannelNumber, video.ID, userID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"video":   video,
+		})
+	}
+}
+
+// DeleteLibraryAssetHandler removes an asset from the library, takes it off every
+// channel airing it and deletes its media, previews and subtitles
+func (h *AdminHandler) DeleteLibraryAssetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		// Deleting takes the asset off every channel airing it, so users limited to some
+		// channels may only delete assets airing on nothing but their channels
+		assetID := mux.Vars(r)["assetID"]
+		asset, err := h.db.GetMediaAsset(assetID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading media asset %s: %v", assetID, err)
+			http.Error(w, "Failed to load asset", http.StatusInternalServerError)
+			return
+		}
+		if len(asset.Channels) == 0 && !principalFrom(r).AllChannels() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		for _, channel := range asset.Channels {
+			if !allowChannel(w, r, auth.DeleteMedia, channel) {
+				return
+			}
+		}
+
+		videoKeys, channels, err := h.db.DeleteMediaAsset(assetID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting media asset %s: %v", assetID, err)
+			http.Error(w, "Failed to delete asset", http.StatusInternalServerError)
+			return
+		}
+		for _, channel := range channels {
+			if err := h.db.ReorderVideosAfterDeletion(channel); err != nil {
+				log.Printf("Warning: Error reordering channel %d after deleting asset %s: %v", channel, assetID, err)
+			}
+		}
+
+		// Objects also used by videos outside the library stay
+		report := services.NewDeletionReport()
+		for _, key := range videoKeys {
+			users, err := h.db.CountVideosByS3Key(key, "")
+			if err != nil || users > 0 {
+				log.Printf("Keeping %s of deleted asset %s: still in use (%v)", key, assetID, err)
+				report.Kept = "media is still used by videos outside the library"
+				continue
+			}
+			h.deleter.DeleteMedia(r.Context(), keys.PreviewID(key, assetID), key, report)
+		}
+		log.Printf("Asset %s deleted from the library by %s", assetID, userID)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": len(report.Failed) == 0,
+			"deleted": report,
+		})
+	}
+}
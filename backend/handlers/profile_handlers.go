@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetChannelProfileHandler returns the transcode profile applied to videos ingested into a channel
+func (h *AdminHandler) GetChannelProfileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID, err := strconv.Atoi(mux.Vars(r)["channelID"])
+		if err != nil || channelID < 1 || channelID > 5 {
+			http.Error(w, "Invalid channel ID", http.StatusBadRequest)
+			return
+		}
+		if !allowChannel(w, r, auth.ViewContent, channelID) {
+			return
+		}
+
+		profile, err := h.db.GetChannelProfile(channelID)
+		if err != nil {
+			log.Printf("Error loading transcode profile for channel %d: %v", channelID, err)
+			http.Error(w, "Failed to load profile", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"channel": channelID,
+			"profile": profile,
+		})
+	}
+}
+
+// UpdateChannelProfileHandler replaces a channel's transcode profile. Videos already
+// ingested keep the profile recorded on their row until they are re-ingested.
+func (h *AdminHandler) UpdateChannelProfileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		channelID, err := strconv.Atoi(mux.Vars(r)["channelID"])
+		if err != nil || channelID < 1 || channelID > 5 {
+			http.Error(w, "Invalid channel ID", http.StatusBadRequest)
+			return
+		}
+		if !allowChannel(w, r, auth.ManageChannels, channelID) {
+			return
+		}
+
+		// Start from the defaults so clients may send a partial profile
+		profile := models.DefaultTranscodeProfile()
+		if err := json.NewDecoder(r.Body).Decode(profile); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := profile.Validate(); err != nil {
+			http.Error(w, "Invalid profile: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.db.UpdateChannelProfile(channelID, profile); err != nil {
+			log.Printf("Error saving transcode profile for channel %d: %v", channelID, err)
+			http.Error(w, "Failed to save profile", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Channel %d transcode profile updated by %s: %+v", channelID, userID, *profile)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"channel": channelID,
+			"profile": profile,
+		})
+	}
+}
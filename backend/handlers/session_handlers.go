@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"live-broadcast-backend/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+// sessionCookie names the cookie carrying the session token
+const sessionCookie = "session_token"
+
+type sessionContextKey struct{}
+
+// setSessionCookie sends the session's token with its current expiry
+func setSessionCookie(w http.ResponseWriter, r *http.Request, session *models.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.Token,
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil, // Set Secure flag in production
+	})
+}
+
+// clearSessionCookie tells the browser to drop the session cookie
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+	})
+}
+
+// requestSession returns the live session the request's cookie names, or nil
+func (h *AdminHandler) requestSession(r *http.Request) *models.Session {
+	if session, ok := r.Context().Value(sessionContextKey{}).(*models.Session); ok {
+		return session
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	session, err := h.sessions.Lookup(cookie.Value)
+	if err != nil {
+		log.Printf("Error looking up session: %v", err)
+		return nil
+	}
+	return session
+}
+
+// SessionMiddleware looks up the request's session once, renewing it and its cookie
+// when it is past half its lifetime, so handlers see it without another lookup
+func (h *AdminHandler) SessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := h.requestSession(r)
+		if session == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if renewed, err := h.sessions.Renew(session); err != nil {
+			log.Printf("Error renewing session: %v", err)
+		} else if renewed {
+			setSessionCookie(w, r, session)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session)))
+	})
+}
+
+// LogoutHandler ends the current session
+func (h *AdminHandler) LogoutHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if session := h.requestSession(r); session != nil {
+			if err := h.sessions.Revoke(session.Token); err != nil {
+				log.Printf("Error ending session: %v", err)
+				http.Error(w, "Failed to log out", http.StatusInternalServerError)
+				return
+			}
+		}
+		clearSessionCookie(w, r)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	}
+}
+
+// LogoutAllHandler ends every session of the current user, on all devices
+func (h *AdminHandler) LogoutAllHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.isAuthenticated(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ended, err := h.sessions.RevokeAll(userID)
+		if err != nil {
+			log.Printf("Error ending sessions of user %s: %v", userID, err)
+			http.Error(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+		clearSessionCookie(w, r)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"sessions": ended,
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"live-broadcast-backend/services"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// StorageHandler serves objects of a local storage to holders of a URL signed by its
+// PresignGet, the local counterpart of an S3 pre-signed URL. Range requests are supported.
+func StorageHandler(storage *services.LocalStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), services.LocalStorageURLPrefix))
+		if err != nil {
+			http.Error(w, "Invalid object key", http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		if !storage.Verify(key, query.Get("expires"), query.Get("signature")) {
+			http.Error(w, "Invalid or expired signature", http.StatusForbidden)
+			return
+		}
+
+		name, err := storage.Path(key)
+		if err != nil {
+			http.Error(w, "Invalid object key", http.StatusBadRequest)
+			return
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			http.Error(w, "Object not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			http.Error(w, "Object not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/services"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// subtitlePathPattern matches /api/subtitles/{videoID}/{language}.vtt
+var subtitlePathPattern = regexp.MustCompile(`^/api/subtitles/([0-9A-Za-z_-]+)/([A-Za-z0-9-]+)\.vtt$`)
+
+// UploadSubtitleHandler adds or replaces a video's subtitle track from an uploaded SRT or
+// WebVTT file. Form fields: file, language, label (optional), kind ("subtitles" or "captions").
+func (h *AdminHandler) UploadSubtitleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		videoID := mux.Vars(r)["videoID"]
+		video, err := h.db.GetVideoByID(videoID)
+		if err != nil {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if !allowChannel(w, r, auth.EditMedia, video.ChannelID) {
+			return
+		}
+		// Subtitles belong to the library asset, shared by every channel airing it
+		if video.AssetID != "" {
+			videoID = video.AssetID
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, services.MaxSubtitleBytes+64*1024)
+		if err := r.ParseMultipartForm(services.MaxSubtitleBytes); err != nil {
+			http.Error(w, "Expected a multipart/form-data body of at most 5 MB", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "A subtitle file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Failed to read subtitle file", http.StatusBadRequest)
+			return
+		}
+
+		track := models.SubtitleTrack{
+			Language: strings.TrimSpace(r.FormValue("language")),
+			Label:    strings.TrimSpace(r.FormValue("label")),
+			Kind:     r.FormValue("kind"),
+			Source:   models.SubtitleSourceUploaded,
+		}
+		if !services.ValidLanguage(track.Language) {
+			http.Error(w, "language must be a tag such as en or pt-BR", http.StatusBadRequest)
+			return
+		}
+
+		track, err = h.subtitles.Store(r.Context(), videoID, track, data)
+		if err != nil {
+			http.Error(w, "Invalid subtitle file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		tracks, err := h.subtitles.AddTracks(videoID, track)
+		if err != nil {
+			log.Printf("Error recording subtitles for video %s: %v", videoID, err)
+			http.Error(w, "Failed to save subtitles", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("%s subtitles uploaded for video %s by %s", track.Language, videoID, userID)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"track":     track,
+			"subtitles": tracks,
+		})
+	}
+}
+
+// DeleteSubtitleHandler removes a video's subtitle track in one language
+func (h *AdminHandler) DeleteSubtitleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		videoID := vars["videoID"]
+		video, err := h.db.GetVideoByID(videoID)
+		if err != nil {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if !allowChannel(w, r, auth.EditMedia, video.ChannelID) {
+			return
+		}
+		if video.AssetID != "" {
+			videoID = video.AssetID
+		}
+
+		tracks, err := h.subtitles.Remove(r.Context(), videoID, vars["language"])
+		if errors.Is(err, services.ErrSubtitleNotFound) {
+			http.Error(w, "Subtitle track not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error removing %s subtitles from video %s: %v", vars["language"], videoID, err)
+			http.Error(w, "Failed to remove subtitles", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"subtitles": tracks,
+		})
+	}
+}
+
+// SubtitleHandler serves a stored WebVTT track to players
+func (h *AdminHandler) SubtitleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := subtitlePathPattern.FindStringSubmatch(r.URL.Path)
+		if m == nil {
+			http.Error(w, "Invalid subtitle path", http.StatusBadRequest)
+			return
+		}
+		cues, err := h.subtitles.Cues(r.Context(), m[1], m[2])
+		if err != nil {
+			log.Printf("Error loading %s subtitles for video %s: %v", m[2], m[1], err)
+			http.Error(w, "Failed to load subtitles", http.StatusBadGateway)
+			return
+		}
+		if len(cues) == 0 {
+			http.Error(w, "Subtitles not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(services.FormatWebVTT(cues))
+	}
+}
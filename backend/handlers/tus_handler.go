@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/services"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tus 1.0 protocol constants (https://tus.io/protocols/resumable-upload)
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,checksum,termination"
+	tusAlgorithms = "sha1,sha256,md5"
+	tusUploadPath = "/api/admin/uploads/"
+
+	// statusChecksumMismatch is the tus checksum extension's "460 Checksum Mismatch"
+	statusChecksumMismatch = 460
+)
+
+// tusHashes maps Upload-Checksum algorithm names to hash constructors
+var tusHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"md5":    md5.New,
+}
+
+// TusHandler serves the tus resumable upload protocol under /api/admin/uploads/.
+// Upload-Metadata keys: filename, channelNumber, title, description. A completed
+// upload is queued for ingest like any other uploaded file.
+func (h *AdminHandler) TusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+
+		method := r.Method
+		if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && method == http.MethodPost {
+			method = strings.ToUpper(override)
+		}
+
+		// Discovery needs no session and no version negotiation
+		if method == http.MethodOptions {
+			w.Header().Set("Tus-Version", tusVersion)
+			w.Header().Set("Tus-Extension", tusExtensions)
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(maxUploadBytes, 10))
+			w.Header().Set("Tus-Checksum-Algorithm", tusAlgorithms)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			http.Error(w, "Unsupported tus version", http.StatusPreconditionFailed)
+			return
+		}
+
+		// Authorized here rather than by Require, since discovery is public
+		principal := h.authorize(w, r, auth.IngestMedia)
+		if principal == nil {
+			return
+		}
+		r = withPrincipal(r, principal)
+		userID := principal.UserID
+
+		uploadID := path.Base(strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(tusUploadPath, "/")))
+		if uploadID == "." || uploadID == "/" {
+			uploadID = ""
+		}
+		if uploadID != "" {
+			if upload, err := h.uploadStore.Get(uploadID); err == nil && !allowChannel(w, r, auth.IngestMedia, upload.ChannelID) {
+				return
+			}
+		}
+
+		switch {
+		case method == http.MethodPost && uploadID == "":
+			h.tusCreate(w, r, userID)
+		case method == http.MethodHead && uploadID != "":
+			h.tusHead(w, uploadID)
+		case method == http.MethodPatch && uploadID != "":
+			h.tusPatch(w, r, uploadID, userID)
+		case method == http.MethodDelete && uploadID != "":
+			h.tusDelete(w, uploadID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// tusCreate implements the creation extension
+func (h *AdminHandler) tusCreate(w http.ResponseWriter, r *http.Request, userID string) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, "Deferred upload length is not supported", http.StatusBadRequest)
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		http.Error(w, "A valid Upload-Length header is required", http.StatusBadRequest)
+		return
+	}
+	if size > maxUploadBytes {
+		http.Error(w, "Upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, "Invalid Upload-Metadata header", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	filename := filepath.Base(metadata["filename"])
+	if metadata["filename"] == "" {
+		http.Error(w, "filename metadata is required", http.StatusBadRequest)
+		return
+	}
+	channelNumber, _ := strconv.Atoi(metadata["channelNumber"])
+	if channelNumber < 1 || channelNumber > 5 {
+		http.Error(w, "Invalid channel number", http.StatusBadRequest)
+		return
+	}
+	if !allowChannel(w, r, auth.IngestMedia, channelNumber) {
+		return
+	}
+
+	upload := &services.UploadInfo{
+		Filename:    filename,
+		Size:        size,
+		ChannelID:   channelNumber,
+		Title:       metadata["title"],
+		Description: metadata["description"],
+		CreatedBy:   userID,
+	}
+	if err := h.uploadStore.Create(upload); err != nil {
+		log.Printf("[TusHandler] Error creating upload: %v", err)
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", tusUploadPath+upload.ID)
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// tusHead reports the current offset so the client can resume
+func (h *AdminHandler) tusHead(w http.ResponseWriter, uploadID string) {
+	upload, err := h.uploadStore.Get(uploadID)
+	w.Header().Set("Cache-Control", "no-store")
+	if err != nil || upload.Expired() {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if !upload.Complete() {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// tusPatch appends a chunk, verifying Upload-Checksum when present, and queues
+// the upload for ingest once the last byte arrives
+func (h *AdminHandler) tusPatch(w http.ResponseWriter, r *http.Request, uploadID, userID string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "A valid Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+
+	var check *services.ChunkChecksum
+	if header := r.Header.Get("Upload-Checksum"); header != "" {
+		algorithm, encoded, _ := strings.Cut(header, " ")
+		newHash, ok := tusHashes[algorithm]
+		if !ok {
+			http.Error(w, "Unsupported checksum algorithm", http.StatusBadRequest)
+			return
+		}
+		expected, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			http.Error(w, "Invalid Upload-Checksum header", http.StatusBadRequest)
+			return
+		}
+		check = &services.ChunkChecksum{Hash: newHash(), Expected: expected}
+	}
+
+	upload, err := h.uploadStore.WriteChunk(uploadID, offset, r.Body, check)
+	switch {
+	case errors.Is(err, services.ErrUploadNotFound):
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrOffsetMismatch):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, services.ErrUploadTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, services.ErrChecksumMismatch):
+		http.Error(w, "Checksum Mismatch", statusChecksumMismatch)
+		return
+	case err != nil && upload == nil:
+		log.Printf("[TusHandler] Error writing to upload %s: %v", uploadID, err)
+		http.Error(w, "Failed to store chunk", http.StatusInternalServerError)
+		return
+	case err != nil:
+		// The connection dropped mid-chunk; the client resumes from a HEAD request
+		log.Printf("[TusHandler] Partial chunk for upload %s: %v", uploadID, err)
+		return
+	}
+
+	if upload.Complete() {
+		if _, err := h.fileIngestor.IngestUpload(upload, userID); err != nil {
+			log.Printf("[TusHandler] Error queueing upload %s: %v", upload.ID, err)
+			http.Error(w, "Failed to process video: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tusDelete implements the termination extension
+func (h *AdminHandler) tusDelete(w http.ResponseWriter, uploadID string) {
+	upload, err := h.uploadStore.Get(uploadID)
+	if err != nil {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+	if upload.VideoID != "" {
+		http.Error(w, "Upload has already been queued for ingest", http.StatusConflict)
+		return
+	}
+	if err := h.uploadStore.Remove(uploadID); err != nil {
+		log.Printf("[TusHandler] Error removing upload %s: %v", uploadID, err)
+		http.Error(w, "Failed to remove upload", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2"
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
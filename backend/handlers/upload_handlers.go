@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/services"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// maxUploadBytes caps a single uploaded media file
+const maxUploadBytes = 20 << 30 // 20 GiB
+
+// UploadSessionRequest is the request body for starting a chunked upload
+type UploadSessionRequest struct {
+	Filename      string `json:"filename"`
+	Size          int64  `json:"size"`
+	ChannelNumber int    `json:"channelNumber"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+}
+
+// UploadFileHandler accepts a media file as multipart/form-data (fields: file,
+// channelNumber, title, description) and queues it for ingest
+func (h *AdminHandler) UploadFileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		// Stream the form so large files go straight to disk
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "Expected multipart/form-data body", http.StatusBadRequest)
+			return
+		}
+
+		upload := &services.UploadInfo{CreatedBy: userID}
+		stored := false
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("[UploadFileHandler] Error reading multipart body: %v", err)
+				http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+				return
+			}
+
+			switch part.FormName() {
+			case "file":
+				if stored {
+					http.Error(w, "Only one file may be uploaded per request", http.StatusBadRequest)
+					return
+				}
+				upload.Filename = filepath.Base(part.FileName())
+				if err := h.uploadStore.Import(upload, part); err != nil {
+					log.Printf("[UploadFileHandler] Error storing upload: %v", err)
+					http.Error(w, "Failed to store uploaded file", http.StatusInternalServerError)
+					return
+				}
+				stored = true
+			case "channelNumber":
+				value, _ := io.ReadAll(io.LimitReader(part, 16))
+				upload.ChannelID, _ = strconv.Atoi(string(value))
+			case "title":
+				value, _ := io.ReadAll(io.LimitReader(part, 1024))
+				upload.Title = string(value)
+			case "description":
+				value, _ := io.ReadAll(io.LimitReader(part, 64*1024))
+				upload.Description = string(value)
+			}
+			part.Close()
+		}
+
+		// Validate request
+		if !stored {
+			http.Error(w, "A file is required", http.StatusBadRequest)
+			return
+		}
+		if upload.ChannelID < 1 || upload.ChannelID > 5 {
+			h.uploadStore.Remove(upload.ID)
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+		if !principalFrom(r).CanOnChannel(auth.IngestMedia, upload.ChannelID) {
+			h.uploadStore.Remove(upload.ID)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		videoID, err := h.fileIngestor.IngestUpload(upload, userID)
+		if err != nil {
+			log.Printf("[UploadFileHandler] Error queueing upload %s: %v", upload.ID, err)
+			http.Error(w, "Failed to process video: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Video processing has been queued",
+			"videoId": videoID,
+		})
+	}
+}
+
+// CreateUploadSessionHandler starts a resumable chunked upload
+func (h *AdminHandler) CreateUploadSessionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		var req UploadSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Validate request
+		if req.Filename == "" {
+			http.Error(w, "Filename is required", http.StatusBadRequest)
+			return
+		}
+		if req.Size <= 0 || req.Size > maxUploadBytes {
+			http.Error(w, "Invalid size", http.StatusBadRequest)
+			return
+		}
+		if req.ChannelNumber < 1 || req.ChannelNumber > 5 {
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+		if !allowChannel(w, r, auth.IngestMedia, req.ChannelNumber) {
+			return
+		}
+
+		upload := &services.UploadInfo{
+			Filename:    filepath.Base(req.Filename),
+			Size:        req.Size,
+			ChannelID:   req.ChannelNumber,
+			Title:       req.Title,
+			Description: req.Description,
+			CreatedBy:   userID,
+		}
+		if err := h.uploadStore.Create(upload); err != nil {
+			log.Printf("Error creating upload session: %v", err)
+			http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"upload": upload,
+		})
+	}
+}
+
+// GetUploadSessionHandler returns the received offset so a client can resume
+func (h *AdminHandler) GetUploadSessionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		upload, err := h.uploadStore.Get(mux.Vars(r)["uploadID"])
+		if err != nil {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		if !allowChannel(w, r, auth.IngestMedia, upload.ChannelID) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"upload": upload,
+		})
+	}
+}
+
+// UploadChunkHandler appends a chunk described by a "Content-Range: bytes start-end/total" header
+func (h *AdminHandler) UploadChunkHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var start, end, total int64
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil || end < start {
+			http.Error(w, "A valid Content-Range header is required", http.StatusBadRequest)
+			return
+		}
+
+		uploadID := mux.Vars(r)["uploadID"]
+		existing, err := h.uploadStore.Get(uploadID)
+		if err != nil || existing.Expired() {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		if !allowChannel(w, r, auth.IngestMedia, existing.ChannelID) {
+			return
+		}
+		if total != existing.Size {
+			http.Error(w, "Content-Range total does not match upload size", http.StatusBadRequest)
+			return
+		}
+
+		upload, err := h.uploadStore.WriteChunk(uploadID, start, io.LimitReader(r.Body, end-start+1), nil)
+		switch {
+		case errors.Is(err, services.ErrOffsetMismatch):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":  err.Error(),
+				"upload": upload,
+			})
+			return
+		case err != nil:
+			log.Printf("Error writing chunk for upload %s: %v", uploadID, err)
+			http.Error(w, "Failed to store chunk", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"upload": upload,
+		})
+	}
+}
+
+// CompleteUploadSessionHandler queues a fully received chunked upload for ingest
+func (h *AdminHandler) CompleteUploadSessionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := principalFrom(r).UserID
+
+		upload, err := h.uploadStore.Get(mux.Vars(r)["uploadID"])
+		if err != nil {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		if !allowChannel(w, r, auth.IngestMedia, upload.ChannelID) {
+			return
+		}
+		if !upload.Complete() {
+			http.Error(w, fmt.Sprintf("Upload incomplete: received %d of %d bytes", upload.Offset, upload.Size), http.StatusConflict)
+			return
+		}
+
+		videoID, err := h.fileIngestor.IngestUpload(upload, userID)
+		if err != nil {
+			log.Printf("Error queueing upload %s: %v", upload.ID, err)
+			http.Error(w, "Failed to process video: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Video processing has been queued",
+			"videoId": videoID,
+		})
+	}
+}
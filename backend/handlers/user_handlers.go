@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/database"
+	"live-broadcast-backend/models"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateUserRequest is the request body for creating a user. The user must change the
+// password at first sign-in.
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	Channels []int  `json:"channels"`
+}
+
+// UpdateUserRequest is the request body for changing a user's email, role or channels
+type UpdateUserRequest struct {
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Channels []int  `json:"channels"`
+}
+
+// ResetPasswordRequest is the request body for setting another user's password
+type ResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest is the request body for changing one's own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// validChannels reports whether every channel exists
+func (h *AdminHandler) validChannels(channels []int) bool {
+	for _, channel := range channels {
+		if _, err := h.db.GetChannel(channel); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isLastOwner reports whether userID is the only enabled owner on every channel, who
+// must stay so that someone can still manage users
+func (h *AdminHandler) isLastOwner(userID string) (bool, error) {
+	users, err := h.db.ListUsers()
+	if err != nil {
+		return false, err
+	}
+	target := false
+	for _, user := range users {
+		if user.Role != auth.RoleOwner || user.Disabled || len(user.Channels) > 0 {
+			continue
+		}
+		if user.ID != userID {
+			return false, nil
+		}
+		target = true
+	}
+	return target, nil
+}
+
+// loadUser answers 404 or 500 and returns nil unless the {userID} path variable names a user
+func (h *AdminHandler) loadUser(w http.ResponseWriter, r *http.Request) *models.User {
+	userID := mux.Vars(r)["userID"]
+	user, err := h.db.GetUser(userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error loading user %s: %v", userID, err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return nil
+	}
+	return user
+}
+
+// keepOwner answers 409 and returns false when the change would leave no enabled owner
+// on every channel
+func (h *AdminHandler) keepOwner(w http.ResponseWriter, user *models.User) bool {
+	last, err := h.isLastOwner(user.ID)
+	if err != nil {
+		log.Printf("Error counting owners: %v", err)
+		http.Error(w, "Failed to check owners", http.StatusInternalServerError)
+		return false
+	}
+	if last {
+		http.Error(w, "At least one enabled owner with access to every channel is required", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// ListUsersHandler returns every user with their role and channels
+func (h *AdminHandler) ListUsersHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := h.db.ListUsers()
+		if err != nil {
+			log.Printf("Error listing users: %v", err)
+			http.Error(w, "Failed to list users", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"users": users,
+		})
+	}
+}
+
+// CreateUserHandler creates a user who must change the given password at first sign-in
+func (h *AdminHandler) CreateUserHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Validate request
+		req.Username = strings.TrimSpace(req.Username)
+		req.Email = strings.TrimSpace(req.Email)
+		if req.Username == "" || req.Email == "" {
+			http.Error(w, "Username and email are required", http.StatusBadRequest)
+			return
+		}
+		if !auth.ValidRole(req.Role) {
+			http.Error(w, "Role must be owner, programmer or uploader", http.StatusBadRequest)
+			return
+		}
+		if !h.validChannels(req.Channels) {
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+		if err := h.passwordPolicy.Check(req.Password, req.Username); err != nil {
+			http.Error(w, "Invalid password: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user := &models.User{
+			Username:           req.Username,
+			Email:              req.Email,
+			Role:               req.Role,
+			Channels:           req.Channels,
+			MustChangePassword: true,
+		}
+		err := h.db.CreateUser(user, req.Password)
+		if errors.Is(err, database.ErrUserExists) {
+			http.Error(w, "Username or email is already taken", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Error creating user %s: %v", req.Username, err)
+			http.Error(w, "Failed to create user", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("User %s (%s) created by %s", user.Username, user.Role, principalFrom(r).Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"user":    user,
+		})
+	}
+}
+
+// UpdateUserHandler changes a user's email, role and channels
+func (h *AdminHandler) UpdateUserHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := h.loadUser(w, r)
+		if user == nil {
+			return
+		}
+
+		var req UpdateUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Validate request
+		req.Email = strings.TrimSpace(req.Email)
+		if req.Email == "" {
+			http.Error(w, "Email is required", http.StatusBadRequest)
+			return
+		}
+		if !auth.ValidRole(req.Role) {
+			http.Error(w, "Role must be owner, programmer or uploader", http.StatusBadRequest)
+			return
+		}
+		if !h.validChannels(req.Channels) {
+			http.Error(w, "Invalid channel number", http.StatusBadRequest)
+			return
+		}
+		if (req.Role != auth.RoleOwner || len(req.Channels) > 0) && !h.keepOwner(w, user) {
+			return
+		}
+
+		user.Email = req.Email
+		user.Role = req.Role
+		user.Channels = req.Channels
+		err := h.db.UpdateUser(user)
+		if errors.Is(err, database.ErrUserExists) {
+			http.Error(w, "Email is already taken", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Error updating user %s: %v", user.ID, err)
+			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("User %s updated by %s: role %s, channels %v", user.Username, principalFrom(r).Username, user.Role, user.Channels)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"user":    user,
+		})
+	}
+}
+
+// SetUserDisabledHandler disables a user, ending their sessions and revoking their API
+// keys, or enables them again
+func (h *AdminHandler) SetUserDisabledHandler(disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := h.loadUser(w, r)
+		if user == nil {
+			return
+		}
+		if disabled && !h.keepOwner(w, user) {
+			return
+		}
+
+		user.Disabled = disabled
+		if err := h.db.UpdateUser(user); err != nil {
+			log.Printf("Error updating user %s: %v", user.ID, err)
+			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+			return
+		}
+		if disabled {
+			if _, err := h.sessions.RevokeAll(user.ID); err != nil {
+				log.Printf("Warning: Error ending sessions of disabled user %s: %v", user.ID, err)
+			}
+			if _, err := h.apiKeys.RevokeAll(user.ID); err != nil {
+				log.Printf("Warning: Error revoking API keys of disabled user %s: %v", user.ID, err)
+			}
+		}
+		log.Printf("User %s disabled=%t by %s", user.Username, disabled, principalFrom(r).Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"user":    user,
+		})
+	}
+}
+
+// DeleteUserHandler deletes a user and their sessions
+func (h *AdminHandler) DeleteUserHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := h.loadUser(w, r)
+		if user == nil {
+			return
+		}
+		if !h.keepOwner(w, user) {
+			return
+		}
+
+		if err := h.db.DeleteUser(user.ID); err != nil {
+			log.Printf("Error deleting user %s: %v", user.ID, err)
+			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("User %s deleted by %s", user.Username, principalFrom(r).Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	}
+}
+
+// ResetPasswordHandler sets another user's password, ends their sessions, revokes their
+// API keys and makes them change it at their next sign-in
+func (h *AdminHandler) ResetPasswordHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := h.loadUser(w, r)
+		if user == nil {
+			return
+		}
+
+		var req ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := h.passwordPolicy.Check(req.Password, user.Username); err != nil {
+			http.Error(w, "Invalid password: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.db.SetPassword(user.ID, req.Password, true); err != nil {
+			log.Printf("Error resetting password of user %s: %v", user.ID, err)
+			http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+			return
+		}
+		if _, err := h.sessions.RevokeAll(user.ID); err != nil {
+			log.Printf("Warning: Error ending sessions of user %s: %v", user.ID, err)
+		}
+		if _, err := h.apiKeys.RevokeAll(user.ID); err != nil {
+			log.Printf("Warning: Error revoking API keys of user %s: %v", user.ID, err)
+		}
+		log.Printf("Password of user %s reset by %s", user.Username, principalFrom(r).Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	}
+}
+
+// ChangePasswordHandler changes the signed-in user's own password. Their other sessions
+// end and the current one is replaced.
+func (h *AdminHandler) ChangePasswordHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := principalFrom(r)
+
+		var req ChangePasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if _, err := h.db.ValidateUser(principal.Username, req.CurrentPassword); err != nil {
+			http.Error(w, "Current password is incorrect", http.StatusForbidden)
+			return
+		}
+		if req.NewPassword == req.CurrentPassword {
+			http.Error(w, "Invalid password: password must differ from the current one", http.StatusBadRequest)
+			return
+		}
+		if err := h.passwordPolicy.Check(req.NewPassword, principal.Username); err != nil {
+			http.Error(w, "Invalid password: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.db.SetPassword(principal.UserID, req.NewPassword, false); err != nil {
+			log.Printf("Error changing password of user %s: %v", principal.UserID, err)
+			http.Error(w, "Failed to change password", http.StatusInternalServerError)
+			return
+		}
+		if _, err := h.sessions.RevokeAll(principal.UserID); err != nil {
+			log.Printf("Warning: Error ending sessions of user %s: %v", principal.UserID, err)
+		}
+		session, err := h.sessions.Create(principal.UserID)
+		if err != nil {
+			log.Printf("Error creating session: %v", err)
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		setSessionCookie(w, r, session)
+		log.Printf("User %s changed their password", principal.Username)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+	}
+}
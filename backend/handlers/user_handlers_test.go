@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"live-broadcast-backend/auth"
+	"live-broadcast-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUserChangesRevokeCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		revoked bool // whether the user's sessions and API keys stop working
+	}{
+		{name: "disable", path: "/users/%s/disable", revoked: true},
+		{name: "enable", path: "/users/%s/enable", revoked: false},
+		{name: "reset password", path: "/users/%s/password", body: `{"password":"a brand new passphrase"}`, revoked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestHandler(t)
+			router := mux.NewRouter()
+			router.HandleFunc("/users/{userID}/disable", h.Require(auth.ManageUsers, h.SetUserDisabledHandler(true))).Methods("POST")
+			router.HandleFunc("/users/{userID}/enable", h.Require(auth.ManageUsers, h.SetUserDisabledHandler(false))).Methods("POST")
+			router.HandleFunc("/users/{userID}/password", h.Require(auth.ManageUsers, h.ResetPasswordHandler())).Methods("POST")
+
+			_, ownerToken := addUser(t, h, db, models.User{Username: "owner", Role: auth.RoleOwner})
+			user, userToken := addUser(t, h, db, models.User{Username: "editor", Role: auth.RoleUploader})
+			secret, err := h.apiKeys.Create(&models.APIKey{Name: "ci", UserID: user.ID, Permissions: []string{string(auth.ViewContent)}}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, strings.Replace(tt.path, "%s", user.ID, 1), strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, withSession(req, ownerToken))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			session, err := h.sessions.Lookup(userToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (session == nil) != tt.revoked {
+				t.Errorf("session ended = %t, want %t", session == nil, tt.revoked)
+			}
+			key, err := h.apiKeys.Lookup(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (key == nil) != tt.revoked {
+				t.Errorf("API key revoked = %t, want %t", key == nil, tt.revoked)
+			}
+		})
+	}
+}
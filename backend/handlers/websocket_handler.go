@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/models"
 	"live-broadcast-backend/services"
 	"live-broadcast-backend/state"
 	"log"
@@ -19,8 +22,13 @@ type WebSocketClient struct {
 	conn           *websocket.Conn
 	channelManager *state.ChannelManager
 	videoService   *services.VideoService
+	subtitles      *services.SubtitleService
 	currentChannel int
+	subtitleLang   string // Language whose cues are sent; "" when subtitles are off
+	audioLangs     map[int]string // Preferred audio language per channel
 	isActive       bool
+	isAdmin        bool
+	unsubscribe    func()
 	mu             sync.Mutex
 	pingTimer      *time.Timer
 }
@@ -34,9 +42,25 @@ type WebSocketMessage struct {
 	Duration    float64     `json:"duration,omitempty"`
 	VideoId     string      `json:"videoId,omitempty"`
 	Title       string      `json:"title,omitempty"`
+	Language    string      `json:"language,omitempty"`
+	Subtitles   []models.SubtitleTrack `json:"subtitles,omitempty"` // Tracks available for the current video
+	AudioTracks []models.AudioTrack    `json:"audioTracks,omitempty"` // Audio renditions of the current video
+	AudioTrack  int                    `json:"audioTrack,omitempty"`  // Index of the rendition to enable for the client's language
 	Data        interface{} `json:"data,omitempty"`
 }
 
+// liveSubtitleCue is a cue placed on the channel clock: ShowAt and HideAt are the wall
+// clock times the cue appears and disappears on air
+type liveSubtitleCue struct {
+	models.SubtitleCue
+	ShowAt time.Time `json:"showAt"`
+	HideAt time.Time `json:"hideAt"`
+}
+
+// subtitleLookahead is how far ahead of the channel clock cues are sent, so players can
+// schedule them before they are due
+const subtitleLookahead = 2 * time.Second
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -46,9 +70,12 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// WebSocketHandler handles WebSocket connections.
-func WebSocketHandler(cm *state.ChannelManager, videoService *services.VideoService) http.HandlerFunc {
+// WebSocketHandler handles WebSocket connections. Connections carrying an admin
+// session additionally receive ingest progress messages.
+func WebSocketHandler(cm *state.ChannelManager, videoService *services.VideoService, subtitles *services.SubtitleService, progressHub *services.ProgressHub, isAdmin func(r *http.Request) bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Check the session cookie before upgrading, while we still have the request
+		admin := isAdmin(r)
 
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -69,8 +96,18 @@ func WebSocketHandler(cm *state.ChannelManager, videoService *services.VideoServ
 			conn:           conn,
 			channelManager: cm,
 			videoService:   videoService,
+			subtitles:      subtitles,
+			audioLangs:     make(map[int]string),
 			currentChannel: 1, // Default to channel 1
 			isActive:       true,
+			isAdmin:        admin,
+		}
+
+		// Stream ingest progress to admin dashboards
+		if admin {
+			progress, unsubscribe := progressHub.Subscribe()
+			client.unsubscribe = unsubscribe
+			go client.sendIngestProgress(progress)
 		}
 
 		// Start the ping ticker to keep the connection alive
@@ -82,6 +119,9 @@ func WebSocketHandler(cm *state.ChannelManager, videoService *services.VideoServ
 		// Start a goroutine to send periodic video updates
 		go client.sendVideoUpdates()
 
+		// Start a goroutine to send subtitle cues once the client picks a language
+		go client.sendSubtitleCues()
+
 		log.Println("New WebSocket client connected")
 	}
 }
@@ -130,6 +170,9 @@ func (c *WebSocketClient) readPump() {
 		c.isActive = false
 		c.conn.Close()
 		c.mu.Unlock()
+		if c.unsubscribe != nil {
+			c.unsubscribe()
+		}
 		log.Println("WebSocket client disconnected")
 	}()
 
@@ -160,6 +203,10 @@ func (c *WebSocketClient) readPump() {
 			c.handleJoinChannel(msg.Channel)
 		case "getChannelGuide":
 			c.handleGetChannelGuide()
+		case "setSubtitles":
+			c.handleSetSubtitles(msg.Language)
+		case "setAudioLanguage":
+			c.handleSetAudioLanguage(msg.Channel, msg.Language)
 		default:
 			log.Printf("Unknown WebSocket message type: %s", msg.Type)
 		}
@@ -181,6 +228,44 @@ func (c *WebSocketClient) handleJoinChannel(channelNumber int) {
 	c.sendCurrentChannelState()
 }
 
+// handleSetSubtitles chooses the subtitle language sent to the client; "" turns subtitles off
+func (c *WebSocketClient) handleSetSubtitles(language string) {
+	if language != "" && !services.ValidLanguage(language) {
+		log.Printf("Invalid subtitle language: %q", language)
+		return
+	}
+
+	c.mu.Lock()
+	c.subtitleLang = language
+	c.mu.Unlock()
+}
+
+// handleSetAudioLanguage records the client's preferred audio language for a channel
+// ("" for the channel default) and resends that channel's state if it is being watched
+func (c *WebSocketClient) handleSetAudioLanguage(channelNumber int, language string) {
+	if channelNumber < 1 || channelNumber > 5 {
+		log.Printf("Invalid channel number: %d", channelNumber)
+		return
+	}
+	if language != "" && !services.ValidLanguage(language) {
+		log.Printf("Invalid audio language: %q", language)
+		return
+	}
+
+	c.mu.Lock()
+	if language == "" {
+		delete(c.audioLangs, channelNumber)
+	} else {
+		c.audioLangs[channelNumber] = language
+	}
+	watching := c.currentChannel == channelNumber
+	c.mu.Unlock()
+
+	if watching {
+		c.sendCurrentChannelState()
+	}
+}
+
 // handleGetChannelGuide processes a request for the channel guide.
 func (c *WebSocketClient) handleGetChannelGuide() {
 	guideInfo := c.channelManager.GetAllChannelGuideInfo()
@@ -286,6 +371,15 @@ func (c *WebSocketClient) sendCurrentChannelState() {
 		videoURL = "/" + videoURL
 	}
 
+	// Enable the rendition in the client's language for this channel
+	c.mu.Lock()
+	preferred := c.audioLangs[channelNumber]
+	c.mu.Unlock()
+	audioTrack := 0
+	if track, ok := services.PickAudioTrack(state.CurrentVideo.AudioTracks, preferred); ok {
+		audioTrack = track.Index
+	}
+
 	// Create videoUpdate message with enhanced metadata
 	response := WebSocketMessage{
 		Type:        "videoUpdate",
@@ -295,6 +389,9 @@ func (c *WebSocketClient) sendCurrentChannelState() {
 		Duration:    state.CurrentVideo.Duration,
 		VideoId:     state.CurrentVideo.ID,
 		Title:       state.CurrentVideo.Title,
+		AudioTracks: state.CurrentVideo.AudioTracks,
+		AudioTrack:  audioTrack,
+		Subtitles:   c.subtitles.Tracks(keys.PreviewID(state.CurrentVideo.S3Key, state.CurrentVideo.ID)),
 	}
 
 	c.mu.Lock()
@@ -311,6 +408,105 @@ func (c *WebSocketClient) sendCurrentChannelState() {
 	}
 }
 
+// sendIngestProgress forwards ingest progress reports to an admin client until unsubscribed.
+func (c *WebSocketClient) sendIngestProgress(progress <-chan models.IngestProgress) {
+	for p := range progress {
+		message := WebSocketMessage{
+			Type:    "ingestProgress",
+			Channel: p.ChannelID,
+			VideoId: p.VideoID,
+			Data:    p,
+		}
+
+		c.mu.Lock()
+		if !c.isActive {
+			c.mu.Unlock()
+			continue // keep draining until the hub closes the channel
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if err := c.conn.WriteJSON(message); err != nil {
+			log.Printf("Error sending ingest progress: %v", err)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// sendSubtitleCues sends the cues of the client's subtitle language as the channel
+// reaches them, subtitleLookahead early. Cues are loaded once per programme.
+func (c *WebSocketClient) sendSubtitleCues() {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	var cues []models.SubtitleCue
+	var videoID, language string
+	var channel int
+	var startTime time.Time
+	sentUntil := 0.0 // Video position up to which cues have been sent
+
+	for range ticker.C {
+		c.mu.Lock()
+		isActive, channelNumber, wanted := c.isActive, c.currentChannel, c.subtitleLang
+		c.mu.Unlock()
+		if !isActive {
+			return
+		}
+		if wanted == "" {
+			language = ""
+			continue
+		}
+
+		state, err := c.channelManager.GetChannelState(channelNumber)
+		if err != nil || state.CurrentVideo == nil {
+			continue
+		}
+		id := keys.PreviewID(state.CurrentVideo.S3Key, state.CurrentVideo.ID)
+		position := time.Since(state.VideoStartTime).Seconds()
+		until := position + subtitleLookahead.Seconds()
+
+		// A new programme, channel or language (or a restarted one) starts from the cue on air now
+		restart := id != videoID || channelNumber != channel || wanted != language || !state.VideoStartTime.Equal(startTime)
+		if restart {
+			videoID, channel, language, startTime = id, channelNumber, wanted, state.VideoStartTime
+			sentUntil = position
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			cues, err = c.subtitles.Cues(ctx, id, wanted)
+			cancel()
+			if err != nil {
+				log.Printf("Error loading %s subtitles for video %s: %v", wanted, id, err)
+				cues = nil
+			}
+		}
+
+		for _, cue := range cues {
+			due := cue.Start > sentUntil || (restart && cue.End > position)
+			if cue.Start > until || !due {
+				continue
+			}
+			message := WebSocketMessage{
+				Type:     "subtitleCue",
+				Channel:  channelNumber,
+				VideoId:  state.CurrentVideo.ID,
+				Language: language,
+				Data: liveSubtitleCue{
+					SubtitleCue: cue,
+					ShowAt:      startTime.Add(time.Duration(cue.Start * float64(time.Second))),
+					HideAt:      startTime.Add(time.Duration(cue.End * float64(time.Second))),
+				},
+			}
+
+			c.mu.Lock()
+			if c.isActive {
+				c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+				if err := c.conn.WriteJSON(message); err != nil {
+					log.Printf("Error sending subtitle cue: %v", err)
+				}
+			}
+			c.mu.Unlock()
+		}
+		sentUntil = until
+	}
+}
+
 // sendVideoUpdates sends periodic updates about the current video's state.
 func (c *WebSocketClient) sendVideoUpdates() {
 	// Send updates more frequently for better synchronization
@@ -0,0 +1,159 @@
+// Package keys defines where media and derived files are kept: the storage keys of
+// videos, previews and subtitles, the URLs previews are served from and the paths of
+// local playout copies. Everything that reads, writes or deletes these goes through
+// here so the layouts cannot drift apart.
+package keys
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ThumbnailURLPrefix is the path previews are served under
+const ThumbnailURLPrefix = "/thumbnails/"
+
+// ingestedVideo matches keys written by the ingest pipeline, whose videos have a
+// database row: library keys and the per-channel keys used before the media library
+var ingestedVideo = regexp.MustCompile(`^(?:library|channel_\d+)/video_([0-9a-f-]{36})\.mp4$`)
+
+// previewKey and subtitleKey match the keys of derived files, capturing their owner's ID
+var (
+	previewKey  = regexp.MustCompile(`^thumbnails/(?:thumbnail|sprite)_(.+)\.(?:jpg|vtt)$`)
+	subtitleKey = regexp.MustCompile(`^subtitles/([^/]+)/[^/]+\.vtt$`)
+)
+
+// previewName matches the file names previews are stored under
+var previewName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// LibraryVideo is the key of a library asset's video, independent of the channels airing it
+func LibraryVideo(assetID string) string {
+	return LibraryPrefix + "video_" + assetID + ".mp4"
+}
+
+// ChannelFolder is the folder videos synced straight into storage are placed in for a channel
+func ChannelFolder(channel int) string {
+	return fmt.Sprintf("channel_%d/", channel)
+}
+
+// Prefixes where ingest and previews write objects; everything else in storage is
+// either a channel folder or not ours
+const (
+	LibraryPrefix   = "library/"
+	ThumbnailPrefix = "thumbnails/"
+	SubtitlesPrefix = "subtitles/"
+)
+
+// ChannelNumber parses a channel folder name, with or without its trailing slash. It
+// returns 0 for anything else.
+func ChannelNumber(folder string) int {
+	var channel int
+	if _, err := fmt.Sscanf(strings.TrimSuffix(folder, "/"), "channel_%d", &channel); err != nil || channel < 1 {
+		return 0
+	}
+	return channel
+}
+
+// LegacyChannelVideo is the single video a channel aired before playlists
+func LegacyChannelVideo(channel int) string {
+	return ChannelFolder(channel) + "video.mp4"
+}
+
+// SyncVideoID is the ID the storage sync gives a video found in a channel folder
+func SyncVideoID(videoKey string, channel int) string {
+	name := path.Base(videoKey)
+	return fmt.Sprintf("s3-%s-%d", strings.TrimSuffix(name, path.Ext(name)), channel)
+}
+
+// IsIngestedVideo reports whether a key was written by the ingest pipeline rather
+// than placed in a channel folder for the sync to pick up
+func IsIngestedVideo(key string) bool {
+	return ingestedVideo.MatchString(key)
+}
+
+// PreviewID returns the ID a video's previews and subtitles are stored under: the
+// library asset (or, before the library, database video) ID for ingested keys,
+// otherwise the ID the storage sync assigns
+func PreviewID(videoKey, syncID string) string {
+	if m := ingestedVideo.FindStringSubmatch(videoKey); m != nil {
+		return m[1]
+	}
+	return syncID
+}
+
+// Thumbnail is the key of a video's poster image
+func Thumbnail(id string) string {
+	return ThumbnailPrefix + "thumbnail_" + id + ".jpg"
+}
+
+// Sprite is the key of a video's seek preview sprite sheet
+func Sprite(id string) string {
+	return ThumbnailPrefix + "sprite_" + id + ".jpg"
+}
+
+// SpriteVTT is the key of the WebVTT track mapping times to sprite tiles
+func SpriteVTT(id string) string {
+	return ThumbnailPrefix + "sprite_" + id + ".vtt"
+}
+
+// Previews returns the keys of the thumbnail, sprite sheet and sprite track of a video
+func Previews(id string) []string {
+	return []string{Thumbnail(id), Sprite(id), SpriteVTT(id)}
+}
+
+// PreviewURL is the URL a preview key is served from
+func PreviewURL(key string) string {
+	return ThumbnailURLPrefix + strings.TrimPrefix(key, ThumbnailPrefix)
+}
+
+// PreviewKey maps the file name in a preview URL back to its key. ok is false for
+// names that are not previews.
+func PreviewKey(name string) (key string, ok bool) {
+	if !previewName.MatchString(name) || strings.Contains(name, "..") {
+		return "", false
+	}
+	return ThumbnailPrefix + name, true
+}
+
+// Owner returns the video or asset ID a preview or subtitle key is stored under. ok is
+// false for other keys.
+func Owner(key string) (id string, ok bool) {
+	if m := previewKey.FindStringSubmatch(key); m != nil {
+		return m[1], true
+	}
+	if m := subtitleKey.FindStringSubmatch(key); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// SubtitlePrefix is the folder holding all subtitle tracks of a video
+func SubtitlePrefix(id string) string {
+	return SubtitlesPrefix + id + "/"
+}
+
+// Subtitle is the key of a video's subtitle track in one language
+func Subtitle(id, lang string) string {
+	return SubtitlePrefix(id) + lang + ".vtt"
+}
+
+// LocalCopy is where playout keeps its downloaded copy of a video
+func LocalCopy(videoDir, videoKey string) string {
+	return filepath.Join(videoDir, filepath.FromSlash(videoKey))
+}
+
+// Fragmented is the fragmented MP4 written next to a local copy that was not fragmented
+func Fragmented(path string) string {
+	return strings.TrimSuffix(path, ".mp4") + ".frag.mp4"
+}
+
+// Unfragmented returns the local copy a fragmented MP4 was made from, or path itself
+// when it is not a fragmented copy
+func Unfragmented(path string) string {
+	if strings.HasSuffix(path, ".frag.mp4") {
+		return strings.TrimSuffix(path, ".frag.mp4") + ".mp4"
+	}
+	return path
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"live-broadcast-backend/auth"
 	"live-broadcast-backend/database"
 	"live-broadcast-backend/handlers"
+	"live-broadcast-backend/models"
 	"live-broadcast-backend/services"
 	"live-broadcast-backend/state"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	gorillaHandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -22,15 +26,16 @@ func main() {
 		log.Println("Warning: .env file not found – falling back to system env")
 	}
 
+	/* ─── CLI: schema migrations without starting the server ─────────────── */
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(os.Args[2:]))
+	}
+
 	/* ─── CORE SINGLETONS ──────────────────────────────────────────────── */
 	channelManager := state.NewChannelManager()
 
 	/* database ---------------------------------------------------------------- */
-	dbPath := os.Getenv("DATABASE_URL")
-	if dbPath == "" {
-		dbPath = "./tvstream.db"
-	}
-	db, err := database.InitDB(dbPath)
+	db, err := database.InitDB(databaseURL())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -41,23 +46,39 @@ func main() {
 	}
 	channelManager.SetDBProvider(db)
 
-	/* S3 / video store -------------------------------------------------------- */
-	s3Bucket := getenvDefault("S3_VIDEO_BUCKET", "tvstream")
-	videoService, err := services.NewVideoService(s3Bucket)
+	/* object storage (S3 or a local directory) ---------------------------- */
+	var storage services.Storage
+	var localStorage *services.LocalStorage
+	switch backend := getenvDefault("STORAGE_BACKEND", "s3"); backend {
+	case "s3":
+		storage, err = services.NewS3Storage(getenvDefault("S3_VIDEO_BUCKET", "tvstream"))
+	case "local":
+		localStorage, err = services.NewLocalStorage(getenvDefault("STORAGE_DIR", "./storage"), os.Getenv("STORAGE_SIGNING_KEY"))
+		storage = localStorage
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q (expected s3 or local)", backend)
+	}
 	if err != nil {
-		log.Fatalf("Failed to initialize video service: %v", err)
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
+	videoService := services.NewVideoService(storage)
 
 	videoDir := getenvDefault("VIDEO_DIR", "./videos")
 	s3Manager, err := services.NewS3Manager(videoService, videoDir)
 	if err != nil {
 		log.Fatalf("Failed to initialize S3 manager: %v", err)
 	}
+	s3Manager.SetChecksumLookup(db)
 	channelManager.SetVideoProvider(s3Manager)
 
+	/* previews (thumbnail, sprite sheet, sprite track) ---------------------- */
+	tempDir := getenvDefault("TEMP_DIR", "./temp")
+	thumbnailGenerator := services.NewThumbnailGenerator(videoService, db, tempDir)
+	subtitleService := services.NewSubtitleService(videoService, db)
+
 	/* sync S3 → local -------------------------------------------------------- */
 	syncMinutes := 15
-	syncService := services.NewSyncService(s3Manager, channelManager, syncMinutes)
+	syncService := services.NewSyncService(s3Manager, channelManager, syncMinutes, thumbnailGenerator)
 	syncService.Start()
 	log.Printf("Started S3 sync service (%d‑minute interval, JIT download)", syncMinutes)
 
@@ -68,15 +89,51 @@ func main() {
 		log.Println("ChannelManager initialised from database")
 	}
 
+	/* live stills for the channel guide ----------------------------------- */
+	channelManager.StartPreviewCapture(time.Duration(getenvInt("PREVIEW_INTERVAL_SECONDS", 30)) * time.Second)
+
+	/* ingest job queue ----------------------------------------------------- */
+	progressHub := services.NewProgressHub(db)
+	jobQueue := services.NewJobQueue(db, db, progressHub,
+		getenvInt("INGEST_CONCURRENCY", 2),
+		getenvInt("INGEST_MAX_ATTEMPTS", 3))
+
+	/* ingest pipeline shared by every source ------------------------------- */
+	ingestPipeline, err := services.NewIngestPipeline(videoService, db, db, db, thumbnailGenerator, subtitleService, tempDir,
+		getenvDefault("DUPLICATE_POLICY", models.DuplicateLink))
+	if err != nil {
+		log.Fatalf("Failed to init ingest pipeline: %v", err)
+	}
+
 	/* YouTube downloader (optional admin feature) -------------------------- */
-	tempDir := getenvDefault("TEMP_DIR", "./temp")
-	youtubeDownloader, err := services.NewYouTubeDownloader(videoService, db, tempDir)
+	youtubeDownloader, err := services.NewYouTubeDownloader(db, jobQueue, ingestPipeline, tempDir,
+		getenvDefault("SUBTITLE_LANGUAGES", "en"))
 	if err != nil {
 		log.Fatalf("Failed to init YouTube downloader: %v", err)
 	}
 
+	/* direct file uploads -------------------------------------------------- */
+	uploadStore, err := services.NewUploadStore(filepath.Join(tempDir, "uploads"),
+		time.Duration(getenvInt("UPLOAD_EXPIRY_HOURS", 24))*time.Hour)
+	if err != nil {
+		log.Fatalf("Failed to init upload store: %v", err)
+	}
+	uploadStore.Start(time.Hour)
+	fileIngestor := services.NewFileIngestor(db, jobQueue, ingestPipeline, uploadStore)
+	jobQueue.Start()
+
+	/* storage garbage collection ------------------------------------------- */
+	garbageCollector := services.NewGarbageCollector(videoService, s3Manager, db,
+		time.Duration(getenvInt("GC_GRACE_HOURS", 72))*time.Hour)
+	garbageCollector.Start(time.Duration(getenvInt("GC_INTERVAL_HOURS", 24)) * time.Hour)
+
 	/* ─── ROUTER ─────────────────────────────────────────────────────────── */
-	adminHandler := handlers.NewAdminHandler(db, youtubeDownloader, videoService)
+	mediaDeleter := services.NewMediaDeleter(videoService, s3Manager)
+	sessionManager := services.NewSessionManager(db, time.Duration(getenvInt("SESSION_TTL_HOURS", 24))*time.Hour)
+	sessionManager.Start(time.Hour)
+	apiKeyManager := services.NewAPIKeyManager(db, time.Duration(getenvInt("API_KEY_TTL_DAYS", 90))*24*time.Hour)
+	adminHandler := handlers.NewAdminHandler(db, youtubeDownloader, fileIngestor, uploadStore, videoService, jobQueue, subtitleService, mediaDeleter, garbageCollector, sessionManager, apiKeyManager,
+		auth.PasswordPolicy{MinLength: getenvInt("PASSWORD_MIN_LENGTH", 12)})
 	router := mux.NewRouter()
 
 	/* secure file server for already‑downloaded MP4s */
@@ -89,23 +146,62 @@ func main() {
 	handlers.SetupLiveStreamRoutes(router, channelManager)
 
 	/* WebSocket (chat / pings / admin dashboard) */
-	router.HandleFunc("/ws", handlers.WebSocketHandler(channelManager, videoService))
+	router.HandleFunc("/ws", handlers.WebSocketHandler(channelManager, videoService, subtitleService, progressHub, adminHandler.IsAdminRequest))
 
 	/* admin & auth sub‑routes */
 	apiRouter := router.PathPrefix("/api").Subrouter()
+	apiRouter.Use(adminHandler.SessionMiddleware)
 
 	authRouter := apiRouter.PathPrefix("/auth").Subrouter()
 	authRouter.HandleFunc("/login",        adminHandler.LoginHandler()).Methods("POST")
-	authRouter.HandleFunc("/verify-admin", adminHandler.VerifyAdminHandler()).Methods("GET")
+	authRouter.HandleFunc("/verify-admin", adminHandler.RequireSignedIn(adminHandler.VerifyAdminHandler())).Methods("GET")
+	authRouter.HandleFunc("/password",     adminHandler.RequireSignedIn(adminHandler.ChangePasswordHandler())).Methods("POST")
+	authRouter.HandleFunc("/logout",       adminHandler.LogoutHandler()).Methods("POST")
+	authRouter.HandleFunc("/logout-all",   adminHandler.LogoutAllHandler()).Methods("POST")
 
+	/* admin routes name the permission they need; handlers check channel scope */
 	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
-	adminRouter.HandleFunc("/upload-video",       adminHandler.UploadVideoHandler()).Methods("POST")
-	adminRouter.HandleFunc("/videos",             adminHandler.GetChannelVideosHandler()).Methods("GET")
-	adminRouter.HandleFunc("/delete-video",       adminHandler.DeleteVideoHandler()).Methods("POST")
-	adminRouter.HandleFunc("/update-video-order", adminHandler.UpdateVideoOrderHandler()).Methods("POST")
-	adminRouter.HandleFunc("/channel",            adminHandler.GetChannelDetailsHandler()).Methods("GET")
-	adminRouter.HandleFunc("/channel/{channelID}",adminHandler.UpdateChannelDetailsHandler()).Methods("PUT")
+	adminRouter.HandleFunc("/upload-video",       adminHandler.Require(auth.IngestMedia, adminHandler.UploadVideoHandler())).Methods("POST")
+	adminRouter.HandleFunc("/videos",             adminHandler.Require(auth.ViewContent, adminHandler.GetChannelVideosHandler())).Methods("GET")
+	adminRouter.HandleFunc("/delete-video",       adminHandler.Require(auth.DeleteMedia, adminHandler.DeleteVideoHandler())).Methods("POST")
+	adminRouter.HandleFunc("/update-video-order", adminHandler.Require(auth.EditSchedule, adminHandler.UpdateVideoOrderHandler())).Methods("POST")
+	adminRouter.HandleFunc("/channel",            adminHandler.Require(auth.ViewContent, adminHandler.GetChannelDetailsHandler())).Methods("GET")
+	adminRouter.HandleFunc("/channel/{channelID}",adminHandler.Require(auth.ManageChannels, adminHandler.UpdateChannelDetailsHandler())).Methods("PUT")
+	adminRouter.HandleFunc("/channel/{channelID}/profile", adminHandler.Require(auth.ViewContent, adminHandler.GetChannelProfileHandler())).Methods("GET")
+	adminRouter.HandleFunc("/channel/{channelID}/profile", adminHandler.Require(auth.ManageChannels, adminHandler.UpdateChannelProfileHandler())).Methods("PUT")
+	adminRouter.HandleFunc("/upload-file",        adminHandler.Require(auth.IngestMedia, adminHandler.UploadFileHandler())).Methods("POST")
+	adminRouter.HandleFunc("/upload-sessions",    adminHandler.Require(auth.IngestMedia, adminHandler.CreateUploadSessionHandler())).Methods("POST")
+	adminRouter.HandleFunc("/upload-sessions/{uploadID}",          adminHandler.Require(auth.IngestMedia, adminHandler.GetUploadSessionHandler())).Methods("GET")
+	adminRouter.HandleFunc("/upload-sessions/{uploadID}",          adminHandler.Require(auth.IngestMedia, adminHandler.UploadChunkHandler())).Methods("PUT")
+	adminRouter.HandleFunc("/upload-sessions/{uploadID}/complete", adminHandler.Require(auth.IngestMedia, adminHandler.CompleteUploadSessionHandler())).Methods("POST")
+	adminRouter.Handle("/uploads",                adminHandler.TusHandler()) // authorizes itself; tus discovery is public
+	adminRouter.PathPrefix("/uploads/").HandlerFunc(adminHandler.TusHandler())
+	adminRouter.HandleFunc("/videos/{videoID}/subtitles",            adminHandler.Require(auth.EditMedia, adminHandler.UploadSubtitleHandler())).Methods("POST")
+	adminRouter.HandleFunc("/videos/{videoID}/subtitles/{language}", adminHandler.Require(auth.EditMedia, adminHandler.DeleteSubtitleHandler())).Methods("DELETE")
+	adminRouter.HandleFunc("/library",            adminHandler.Require(auth.ViewContent, adminHandler.ListLibraryHandler())).Methods("GET")
+	adminRouter.HandleFunc("/library/{assetID}",  adminHandler.Require(auth.ViewContent, adminHandler.GetLibraryAssetHandler())).Methods("GET")
+	adminRouter.HandleFunc("/library/{assetID}",  adminHandler.Require(auth.DeleteMedia, adminHandler.DeleteLibraryAssetHandler())).Methods("DELETE")
+	adminRouter.HandleFunc("/library/{assetID}/channels", adminHandler.Require(auth.EditSchedule, adminHandler.AddAssetToChannelHandler())).Methods("POST")
+	adminRouter.HandleFunc("/storage/orphans",    adminHandler.Require(auth.ManageStorage, adminHandler.StorageOrphansHandler())).Methods("GET")
+	adminRouter.HandleFunc("/storage/gc",         adminHandler.Require(auth.ManageStorage, adminHandler.RunStorageGCHandler())).Methods("POST")
+	adminRouter.HandleFunc("/jobs",               adminHandler.Require(auth.ViewContent, adminHandler.ListJobsHandler())).Methods("GET")
+	adminRouter.HandleFunc("/jobs/{jobID}/retry", adminHandler.Require(auth.ManageJobs, adminHandler.RetryJobHandler())).Methods("POST")
+	adminRouter.HandleFunc("/jobs/{jobID}/cancel",adminHandler.Require(auth.ManageJobs, adminHandler.CancelJobHandler())).Methods("POST")
+	adminRouter.HandleFunc("/users",              adminHandler.Require(auth.ManageUsers, adminHandler.ListUsersHandler())).Methods("GET")
+	adminRouter.HandleFunc("/users",              adminHandler.Require(auth.ManageUsers, adminHandler.CreateUserHandler())).Methods("POST")
+	adminRouter.HandleFunc("/users/{userID}",     adminHandler.Require(auth.ManageUsers, adminHandler.UpdateUserHandler())).Methods("PUT")
+	adminRouter.HandleFunc("/users/{userID}",     adminHandler.Require(auth.ManageUsers, adminHandler.DeleteUserHandler())).Methods("DELETE")
+	adminRouter.HandleFunc("/users/{userID}/disable",  adminHandler.Require(auth.ManageUsers, adminHandler.SetUserDisabledHandler(true))).Methods("POST")
+	adminRouter.HandleFunc("/users/{userID}/enable",   adminHandler.Require(auth.ManageUsers, adminHandler.SetUserDisabledHandler(false))).Methods("POST")
+	adminRouter.HandleFunc("/users/{userID}/password", adminHandler.Require(auth.ManageUsers, adminHandler.ResetPasswordHandler())).Methods("POST")
+	adminRouter.HandleFunc("/api-keys",           adminHandler.Require(auth.ManageAPIKeys, adminHandler.ListAPIKeysHandler())).Methods("GET")
+	adminRouter.HandleFunc("/api-keys",           adminHandler.Require(auth.ManageAPIKeys, adminHandler.CreateAPIKeyHandler())).Methods("POST")
+	adminRouter.HandleFunc("/api-keys/{keyID}",   adminHandler.Require(auth.ManageAPIKeys, adminHandler.RevokeAPIKeyHandler())).Methods("DELETE")
 	apiRouter.PathPrefix("/thumbnails/").HandlerFunc(adminHandler.ThumbnailHandler())
+	apiRouter.PathPrefix("/subtitles/").HandlerFunc(adminHandler.SubtitleHandler())
+	if localStorage != nil {
+		apiRouter.PathPrefix("/storage/").HandlerFunc(handlers.StorageHandler(localStorage))
+	}
 
 	/* single‑page frontend build ------------------------------------------- */
 	frontendDist := filepath.Join(".", "frontend", "dist")
@@ -131,8 +227,11 @@ func main() {
 			"http://localhost:3000", "http://127.0.0.1:3000",
 			"http://localhost:5173", // Vite
 		}),
-		gorillaHandlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		gorillaHandlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
+		gorillaHandlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "HEAD", "DELETE", "OPTIONS"}),
+		gorillaHandlers.AllowedHeaders([]string{"Content-Type", "Authorization",
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Checksum", "X-HTTP-Method-Override"}),
+		gorillaHandlers.ExposedHeaders([]string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension",
+			"Tus-Max-Size", "Tus-Checksum-Algorithm", "Upload-Offset", "Upload-Length", "Upload-Expires"}),
 		gorillaHandlers.AllowCredentials(),
 	)
 
@@ -142,9 +241,23 @@ func main() {
 }
 
 /* utility */
+func databaseURL() string {
+	return getenvDefault("DATABASE_URL", "./tvstream.db")
+}
+
 func getenvDefault(k, d string) string {
 	if v := os.Getenv(k); v != "" {
 		return v
 	}
 	return d
 }
+
+func getenvInt(k string, d int) int {
+	if v := os.Getenv(k); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+		log.Printf("Warning: %s=%q is not an integer – using %d", k, v, d)
+	}
+	return d
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"live-broadcast-backend/database"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `usage: live-broadcast-backend migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [steps]  roll back the last steps migrations (default 1)
+  status        list migrations and whether they are applied`
+
+// runMigrateCommand migrates the database named by DATABASE_URL and returns the
+// process exit code
+func runMigrateCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db, err := database.Open(databaseURL())
+	if err != nil {
+		log.Printf("Failed to connect to database: %v", err)
+		return 1
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.Migrate(ctx)
+		if err != nil {
+			log.Printf("Migration failed: %v", err)
+			return 1
+		}
+		log.Printf("Applied %d migrations", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive number")
+				return 2
+			}
+		}
+		rolledBack, err := db.Rollback(ctx, steps)
+		if err != nil {
+			log.Printf("Rollback failed: %v", err)
+			return 1
+		}
+		log.Printf("Rolled back %d migrations", rolledBack)
+
+	case "status":
+		statuses, err := db.MigrationStatuses(ctx)
+		if err != nil {
+			log.Printf("Failed to read migration status: %v", err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%03d  %-28s  %s\n", status.Version, status.Name, applied)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,11 @@
+package models
+
+// AudioTrack is one audio rendition inside a video's fMP4. All audio tracks share one
+// alternate group; only the default one is enabled unless the player picks another.
+type AudioTrack struct {
+	Index    int    `json:"index"`           // Position among the file's audio tracks
+	Language string `json:"language"`        // BCP 47 tag; "" when the source did not say
+	Label    string `json:"label,omitempty"` // Title from the source, e.g. "Director's commentary"
+	Default  bool   `json:"default,omitempty"`
+	Fallback bool   `json:"fallback,omitempty"` // No dub in Language; the track carries the original audio
+}
@@ -0,0 +1,13 @@
+package models
+
+// What ingest does with a video whose content is already in the library
+const (
+	DuplicateReject = "reject" // Fail the new video and point at the existing one
+	DuplicateLink   = "link"   // Reference the existing S3 object instead of storing a copy
+	DuplicateAllow  = "allow"  // Ingest a separate copy anyway
+)
+
+// ValidDuplicatePolicy reports whether policy is one of the Duplicate* policies
+func ValidDuplicatePolicy(policy string) bool {
+	return policy == DuplicateReject || policy == DuplicateLink || policy == DuplicateAllow
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// JobStatus represents the lifecycle state of an ingest job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// JobKind identifies which pipeline should process an ingest job
+type JobKind string
+
+const (
+	JobKindYouTube JobKind = "youtube" // Any yt-dlp supported page; the name predates other sites
+	JobKindUpload  JobKind = "upload"  // SourceURL is upload://{uploadID} in the upload store
+	JobKindHTTP    JobKind = "http"    // SourceURL is a plain HTTP(S) media file
+	JobKindExpand  JobKind = "expand"  // SourceURL is listed and one job queued per video found; no VideoID
+)
+
+// ImportOptions narrow what a remote import fetches
+type ImportOptions struct {
+	MaxHeight         int    `json:"maxHeight,omitempty"`         // Highest video resolution to download, e.g. 1080
+	DateAfter         string `json:"dateAfter,omitempty"`         // Only entries uploaded on or after YYYYMMDD
+	DateBefore        string `json:"dateBefore,omitempty"`        // Only entries uploaded on or before YYYYMMDD
+	Limit             int    `json:"limit,omitempty"`             // Maximum playlist/channel entries to import
+	SubtitleLanguages string `json:"subtitleLanguages,omitempty"` // yt-dlp --sub-langs list, e.g. "en,es.*"; "none" skips subtitles
+	Duplicates        string `json:"duplicates,omitempty"`        // One of the Duplicate* policies; the server default when empty
+}
+
+// IngestJob is a persisted unit of ingest work that the job queue hands to a worker
+type IngestJob struct {
+	ID          string        `json:"id"`
+	VideoID     string        `json:"videoId"`
+	Kind        JobKind       `json:"kind"`
+	SourceURL   string        `json:"sourceUrl"`
+	ChannelID   int           `json:"channelId"`
+	Options     ImportOptions `json:"options"`
+	Status      JobStatus     `json:"status"`
+	Attempts    int           `json:"attempts"`
+	MaxAttempts int           `json:"maxAttempts"`
+	LastError   string        `json:"lastError,omitempty"`
+	NextRunAt   time.Time     `json:"nextRunAt"`
+	CreatedBy   string        `json:"createdBy"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+	StartedAt   *time.Time    `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time    `json:"finishedAt,omitempty"`
+}
+
+// IsFinal reports whether the job has reached a state it will not leave on its own
+func (j *IngestJob) IsFinal() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCancelled
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// MediaAsset is a transcoded video in the shared media library. Channel playlists air
+// assets through playlist entries (AdminVideo rows with an AssetID), so one S3 object
+// can be scheduled on any number of channels.
+type MediaAsset struct {
+	ID           string               `json:"id"`
+	S3Key        string               `json:"s3Key"`
+	Title        string               `json:"title"`
+	Description  string               `json:"description,omitempty"`
+	Duration     float64              `json:"duration"`
+	ThumbnailURL string               `json:"thumbnailUrl,omitempty"`
+	SpriteURL    string               `json:"spriteUrl,omitempty"`
+	SpriteVTTURL string               `json:"spriteVttUrl,omitempty"`
+	SourceID     string               `json:"sourceId,omitempty"`
+	SourceURL    string               `json:"sourceUrl,omitempty"`
+	Profile      *TranscodeProfile    `json:"profile,omitempty"`
+	Loudness     *LoudnessMeasurement `json:"loudness,omitempty"`
+	Subtitles    []SubtitleTrack      `json:"subtitles,omitempty"`
+	AudioTracks  []AudioTrack         `json:"audioTracks,omitempty"`
+	Checksum     string               `json:"checksum,omitempty"` // SHA-256 of the S3 object
+	CreatedBy    string               `json:"createdBy,omitempty"`
+	CreatedAt    time.Time            `json:"createdAt"`
+	UpdatedAt    time.Time            `json:"updatedAt"`
+	Channels     []int                `json:"channels"` // Channels whose playlist includes the asset
+}
@@ -15,6 +15,10 @@ type Video struct {
 	CreatedAt    time.Time `json:"createdAt"`
 	URL          string    `json:"url"`      // Actual video URL (e.g., pre-signed S3 URL or placeholder)
 	ThumbnailURL string    `json:"thumbnailUrl,omitempty"` // URL for the video thumbnail
+	SpriteURL    string    `json:"spriteUrl,omitempty"`    // Preview sprite sheet for scrubbing
+	SpriteVTTURL string    `json:"spriteVttUrl,omitempty"` // WebVTT track mapping times to sprite tiles
+	Subtitles    []SubtitleTrack `json:"subtitles,omitempty"`
+	AudioTracks  []AudioTrack `json:"audioTracks,omitempty"` // Alternate audio renditions, e.g. dubs
 }
 
 // Channel represents a broadcast channel
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxAudioLanguages caps the audio tracks a channel profile may ask for
+const maxAudioLanguages = 8
+
+// audioLanguagePattern accepts BCP 47 style tags such as "en", "pt-BR" or "spa"
+var audioLanguagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// TranscodeProfile is the output format every video ingested into a channel is
+// transcoded to, so consecutive programmes can share one MSE SourceBuffer
+type TranscodeProfile struct {
+	VideoCodec      string   `json:"videoCodec"` // "h264" or "hevc"
+	Width           int      `json:"width"`      // Output frame size; sources are letterboxed to fit
+	Height          int      `json:"height"`
+	FPS             int      `json:"fps"`
+	GOPSeconds      float64  `json:"gopSeconds"`             // Fixed keyframe interval
+	VideoBitrate    int      `json:"videoBitrate,omitempty"` // kbit/s; quality-based (CRF) encoding when zero
+	AudioCodec      string   `json:"audioCodec"`             // "aac"
+	AudioSampleRate int      `json:"audioSampleRate"`
+	AudioChannels   int      `json:"audioChannels"`
+	AudioBitrate    int      `json:"audioBitrate"`             // kbit/s
+	LoudnessMode    string   `json:"loudnessMode"`             // One of the Loudness* modes
+	TargetLUFS      float64  `json:"targetLufs"`               // Integrated loudness target (EBU R128 is -23)
+	TruePeak        float64  `json:"truePeak"`                 // Maximum true peak in dBTP
+	AudioLanguages  []string `json:"audioLanguages,omitempty"` // One audio track per language, first is default; empty keeps the source's main track only
+}
+
+// Loudness normalisation modes
+const (
+	LoudnessOff       = "off"       // Measure only
+	LoudnessTranscode = "transcode" // Normalise the audio while transcoding
+)
+
+// LoudnessMeasurement is the EBU R128 analysis of a video's source audio
+type LoudnessMeasurement struct {
+	IntegratedLUFS float64 `json:"integratedLufs"`
+	TruePeak       float64 `json:"truePeak"` // dBTP
+	LRA            float64 `json:"lra"`      // Loudness range in LU
+	Threshold      float64 `json:"threshold"`
+	TargetOffset   float64 `json:"targetOffset"`
+	TargetLUFS     float64 `json:"targetLufs"` // Channel target at the time of ingest
+	Mode           string  `json:"mode"`
+}
+
+// DefaultTranscodeProfile returns the profile used by channels that have not configured one
+func DefaultTranscodeProfile() *TranscodeProfile {
+	return &TranscodeProfile{
+		VideoCodec:      "h264",
+		Width:           1280,
+		Height:          720,
+		FPS:             30,
+		GOPSeconds:      2,
+		AudioCodec:      "aac",
+		AudioSampleRate: 48000,
+		AudioChannels:   2,
+		AudioBitrate:    160,
+		LoudnessMode:    LoudnessTranscode,
+		TargetLUFS:      -23,
+		TruePeak:        -1,
+	}
+}
+
+// Validate checks that the profile describes an output ffmpeg and browsers can handle
+func (p *TranscodeProfile) Validate() error {
+	if p.VideoCodec != "h264" && p.VideoCodec != "hevc" {
+		return fmt.Errorf("unsupported video codec %q", p.VideoCodec)
+	}
+	if p.Width < 16 || p.Height < 16 || p.Width > 7680 || p.Height > 4320 || p.Width%2 != 0 || p.Height%2 != 0 {
+		return fmt.Errorf("resolution must be even and between 16x16 and 7680x4320")
+	}
+	if p.FPS < 1 || p.FPS > 120 {
+		return fmt.Errorf("fps must be between 1 and 120")
+	}
+	if p.GOPSeconds <= 0 || p.GOPSeconds > 10 {
+		return fmt.Errorf("GOP length must be between 0 and 10 seconds")
+	}
+	if p.VideoBitrate < 0 {
+		return fmt.Errorf("video bitrate cannot be negative")
+	}
+	if p.AudioCodec != "aac" {
+		return fmt.Errorf("unsupported audio codec %q", p.AudioCodec)
+	}
+	if p.AudioSampleRate != 44100 && p.AudioSampleRate != 48000 {
+		return fmt.Errorf("audio sample rate must be 44100 or 48000")
+	}
+	if p.AudioChannels != 1 && p.AudioChannels != 2 {
+		return fmt.Errorf("audio must be mono or stereo")
+	}
+	if p.AudioBitrate < 32 || p.AudioBitrate > 512 {
+		return fmt.Errorf("audio bitrate must be between 32 and 512 kbit/s")
+	}
+	if p.LoudnessMode != LoudnessOff && p.LoudnessMode != LoudnessTranscode {
+		return fmt.Errorf("loudness mode must be off or transcode")
+	}
+	if p.TargetLUFS < -70 || p.TargetLUFS > -5 {
+		return fmt.Errorf("target loudness must be between -70 and -5 LUFS")
+	}
+	if p.TruePeak < -9 || p.TruePeak > 0 {
+		return fmt.Errorf("true peak must be between -9 and 0 dBTP")
+	}
+	if len(p.AudioLanguages) > maxAudioLanguages {
+		return fmt.Errorf("at most %d audio languages are supported", maxAudioLanguages)
+	}
+	seen := make(map[string]bool, len(p.AudioLanguages))
+	for _, lang := range p.AudioLanguages {
+		if !audioLanguagePattern.MatchString(lang) {
+			return fmt.Errorf("invalid audio language %q", lang)
+		}
+		if seen[strings.ToLower(lang)] {
+			return fmt.Errorf("audio language %q is listed twice", lang)
+		}
+		seen[strings.ToLower(lang)] = true
+	}
+	return nil
+}
+
+// GOPFrames is the keyframe interval in frames
+func (p *TranscodeProfile) GOPFrames() int {
+	frames := int(p.GOPSeconds*float64(p.FPS) + 0.5)
+	if frames < 1 {
+		frames = 1
+	}
+	return frames
+}
+
+// Equal reports whether two profiles produce interchangeable output, so a video
+// transcoded for one channel can be played on the other
+func (p *TranscodeProfile) Equal(o *TranscodeProfile) bool {
+	if p == nil || o == nil || len(p.AudioLanguages) != len(o.AudioLanguages) {
+		return false
+	}
+	for i := range p.AudioLanguages {
+		if !strings.EqualFold(p.AudioLanguages[i], o.AudioLanguages[i]) {
+			return false
+		}
+	}
+	return p.VideoCodec == o.VideoCodec && p.Width == o.Width && p.Height == o.Height &&
+		p.FPS == o.FPS && p.GOPSeconds == o.GOPSeconds && p.VideoBitrate == o.VideoBitrate &&
+		p.AudioCodec == o.AudioCodec && p.AudioSampleRate == o.AudioSampleRate &&
+		p.AudioChannels == o.AudioChannels && p.AudioBitrate == o.AudioBitrate &&
+		p.LoudnessMode == o.LoudnessMode && p.TargetLUFS == o.TargetLUFS && p.TruePeak == o.TruePeak
+}
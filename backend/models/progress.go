@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// IngestStage names a step of the ingest pipeline
+type IngestStage string
+
+const (
+	StageMetadata  IngestStage = "metadata"
+	StageDownload  IngestStage = "download"
+	StageTranscode IngestStage = "transcode"
+	StageUpload    IngestStage = "upload"
+	StageDone      IngestStage = "done"
+)
+
+// IngestProgress is a point-in-time progress report for a video being ingested
+type IngestProgress struct {
+	VideoID    string      `json:"videoId"`
+	JobID      string      `json:"jobId"`
+	ChannelID  int         `json:"channelId"`
+	Stage      IngestStage `json:"stage"`
+	Status     VideoStatus `json:"status"`
+	Percent    float64     `json:"percent"`              // Percent of the current stage, 0-100
+	BytesDone  int64       `json:"bytesDone,omitempty"`  // Bytes processed in the current stage
+	BytesTotal int64       `json:"bytesTotal,omitempty"` // Total bytes for the current stage if known
+	SpeedBps   float64     `json:"speedBps,omitempty"`   // Throughput in bytes per second
+	ErrorMsg   string      `json:"errorMsg,omitempty"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Subtitle track kinds, as in the HTML <track kind> attribute
+const (
+	SubtitleKindSubtitles = "subtitles"
+	SubtitleKindCaptions  = "captions"
+)
+
+// Where a subtitle track came from
+const (
+	SubtitleSourceUploaded = "uploaded" // Sidecar SRT/VTT file added by an admin
+	SubtitleSourceEmbedded = "embedded" // Text stream inside the ingested media file
+	SubtitleSourceRemote   = "remote"   // Subtitles published alongside a yt-dlp source
+	SubtitleSourceAuto     = "auto"     // Automatic captions published by a yt-dlp source
+)
+
+// SubtitleTrack is one language of timed text stored as WebVTT in S3
+type SubtitleTrack struct {
+	Language string `json:"language"`        // BCP 47 tag, e.g. "en" or "pt-BR"
+	Label    string `json:"label,omitempty"` // Name shown in the player menu
+	Kind     string `json:"kind"`
+	Source   string `json:"source"`
+	URL      string `json:"url"` // Relative to /api, like thumbnail URLs
+}
+
+// SubtitleCue is one timed line of a subtitle track. Times are seconds into the video.
+type SubtitleCue struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
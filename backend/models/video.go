@@ -13,6 +13,7 @@ const (
 	StatusProcessing  VideoStatus = "processing"
 	StatusCompleted   VideoStatus = "completed"
 	StatusFailed      VideoStatus = "failed"
+	StatusCancelled   VideoStatus = "cancelled"
 )
 
 // Update the existing Video struct to include admin fields
@@ -39,6 +40,17 @@ type AdminVideo struct {
 	URL          string      `json:"url,omitempty"`
 	ThumbnailURL string      `json:"thumbnailUrl,omitempty"`
 	DisplayOrder int         `json:"displayOrder,omitempty"`
+	ProgressStage   string   `json:"progressStage,omitempty"`   // Latest ingest stage reported
+	ProgressPercent float64  `json:"progressPercent,omitempty"` // Percent complete within ProgressStage
+	SourceID     string      `json:"sourceId,omitempty"` // Extractor-qualified remote ID, e.g. "youtube:dQw4w9WgXcQ"
+	Profile      *TranscodeProfile `json:"profile,omitempty"` // Output profile the video was transcoded to
+	Loudness     *LoudnessMeasurement `json:"loudness,omitempty"` // EBU R128 measurement of the source audio
+	SpriteURL    string      `json:"spriteUrl,omitempty"`    // Preview sprite sheet for scrubbing
+	SpriteVTTURL string      `json:"spriteVttUrl,omitempty"` // WebVTT track mapping times to sprite tiles
+	Subtitles    []SubtitleTrack `json:"subtitles,omitempty"`
+	AudioTracks  []AudioTrack `json:"audioTracks,omitempty"` // Alternate audio renditions, e.g. dubs
+	AssetID      string      `json:"assetId,omitempty"` // Library asset this playlist entry airs
+	Checksum     string      `json:"checksum,omitempty"` // SHA-256 of the stored video object
 }
 
 // User represents an admin user who can upload videos
@@ -47,7 +59,33 @@ type User struct {
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
 	Password  string    `json:"-"` // Password hash, not returned in JSON
-	Role      string    `json:"role"` // "admin" or "user"
+	Role      string    `json:"role"` // "owner", "programmer" or "uploader"
+	Channels  []int     `json:"channels"` // Channels the user may work on; empty means all
+	Disabled  bool      `json:"disabled"` // Disabled users cannot sign in
+	MustChangePassword bool `json:"mustChangePassword"` // Set for passwords chosen by someone else
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+// Session is a logged-in user, identified by the token in their session cookie
+type Session struct {
+	Token     string
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// APIKey lets an automation client act for the user who created it, limited to some of
+// that user's permissions and channels. Only a hash of the secret is stored.
+type APIKey struct {
+	ID          string     `json:"id"`
+	Prefix      string     `json:"prefix"` // Start of the secret, to tell keys apart
+	Name        string     `json:"name"`
+	UserID      string     `json:"userId"`
+	Permissions []string   `json:"permissions"`
+	Channels    []int      `json:"channels"` // Empty means every channel the user has
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+}
@@ -0,0 +1,1281 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"live-broadcast-backend/database"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Memory implements every repository in memory with the semantics of the database
+// implementation, for unit tests of handlers and services
+type Memory struct {
+	mu       sync.Mutex
+	videos   map[string]*memVideo
+	order    map[string]int // video_order: explicit playlist positions
+	assets   map[string]*memAsset
+	channels map[int]*memChannel
+	users    map[string]*memUser
+	sessions map[string]models.Session
+	apiKeys  map[string]*memAPIKey
+	jobs     map[string]*models.IngestJob
+}
+
+type memVideo struct {
+	models.AdminVideo
+	contentHash    string
+	perceptualHash string
+}
+
+type memAsset struct {
+	models.MediaAsset
+	contentHash    string
+	perceptualHash string
+}
+
+type memChannel struct {
+	models.Channel
+	profile *models.TranscodeProfile
+}
+
+type memAPIKey struct {
+	models.APIKey
+	secret string
+}
+
+type memUser struct {
+	models.User
+	password string // bcrypt hash
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		videos:   make(map[string]*memVideo),
+		order:    make(map[string]int),
+		assets:   make(map[string]*memAsset),
+		channels: make(map[int]*memChannel),
+		users:    make(map[string]*memUser),
+		sessions: make(map[string]models.Session),
+		apiKeys:  make(map[string]*memAPIKey),
+		jobs:     make(map[string]*models.IngestJob),
+	}
+}
+
+// AddUser stores a user with the given password, hashed at the lowest bcrypt cost to
+// keep tests fast. A missing ID or timestamp is filled in.
+func (m *Memory) AddUser(user models.User, password string) (*models.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+		user.UpdatedAt = user.CreatedAt
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[user.ID] = &memUser{User: user, password: string(hash)}
+	return &user, nil
+}
+
+/* ---------- videos ---------- */
+
+// adminVideo copies a stored video the way the database reads it back
+func (v *memVideo) adminVideo() *models.AdminVideo {
+	video := v.AdminVideo
+	video.Subtitles = append([]models.SubtitleTrack(nil), v.Subtitles...)
+	video.AudioTracks = append([]models.AudioTrack(nil), v.AudioTracks...)
+	if video.ThumbnailURL == "" {
+		video.ThumbnailURL = keys.PreviewURL(keys.Thumbnail(video.ID))
+	}
+	video.URL = fmt.Sprintf("/videos/%s", video.S3Key)
+	return &video
+}
+
+func (m *Memory) SaveVideo(video *models.AdminVideo) error {
+	if video.ID == "" {
+		video.ID = uuid.New().String()
+	}
+	if video.CreatedAt.IsZero() {
+		video.CreatedAt = time.Now()
+	}
+	if video.UpdatedAt.IsZero() {
+		video.UpdatedAt = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.channels[video.ChannelID]; !ok {
+		return fmt.Errorf("channel %d not found", video.ChannelID)
+	}
+	v, ok := m.videos[video.ID]
+	if !ok {
+		m.videos[video.ID] = &memVideo{AdminVideo: models.AdminVideo{
+			ID:           video.ID,
+			YoutubeURL:   video.YoutubeURL,
+			S3Key:        video.S3Key,
+			ChannelID:    video.ChannelID,
+			Title:        video.Title,
+			Description:  video.Description,
+			Status:       video.Status,
+			ErrorMsg:     video.ErrorMsg,
+			UploadedBy:   video.UploadedBy,
+			Duration:     video.Duration,
+			CreatedAt:    video.CreatedAt,
+			UpdatedAt:    video.UpdatedAt,
+			DisplayOrder: video.DisplayOrder,
+			ThumbnailURL: video.ThumbnailURL,
+			SourceID:     video.SourceID,
+		}}
+		return nil
+	}
+	v.YoutubeURL = video.YoutubeURL
+	v.S3Key = video.S3Key
+	v.ChannelID = video.ChannelID
+	v.Title = video.Title
+	v.Description = video.Description
+	v.Status = video.Status
+	v.ErrorMsg = video.ErrorMsg
+	v.Duration = video.Duration
+	v.UpdatedAt = video.UpdatedAt
+	v.DisplayOrder = video.DisplayOrder
+	v.ThumbnailURL = video.ThumbnailURL
+	return nil
+}
+
+func (m *Memory) GetVideoByID(id string) (*models.AdminVideo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.videos[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return v.adminVideo(), nil
+}
+
+func (m *Memory) GetChannelVideosWithDetails(channelID int) ([]models.AdminVideo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := m.channelVideos(channelID, func(v *memVideo) bool { return true })
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].DisplayOrder != entries[j].DisplayOrder {
+			return entries[i].DisplayOrder < entries[j].DisplayOrder
+		}
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+
+	var videos []models.AdminVideo
+	for _, v := range entries {
+		videos = append(videos, *v.adminVideo())
+	}
+	return videos, nil
+}
+
+// channelVideos returns the channel's videos that match keep, in no particular order
+func (m *Memory) channelVideos(channelID int, keep func(v *memVideo) bool) []*memVideo {
+	var videos []*memVideo
+	for _, v := range m.videos {
+		if v.ChannelID == channelID && keep(v) {
+			videos = append(videos, v)
+		}
+	}
+	return videos
+}
+
+func (m *Memory) DeleteVideo(videoID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.videos[videoID]; !ok {
+		return fmt.Errorf("video with ID %s not found", videoID)
+	}
+	delete(m.videos, videoID)
+	delete(m.order, videoID)
+	return nil
+}
+
+func (m *Memory) UpdateVideoOrder(channelID int, videoOrders map[string]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range videoOrders {
+		if v, ok := m.videos[id]; !ok || v.ChannelID != channelID {
+			return database.ErrVideoNotOnChannel
+		}
+	}
+	for id := range m.order {
+		if v, ok := m.videos[id]; ok && v.ChannelID == channelID {
+			delete(m.order, id)
+		}
+	}
+	for id, order := range videoOrders {
+		m.order[id] = order
+		m.videos[id].DisplayOrder = order
+	}
+	return nil
+}
+
+func (m *Memory) ReorderVideosAfterDeletion(channelID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	position := func(v *memVideo) int {
+		if order, ok := m.order[v.ID]; ok {
+			return order
+		}
+		return 9999
+	}
+	videos := m.channelVideos(channelID, func(v *memVideo) bool { return true })
+	sort.SliceStable(videos, func(i, j int) bool {
+		if position(videos[i]) != position(videos[j]) {
+			return position(videos[i]) < position(videos[j])
+		}
+		return videos[i].CreatedAt.Before(videos[j].CreatedAt)
+	})
+	for i, v := range videos {
+		m.order[v.ID] = i + 1
+		v.DisplayOrder = i + 1
+	}
+	return nil
+}
+
+// updateVideo applies fn to video id, which need not exist, like an UPDATE
+func (m *Memory) updateVideo(id string, fn func(v *memVideo)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.videos[id]; ok {
+		fn(v)
+	}
+	return nil
+}
+
+func (m *Memory) UpdateVideoStatus(id string, status models.VideoStatus, errorMsg string) error {
+	return m.updateVideo(id, func(v *memVideo) {
+		v.Status = status
+		v.ErrorMsg = errorMsg
+		v.UpdatedAt = time.Now()
+	})
+}
+
+func (m *Memory) UpdateVideoProgress(id string, stage string, percent float64) error {
+	return m.updateVideo(id, func(v *memVideo) {
+		v.ProgressStage = stage
+		v.ProgressPercent = percent
+	})
+}
+
+// updateMedia applies video to video id or all entries of library asset id, and asset
+// to that asset, like the database's updateMedia
+func (m *Memory) updateMedia(id string, video func(v *memVideo), asset func(a *memAsset)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.videos {
+		if v.ID == id || v.AssetID == id {
+			video(v)
+		}
+	}
+	if a, ok := m.assets[id]; ok {
+		asset(a)
+	}
+	return nil
+}
+
+func (m *Memory) UpdateVideoProfile(id string, profile *models.TranscodeProfile) error {
+	return m.updateMedia(id, func(v *memVideo) { v.Profile = profile }, func(a *memAsset) { a.Profile = profile })
+}
+
+func (m *Memory) UpdateVideoThumbnails(id, thumbnailURL, spriteURL, spriteVTTURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.videos {
+		if v.ID == id || v.AssetID == id {
+			if thumbnailURL != "" {
+				v.ThumbnailURL = thumbnailURL
+			}
+			v.SpriteURL, v.SpriteVTTURL = spriteURL, spriteVTTURL
+		}
+	}
+	if a, ok := m.assets[id]; ok {
+		if thumbnailURL != "" {
+			a.ThumbnailURL = thumbnailURL
+		}
+		a.SpriteURL, a.SpriteVTTURL = spriteURL, spriteVTTURL
+	}
+	return nil
+}
+
+func (m *Memory) UpdateVideoLoudness(id string, loudness *models.LoudnessMeasurement) error {
+	return m.updateMedia(id, func(v *memVideo) { v.Loudness = loudness }, func(a *memAsset) { a.Loudness = loudness })
+}
+
+func (m *Memory) UpdateVideoSubtitles(id string, tracks []models.SubtitleTrack) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(tracks) == 0 {
+		tracks = nil
+	}
+	now := time.Now()
+	for _, v := range m.videos {
+		if v.ID == id || v.AssetID == id {
+			v.Subtitles = append([]models.SubtitleTrack(nil), tracks...)
+			v.UpdatedAt = now
+		}
+	}
+	if a, ok := m.assets[id]; ok {
+		a.Subtitles = append([]models.SubtitleTrack(nil), tracks...)
+		a.UpdatedAt = now
+	}
+	return nil
+}
+
+func (m *Memory) UpdateVideoAudioTracks(id string, tracks []models.AudioTrack) error {
+	return m.updateMedia(id,
+		func(v *memVideo) { v.AudioTracks = append([]models.AudioTrack(nil), tracks...) },
+		func(a *memAsset) { a.AudioTracks = append([]models.AudioTrack(nil), tracks...) })
+}
+
+func (m *Memory) UpdateVideoHashes(id, contentHash, perceptualHash string) error {
+	return m.updateVideo(id, func(v *memVideo) { v.contentHash, v.perceptualHash = contentHash, perceptualHash })
+}
+
+func (m *Memory) UpdateVideoChecksum(id, checksum string) error {
+	return m.updateMedia(id, func(v *memVideo) { v.Checksum = checksum }, func(a *memAsset) { a.Checksum = checksum })
+}
+
+func (m *Memory) GetSubtitles(id string) ([]models.SubtitleTrack, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.assets[id]; ok {
+		return append([]models.SubtitleTrack(nil), a.Subtitles...), nil
+	}
+	if v, ok := m.videos[id]; ok {
+		return append([]models.SubtitleTrack(nil), v.Subtitles...), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+// earliest returns the ID of the oldest video matching keep, or ""
+func (m *Memory) earliest(keep func(v *memVideo) bool) string {
+	var found *memVideo
+	for _, v := range m.videos {
+		if keep(v) && (found == nil || v.CreatedAt.Before(found.CreatedAt)) {
+			found = v
+		}
+	}
+	if found == nil {
+		return ""
+	}
+	return found.ID
+}
+
+func (m *Memory) FindVideoBySource(channelID int, sourceID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.earliest(func(v *memVideo) bool {
+		return sourceID != "" && v.ChannelID == channelID && v.SourceID == sourceID &&
+			v.Status != models.StatusFailed && v.Status != models.StatusCancelled
+	}), nil
+}
+
+func (m *Memory) FindCompletedVideoBySource(sourceID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.earliest(func(v *memVideo) bool {
+		return sourceID != "" && v.SourceID == sourceID && v.Status == models.StatusCompleted
+	}), nil
+}
+
+func (m *Memory) FindVideoByContentHash(contentHash, excludeID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.earliest(func(v *memVideo) bool {
+		return contentHash != "" && v.contentHash == contentHash && v.ID != excludeID && v.Status == models.StatusCompleted
+	}), nil
+}
+
+func (m *Memory) ListPerceptualHashes(minDuration, maxDuration float64, excludeID string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hashes := make(map[string]string)
+	for _, v := range m.videos {
+		if v.perceptualHash != "" && v.ID != excludeID && v.Status == models.StatusCompleted &&
+			v.Duration >= minDuration && v.Duration <= maxDuration {
+			hashes[v.ID] = v.perceptualHash
+		}
+	}
+	return hashes, nil
+}
+
+func (m *Memory) LinkVideo(id, existingID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.videos[id]
+	o, found := m.videos[existingID]
+	if !ok || !found {
+		return fmt.Errorf("video %s or %s not found", id, existingID)
+	}
+	v.S3Key = o.S3Key
+	v.Duration = o.Duration
+	if v.Title == "" || v.Title == "Processing..." {
+		v.Title = o.Title
+	}
+	if v.Description == "" {
+		v.Description = o.Description
+	}
+	v.ThumbnailURL = o.ThumbnailURL
+	v.SpriteURL, v.SpriteVTTURL = o.SpriteURL, o.SpriteVTTURL
+	v.Subtitles = append([]models.SubtitleTrack(nil), o.Subtitles...)
+	v.AudioTracks = append([]models.AudioTrack(nil), o.AudioTracks...)
+	v.Loudness = o.Loudness
+	v.Profile = o.Profile
+	if v.contentHash == "" {
+		v.contentHash = o.contentHash
+	}
+	if v.perceptualHash == "" {
+		v.perceptualHash = o.perceptualHash
+	}
+	v.Checksum = o.Checksum
+	v.AssetID = o.AssetID
+	v.Status = models.StatusCompleted
+	v.ErrorMsg = ""
+	v.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *Memory) GetChecksumByS3Key(s3Key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.videos {
+		if v.S3Key == s3Key && v.Checksum != "" {
+			return v.Checksum, nil
+		}
+	}
+	return "", nil
+}
+
+func (m *Memory) CountVideosByS3Key(s3Key, excludeID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, v := range m.videos {
+		if v.S3Key == s3Key && v.ID != excludeID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) ReferencedMedia() (map[string]bool, map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s3Keys := make(map[string]bool)
+	ids := make(map[string]bool)
+	add := func(id, s3Key string) {
+		ids[id] = true
+		if s3Key != "" {
+			s3Keys[s3Key] = true
+		}
+	}
+	for _, v := range m.videos {
+		add(v.ID, v.S3Key)
+	}
+	for _, a := range m.assets {
+		add(a.ID, a.S3Key)
+	}
+	return s3Keys, ids, nil
+}
+
+/* ---------- library ---------- */
+
+func (m *Memory) CreateAssetFromVideo(videoID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.videos[videoID]
+	if !ok {
+		return nil
+	}
+
+	a, exists := m.assets[videoID]
+	if !exists {
+		a = &memAsset{MediaAsset: models.MediaAsset{
+			ID:        v.ID,
+			SourceID:  v.SourceID,
+			SourceURL: v.YoutubeURL,
+			CreatedBy: v.UploadedBy,
+			CreatedAt: v.CreatedAt,
+		}}
+		m.assets[videoID] = a
+	}
+	a.S3Key = v.S3Key
+	a.Title = v.Title
+	a.Description = v.Description
+	a.Duration = v.Duration
+	a.ThumbnailURL = v.ThumbnailURL
+	a.SpriteURL, a.SpriteVTTURL = v.SpriteURL, v.SpriteVTTURL
+	a.Profile = v.Profile
+	a.Loudness = v.Loudness
+	a.Subtitles = append([]models.SubtitleTrack(nil), v.Subtitles...)
+	a.AudioTracks = append([]models.AudioTrack(nil), v.AudioTracks...)
+	a.contentHash, a.perceptualHash = v.contentHash, v.perceptualHash
+	a.Checksum = v.Checksum
+	a.UpdatedAt = time.Now()
+	v.AssetID = videoID
+	return nil
+}
+
+// mediaAsset copies a stored asset with the channels whose playlist includes it
+func (m *Memory) mediaAsset(a *memAsset) *models.MediaAsset {
+	asset := a.MediaAsset
+	asset.Subtitles = append([]models.SubtitleTrack(nil), a.Subtitles...)
+	asset.AudioTracks = append([]models.AudioTrack(nil), a.AudioTracks...)
+	asset.Channels = []int{}
+	seen := make(map[int]bool)
+	for _, v := range m.videos {
+		if v.AssetID == a.ID && !seen[v.ChannelID] {
+			seen[v.ChannelID] = true
+			asset.Channels = append(asset.Channels, v.ChannelID)
+		}
+	}
+	sort.Ints(asset.Channels)
+	return &asset
+}
+
+func (m *Memory) GetMediaAsset(id string) (*models.MediaAsset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.assets[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return m.mediaAsset(a), nil
+}
+
+func (m *Memory) ListMediaAssets() ([]*models.MediaAsset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assets := []*models.MediaAsset{}
+	for _, a := range m.assets {
+		assets = append(assets, m.mediaAsset(a))
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].CreatedAt.After(assets[j].CreatedAt) })
+	return assets, nil
+}
+
+func (m *Memory) AddAssetToChannel(assetID string, channelID int, addedBy string) (*models.AdminVideo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.channelVideos(channelID, func(v *memVideo) bool { return v.AssetID == assetID })) > 0 {
+		return nil, database.ErrAssetOnChannel
+	}
+	a, ok := m.assets[assetID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if _, ok := m.channels[channelID]; !ok {
+		return nil, fmt.Errorf("channel %d not found", channelID)
+	}
+
+	order := 0
+	for _, v := range m.channelVideos(channelID, func(v *memVideo) bool { return true }) {
+		if v.DisplayOrder > order {
+			order = v.DisplayOrder
+		}
+	}
+	now := time.Now()
+	v := &memVideo{
+		AdminVideo: models.AdminVideo{
+			ID:           uuid.New().String(),
+			YoutubeURL:   a.SourceURL,
+			S3Key:        a.S3Key,
+			ChannelID:    channelID,
+			Title:        a.Title,
+			Description:  a.Description,
+			Status:       models.StatusCompleted,
+			UploadedBy:   addedBy,
+			Duration:     a.Duration,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			DisplayOrder: order + 1,
+			ThumbnailURL: a.ThumbnailURL,
+			SourceID:     a.SourceID,
+			Profile:      a.Profile,
+			Loudness:     a.Loudness,
+			SpriteURL:    a.SpriteURL,
+			SpriteVTTURL: a.SpriteVTTURL,
+			Subtitles:    append([]models.SubtitleTrack(nil), a.Subtitles...),
+			AudioTracks:  append([]models.AudioTrack(nil), a.AudioTracks...),
+			AssetID:      a.ID,
+			Checksum:     a.Checksum,
+		},
+		contentHash:    a.contentHash,
+		perceptualHash: a.perceptualHash,
+	}
+	m.videos[v.ID] = v
+	return v.adminVideo(), nil
+}
+
+func (m *Memory) DeleteMediaAsset(id string) ([]string, []int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.assets[id]
+	if !ok {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	videoKeys := []string{a.S3Key}
+	var channels []int
+	seenKeys := map[string]bool{a.S3Key: true}
+	seenChannels := make(map[int]bool)
+	for vid, v := range m.videos {
+		if v.AssetID != id {
+			continue
+		}
+		if v.S3Key != "" && !seenKeys[v.S3Key] {
+			seenKeys[v.S3Key] = true
+			videoKeys = append(videoKeys, v.S3Key)
+		}
+		if !seenChannels[v.ChannelID] {
+			seenChannels[v.ChannelID] = true
+			channels = append(channels, v.ChannelID)
+		}
+		delete(m.videos, vid)
+		delete(m.order, vid)
+	}
+	delete(m.assets, id)
+	return videoKeys, channels, nil
+}
+
+/* ---------- channels ---------- */
+
+func (m *Memory) CreateDefaultChannels() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.channels) > 0 {
+		return nil
+	}
+	for _, channel := range models.PredefinedChannels() {
+		m.channels[channel.Number] = &memChannel{Channel: *channel}
+	}
+	return nil
+}
+
+func (m *Memory) GetAllChannels() ([]*models.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var channels []*models.Channel
+	for _, c := range m.channels {
+		channel := c.Channel
+		channels = append(channels, &channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Number < channels[j].Number })
+	return channels, nil
+}
+
+func (m *Memory) GetChannel(channelNumber int) (*models.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.channels[channelNumber]
+	if !ok {
+		return nil, fmt.Errorf("channel %d not found", channelNumber)
+	}
+	channel := c.Channel
+	return &channel, nil
+}
+
+func (m *Memory) GetChannelVideos(channelNumber int) ([]*models.Video, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := m.channelVideos(channelNumber, func(v *memVideo) bool { return v.Status == models.StatusCompleted })
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].DisplayOrder != entries[j].DisplayOrder {
+			return entries[i].DisplayOrder < entries[j].DisplayOrder
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	var videos []*models.Video
+	for _, e := range entries {
+		v := e.adminVideo()
+		video := &models.Video{
+			ID:           v.ID,
+			Title:        v.Title,
+			Description:  v.Description,
+			Duration:     v.Duration,
+			S3Key:        v.S3Key,
+			Tags:         []string{fmt.Sprintf("channel_%d", channelNumber)},
+			CreatedAt:    v.CreatedAt,
+			URL:          v.URL,
+			ThumbnailURL: v.ThumbnailURL,
+			SpriteURL:    v.SpriteURL,
+			SpriteVTTURL: v.SpriteVTTURL,
+			Subtitles:    v.Subtitles,
+			AudioTracks:  v.AudioTracks,
+		}
+		if video.Duration == 0 {
+			video.Duration = 300
+		}
+		videos = append(videos, video)
+	}
+	return videos, nil
+}
+
+func (m *Memory) GetChannelProfile(channelNumber int) (*models.TranscodeProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.channels[channelNumber]
+	if !ok {
+		return nil, fmt.Errorf("channel %d not found", channelNumber)
+	}
+	if c.profile != nil {
+		profile := *c.profile
+		return &profile, nil
+	}
+	return models.DefaultTranscodeProfile(), nil
+}
+
+func (m *Memory) UpdateChannelProfile(channelNumber int, profile *models.TranscodeProfile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.channels[channelNumber]
+	if !ok {
+		return fmt.Errorf("channel %d not found", channelNumber)
+	}
+	stored := *profile
+	c.profile = &stored
+	return nil
+}
+
+/* ---------- users ---------- */
+
+func (m *Memory) ValidateUser(username, password string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username != username {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(u.password), []byte(password)) != nil {
+			return nil, fmt.Errorf("invalid credentials")
+		}
+		user := u.User
+		return &user, nil
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *Memory) GetUser(userID string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return u.user(), nil
+}
+
+// user copies a stored user the way the database reads it back
+func (u *memUser) user() *models.User {
+	user := u.User
+	user.Channels = append([]int(nil), u.Channels...)
+	return &user
+}
+
+func (m *Memory) ListUsers() ([]*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := []*models.User{}
+	for _, u := range m.users {
+		users = append(users, u.user())
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// emailTaken reports whether another user has the email; the caller holds m.mu
+func (m *Memory) emailTaken(email, excludeID string) bool {
+	for id, u := range m.users {
+		if id != excludeID && u.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) CreateUser(user *models.User, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == user.Username {
+			return database.ErrUserExists
+		}
+	}
+	if m.emailTaken(user.Email, "") {
+		return database.ErrUserExists
+	}
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	stored := &memUser{User: *user, password: string(hash)}
+	stored.Channels = append([]int(nil), user.Channels...)
+	m.users[user.ID] = stored
+	return nil
+}
+
+func (m *Memory) UpdateUser(user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[user.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if m.emailTaken(user.Email, user.ID) {
+		return database.ErrUserExists
+	}
+	user.UpdatedAt = time.Now()
+	u.Email = user.Email
+	u.Role = user.Role
+	u.Disabled = user.Disabled
+	u.Channels = append([]int(nil), user.Channels...)
+	u.UpdatedAt = user.UpdatedAt
+	return nil
+}
+
+func (m *Memory) SetPassword(userID, password string, mustChange bool) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.password = string(hash)
+	u.MustChangePassword = mustChange
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *Memory) DeleteUser(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.users, userID)
+	for token, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, token)
+		}
+	}
+	for id, key := range m.apiKeys {
+		if key.UserID == userID {
+			delete(m.apiKeys, id)
+		}
+	}
+	return nil
+}
+
+/* ---------- sessions ---------- */
+
+func (m *Memory) CreateSession(session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	m.sessions[session.Token] = *session
+	return nil
+}
+
+func (m *Memory) GetSession(token string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[token]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (m *Memory) RenewSession(token string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[token]; ok {
+		session.ExpiresAt = expiresAt
+		m.sessions[token] = session
+	}
+	return nil
+}
+
+func (m *Memory) DeleteSession(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, token)
+	return nil
+}
+
+func (m *Memory) DeleteUserSessions(userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for token, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, token)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *Memory) PurgeExpiredSessions() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	purged := 0
+	for token, session := range m.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(m.sessions, token)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+/* ---------- API keys ---------- */
+
+// apiKey copies a stored key the way the database reads it back
+func (k *memAPIKey) apiKey() *models.APIKey {
+	key := k.APIKey
+	key.Permissions = append([]string(nil), k.Permissions...)
+	if len(k.Channels) > 0 {
+		key.Channels = append([]int(nil), k.Channels...)
+	} else {
+		key.Channels = nil
+	}
+	if k.LastUsedAt != nil {
+		usedAt := *k.LastUsedAt
+		key.LastUsedAt = &usedAt
+	}
+	if k.RevokedAt != nil {
+		revokedAt := *k.RevokedAt
+		key.RevokedAt = &revokedAt
+	}
+	return &key
+}
+
+func (m *Memory) CreateAPIKey(key *models.APIKey, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := &memAPIKey{APIKey: *key, secret: secret}
+	m.apiKeys[key.ID] = stored
+	stored.APIKey = *stored.apiKey()
+	return nil
+}
+
+func (m *Memory) GetAPIKey(secret string) (*models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.apiKeys {
+		if k.secret == secret {
+			return k.apiKey(), nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Memory) GetAPIKeyByID(id string) (*models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.apiKeys[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return k.apiKey(), nil
+}
+
+func (m *Memory) ListAPIKeys(userID string) ([]*models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []*models.APIKey{}
+	for _, k := range m.apiKeys {
+		if userID == "" || k.UserID == userID {
+			keys = append(keys, k.apiKey())
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (m *Memory) TouchAPIKey(id string, usedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.apiKeys[id]; ok {
+		k.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func (m *Memory) RevokeAPIKey(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.apiKeys[id]
+	if !ok || k.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+	return nil
+}
+
+func (m *Memory) RevokeUserAPIKeys(userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, k := range m.apiKeys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			k.RevokedAt = &now
+			n++
+		}
+	}
+	return n, nil
+}
+
+/* ---------- jobs ---------- */
+
+// copyJob returns a copy of a stored job that shares no pointers with it
+func copyJob(job *models.IngestJob) *models.IngestJob {
+	c := *job
+	if job.StartedAt != nil {
+		startedAt := *job.StartedAt
+		c.StartedAt = &startedAt
+	}
+	if job.FinishedAt != nil {
+		finishedAt := *job.FinishedAt
+		c.FinishedAt = &finishedAt
+	}
+	return &c
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) CreateJob(job *models.IngestJob) error {
+	if job.ID == "" {
+		job.ID = uuid.New().String()
+	}
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	if job.NextRunAt.IsZero() {
+		job.NextRunAt = now
+	}
+	job.UpdatedAt = now
+	if job.Status == "" {
+		job.Status = models.JobQueued
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[job.ID]; ok {
+		return fmt.Errorf("job %s already exists", job.ID)
+	}
+	m.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (m *Memory) GetJob(id string) (*models.IngestJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyJob(job), nil
+}
+
+func (m *Memory) ListJobs(status models.JobStatus, channels []int, limit int) ([]models.IngestJob, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []models.IngestJob{}
+	for _, job := range m.jobs {
+		if status != "" && job.Status != status {
+			continue
+		}
+		if channels != nil && !containsInt(channels, job.ChannelID) {
+			continue
+		}
+		jobs = append(jobs, *copyJob(job))
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (m *Memory) ClaimNextJob() (*models.IngestJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var next *models.IngestJob
+	for _, job := range m.jobs {
+		if job.Status != models.JobQueued || job.NextRunAt.After(now) {
+			continue
+		}
+		if next == nil || job.NextRunAt.Before(next.NextRunAt) ||
+			(job.NextRunAt.Equal(next.NextRunAt) && job.CreatedAt.Before(next.CreatedAt)) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	next.Status = models.JobRunning
+	next.Attempts++
+	next.StartedAt = &now
+	next.UpdatedAt = now
+	return copyJob(next), nil
+}
+
+// updateJob applies fn to job id when it is in one of the given states and reports
+// whether it did
+func (m *Memory) updateJob(id string, fn func(job *models.IngestJob, now time.Time), states ...models.JobStatus) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return false
+	}
+	for _, state := range states {
+		if job.Status == state {
+			now := time.Now()
+			fn(job, now)
+			job.UpdatedAt = now
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) CompleteJob(id string) error {
+	m.updateJob(id, func(job *models.IngestJob, now time.Time) {
+		job.Status = models.JobCompleted
+		job.LastError = ""
+		job.FinishedAt = &now
+	}, models.JobRunning)
+	return nil
+}
+
+func (m *Memory) FailJob(id string, errorMsg string) error {
+	m.updateJob(id, func(job *models.IngestJob, now time.Time) {
+		job.Status = models.JobFailed
+		job.LastError = errorMsg
+		job.FinishedAt = &now
+	}, models.JobRunning)
+	return nil
+}
+
+func (m *Memory) RescheduleJob(id string, errorMsg string, nextRunAt time.Time) error {
+	m.updateJob(id, func(job *models.IngestJob, now time.Time) {
+		job.Status = models.JobQueued
+		job.LastError = errorMsg
+		job.NextRunAt = nextRunAt
+	}, models.JobRunning)
+	return nil
+}
+
+func (m *Memory) CancelJob(id string) (bool, error) {
+	return m.updateJob(id, func(job *models.IngestJob, now time.Time) {
+		job.Status = models.JobCancelled
+		job.FinishedAt = &now
+	}, models.JobQueued, models.JobRunning), nil
+}
+
+func (m *Memory) RequeueJob(id string) (bool, error) {
+	return m.updateJob(id, func(job *models.IngestJob, now time.Time) {
+		job.Status = models.JobQueued
+		job.Attempts = 0
+		job.LastError = ""
+		job.NextRunAt = now
+		job.StartedAt = nil
+		job.FinishedAt = nil
+	}, models.JobFailed, models.JobCancelled), nil
+}
+
+func (m *Memory) HeartbeatJob(id string) (bool, error) {
+	return m.updateJob(id, func(job *models.IngestJob, now time.Time) {}, models.JobRunning), nil
+}
+
+func (m *Memory) RecoverStuckJobs(staleBefore time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	requeued := 0
+	for _, job := range m.jobs {
+		if job.Status == models.JobRunning && job.UpdatedAt.Before(staleBefore) {
+			job.Status = models.JobQueued
+			job.NextRunAt = now
+			job.UpdatedAt = now
+			requeued++
+		}
+	}
+
+	live := make(map[string]models.JobStatus)
+	for _, job := range m.jobs {
+		if job.Status == models.JobQueued || job.Status == models.JobRunning {
+			live[job.VideoID] = job.Status
+		}
+	}
+	for _, v := range m.videos {
+		switch v.Status {
+		case models.StatusDownloading, models.StatusProcessing:
+			if live[v.ID] == models.JobQueued {
+				v.Status = models.StatusPending
+				v.UpdatedAt = now
+				continue
+			}
+		case models.StatusPending:
+		default:
+			continue
+		}
+		if _, ok := live[v.ID]; !ok && v.UpdatedAt.Before(staleBefore) {
+			v.Status = models.StatusFailed
+			v.ErrorMsg = "Ingest was interrupted by a server restart"
+			v.UpdatedAt = now
+		}
+	}
+	return requeued, nil
+}
@@ -0,0 +1,128 @@
+// Package repository defines the storage interfaces handlers and services depend on.
+// *database.DB implements them against Postgres or SQLite; Memory implements them in
+// memory for unit tests.
+package repository
+
+import (
+	"time"
+
+	"live-broadcast-backend/database"
+	"live-broadcast-backend/models"
+)
+
+// VideoRepository stores playlist entries and the media recorded for them
+type VideoRepository interface {
+	SaveVideo(video *models.AdminVideo) error
+	GetVideoByID(id string) (*models.AdminVideo, error)
+	GetChannelVideosWithDetails(channelID int) ([]models.AdminVideo, error)
+	DeleteVideo(videoID string) error
+	UpdateVideoOrder(channelID int, videoOrders map[string]int) error
+	ReorderVideosAfterDeletion(channelID int) error
+
+	UpdateVideoStatus(id string, status models.VideoStatus, errorMsg string) error
+	UpdateVideoProgress(id string, stage string, percent float64) error
+	UpdateVideoProfile(id string, profile *models.TranscodeProfile) error
+	UpdateVideoThumbnails(id, thumbnailURL, spriteURL, spriteVTTURL string) error
+	UpdateVideoLoudness(id string, loudness *models.LoudnessMeasurement) error
+	UpdateVideoSubtitles(id string, tracks []models.SubtitleTrack) error
+	UpdateVideoAudioTracks(id string, tracks []models.AudioTrack) error
+	UpdateVideoHashes(id, contentHash, perceptualHash string) error
+	UpdateVideoChecksum(id, checksum string) error
+	GetSubtitles(id string) ([]models.SubtitleTrack, error)
+
+	FindVideoBySource(channelID int, sourceID string) (string, error)
+	FindCompletedVideoBySource(sourceID string) (string, error)
+	FindVideoByContentHash(contentHash, excludeID string) (string, error)
+	ListPerceptualHashes(minDuration, maxDuration float64, excludeID string) (map[string]string, error)
+	LinkVideo(id, existingID string) error
+
+	GetChecksumByS3Key(s3Key string) (string, error)
+	CountVideosByS3Key(s3Key, excludeID string) (int, error)
+	ReferencedMedia() (map[string]bool, map[string]bool, error)
+}
+
+// LibraryRepository stores media library assets
+type LibraryRepository interface {
+	CreateAssetFromVideo(videoID string) error
+	GetMediaAsset(id string) (*models.MediaAsset, error)
+	ListMediaAssets() ([]*models.MediaAsset, error)
+	AddAssetToChannel(assetID string, channelID int, addedBy string) (*models.AdminVideo, error)
+	DeleteMediaAsset(id string) ([]string, []int, error)
+}
+
+// ChannelRepository stores channels and reads their playout lists
+type ChannelRepository interface {
+	CreateDefaultChannels() error
+	GetAllChannels() ([]*models.Channel, error)
+	GetChannel(channelNumber int) (*models.Channel, error)
+	GetChannelVideos(channelNumber int) ([]*models.Video, error)
+	GetChannelProfile(channelNumber int) (*models.TranscodeProfile, error)
+	UpdateChannelProfile(channelNumber int, profile *models.TranscodeProfile) error
+}
+
+// UserRepository authenticates and manages users
+type UserRepository interface {
+	ValidateUser(username, password string) (*models.User, error)
+	GetUser(userID string) (*models.User, error)
+	ListUsers() ([]*models.User, error)
+	CreateUser(user *models.User, password string) error
+	UpdateUser(user *models.User) error
+	SetPassword(userID, password string, mustChange bool) error
+	DeleteUser(userID string) error
+}
+
+// SessionRepository stores login sessions by token
+type SessionRepository interface {
+	CreateSession(session *models.Session) error
+	// GetSession returns nil without error when the token is unknown or expired
+	GetSession(token string) (*models.Session, error)
+	RenewSession(token string, expiresAt time.Time) error
+	DeleteSession(token string) error
+	DeleteUserSessions(userID string) (int, error)
+	PurgeExpiredSessions() (int, error)
+}
+
+// APIKeyRepository stores API keys by the hash of their secret
+type APIKeyRepository interface {
+	CreateAPIKey(key *models.APIKey, secret string) error
+	// GetAPIKey returns nil without error when no key has the secret
+	GetAPIKey(secret string) (*models.APIKey, error)
+	GetAPIKeyByID(id string) (*models.APIKey, error)
+	ListAPIKeys(userID string) ([]*models.APIKey, error)
+	TouchAPIKey(id string, usedAt time.Time) error
+	RevokeAPIKey(id string) error
+	RevokeUserAPIKeys(userID string) (int, error)
+}
+
+// JobRepository stores the ingest job queue
+type JobRepository interface {
+	CreateJob(job *models.IngestJob) error
+	// GetJob returns sql.ErrNoRows when there is no such job
+	GetJob(id string) (*models.IngestJob, error)
+	ListJobs(status models.JobStatus, channels []int, limit int) ([]models.IngestJob, error)
+	ClaimNextJob() (*models.IngestJob, error)
+	CompleteJob(id string) error
+	FailJob(id string, errorMsg string) error
+	RescheduleJob(id string, errorMsg string, nextRunAt time.Time) error
+	CancelJob(id string) (bool, error)
+	RequeueJob(id string) (bool, error)
+	HeartbeatJob(id string) (bool, error)
+	RecoverStuckJobs(staleBefore time.Time) (int, error)
+}
+
+// Store is every repository backed by the database; *database.DB and Memory implement
+// all of it, and components take only the repositories they use
+type Store interface {
+	VideoRepository
+	LibraryRepository
+	ChannelRepository
+	UserRepository
+	SessionRepository
+	APIKeyRepository
+	JobRepository
+}
+
+var (
+	_ Store = (*database.DB)(nil)
+	_ Store = (*Memory)(nil)
+)
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyPrefix starts every API key secret so they are easy to spot in leaked text
+	apiKeyPrefix = "lbk_"
+	// apiKeyBytes is the amount of randomness in an API key secret
+	apiKeyBytes = 32
+	// apiKeyTouchInterval is how stale a key's last-used time may get before it is
+	// written again, so busy clients do not write on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyManager issues, checks and revokes API keys
+type APIKeyManager struct {
+	keys       repository.APIKeyRepository
+	defaultTTL time.Duration
+}
+
+// NewAPIKeyManager creates an API key manager whose keys last defaultTTL unless created
+// with another lifetime
+func NewAPIKeyManager(keys repository.APIKeyRepository, defaultTTL time.Duration) *APIKeyManager {
+	return &APIKeyManager{keys: keys, defaultTTL: defaultTTL}
+}
+
+// Create issues a key for key.UserID valid for ttl, or the default lifetime when ttl is
+// zero, and returns its secret, which is not stored and cannot be shown again
+func (m *APIKeyManager) Create(key *models.APIKey, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = m.defaultTTL
+	}
+	buf := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %v", err)
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	key.ID = uuid.New().String()
+	key.Prefix = secret[:len(apiKeyPrefix)+6]
+	key.CreatedAt = time.Now()
+	key.ExpiresAt = key.CreatedAt.Add(ttl)
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+	if err := m.keys.CreateAPIKey(key, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Lookup returns the live key with a secret, recording its use, or nil when the secret
+// is unknown, revoked or expired
+func (m *APIKeyManager) Lookup(secret string) (*models.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, nil
+	}
+	key, err := m.keys.GetAPIKey(secret)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || now.After(key.ExpiresAt) {
+		return nil, nil
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := m.keys.TouchAPIKey(key.ID, now); err != nil {
+			log.Printf("Warning: Error recording use of API key %s: %v", key.ID, err)
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+// Get returns one key by ID
+func (m *APIKeyManager) Get(id string) (*models.APIKey, error) {
+	return m.keys.GetAPIKeyByID(id)
+}
+
+// List returns a user's keys or, when userID is empty, everyone's
+func (m *APIKeyManager) List(userID string) ([]*models.APIKey, error) {
+	return m.keys.ListAPIKeys(userID)
+}
+
+// Revoke stops a key from working
+func (m *APIKeyManager) Revoke(id string) error {
+	return m.keys.RevokeAPIKey(id)
+}
+
+// RevokeAll stops every key of a user from working and returns how many were live
+func (m *APIKeyManager) RevokeAll(userID string) (int, error) {
+	return m.keys.RevokeUserAPIKeys(userID)
+}
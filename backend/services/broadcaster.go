@@ -20,6 +20,11 @@ type Broadcaster struct {
 	initSegment   []byte        // cached ftyp+moov
 	mu            sync.Mutex
 	clients       map[*client]struct{}
+
+	// latest fragment sent to clients, with the init segment it belongs to
+	keyframeInit []byte
+	keyframeFrag []byte
+	keyframeAt   time.Time
 }
 
 type client struct {
@@ -124,6 +129,7 @@ func (b *Broadcaster) loop() {
 
 			/* fan‑out to clients */
 			b.mu.Lock()
+			b.keyframeInit, b.keyframeFrag, b.keyframeAt = b.initSegment, frag, time.Now()
 			for cl := range b.clients {
 				if _, werr := cl.w.Write(frag); werr == nil {
 					cl.w.(http.Flusher).Flush()
@@ -152,6 +158,15 @@ func (b *Broadcaster) CurrentPath() string {
 	return b.srcPath
 }
 
+// LatestKeyframe returns the fragment most recently sent to clients together with its
+// init segment. Sources are fragmented with frag_keyframe, so every fragment starts
+// on a keyframe and init+frag decodes on its own. frag is nil before the first one.
+func (b *Broadcaster) LatestKeyframe() (init, frag []byte, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.keyframeInit, b.keyframeFrag, b.keyframeAt
+}
+
 func readBox(r io.Reader) (mp4box, error) {
 	hdr := make([]byte, 8)
 	if _, err := io.ReadFull(r, hdr); err != nil {
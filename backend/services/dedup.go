@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"live-broadcast-backend/models"
+	"log"
+	"math"
+	"math/bits"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Perceptual hashing samples perceptualFrames evenly spaced frames, each reduced to a
+// 64-bit difference hash (dHash) of a 9x8 greyscale thumbnail
+const (
+	perceptualFrames    = 16
+	perceptualMatchBits = 8 // Highest mean per-frame Hamming distance still treated as the same video
+)
+
+// DuplicateError is returned for media already in the library under the reject policy
+type DuplicateError struct {
+	VideoID   string
+	ChannelID int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate of video %s on channel %d", e.VideoID, e.ChannelID)
+}
+
+// sourceHashes identifies a source file: its bytes and what it looks like
+type sourceHashes struct {
+	content    string // Hex SHA-256 of the file
+	perceptual string // Hex dHash per sampled frame, concatenated
+}
+
+// ContentHash returns the hex SHA-256 of the file at path
+func ContentHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// PerceptualHash decodes evenly spaced frames of the video at input and returns their
+// dHashes. Re-encodes, resizes and container changes of the same video hash alike.
+func PerceptualHash(ctx context.Context, input string, duration float64) (string, error) {
+	if duration <= 0 {
+		return "", fmt.Errorf("unknown duration")
+	}
+	spacing := duration / perceptualFrames
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-ss", fmt.Sprintf("%.3f", spacing/2),
+		"-i", input,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("fps=1/%.3f,scale=9:8:flags=area,format=gray", spacing),
+		"-frames:v", fmt.Sprint(perceptualFrames),
+		"-f", "rawvideo",
+		"pipe:1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %v - %s", err, stderr.String())
+	}
+
+	pixels := stdout.Bytes()
+	var hash strings.Builder
+	for len(pixels) >= 72 {
+		var frame uint64
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				frame <<= 1
+				if pixels[y*9+x] < pixels[y*9+x+1] {
+					frame |= 1
+				}
+			}
+		}
+		var word [8]byte
+		binary.BigEndian.PutUint64(word[:], frame)
+		hash.WriteString(hex.EncodeToString(word[:]))
+		pixels = pixels[72:]
+	}
+	if hash.Len() == 0 {
+		return "", fmt.Errorf("ffmpeg produced no frames")
+	}
+	return hash.String(), nil
+}
+
+// perceptualDistance is the mean number of differing bits per frame between two
+// perceptual hashes; ok is false when they cannot be compared
+func perceptualDistance(a, b string) (distance float64, ok bool) {
+	x, err1 := hex.DecodeString(a)
+	y, err2 := hex.DecodeString(b)
+	if err1 != nil || err2 != nil || len(x) != len(y) || len(x) == 0 || len(x)%8 != 0 {
+		return 0, false
+	}
+	total := 0
+	for i := 0; i < len(x); i += 8 {
+		total += bits.OnesCount64(binary.BigEndian.Uint64(x[i:]) ^ binary.BigEndian.Uint64(y[i:]))
+	}
+	return float64(total) / float64(len(x)/8), true
+}
+
+// DuplicatePolicy resolves a per-import policy against the pipeline default
+func (p *IngestPipeline) DuplicatePolicy(requested string) string {
+	if models.ValidDuplicatePolicy(requested) {
+		return requested
+	}
+	return p.duplicates
+}
+
+// hashSource computes the hashes of src. Either may be empty when it cannot be computed;
+// hashing never fails an ingest.
+func (p *IngestPipeline) hashSource(ctx context.Context, src IngestSource, duration float64) sourceHashes {
+	var hashes sourceHashes
+	var err error
+	if hashes.content, err = ContentHash(src.Path); err != nil {
+		log.Printf("Warning: Failed to hash source of video %s: %v", src.VideoID, err)
+	}
+	if hashes.perceptual, err = PerceptualHash(ctx, src.Path, duration); err != nil {
+		log.Printf("Warning: Failed to compute perceptual hash of video %s: %v", src.VideoID, err)
+	}
+	return hashes
+}
+
+// findDuplicate returns the ID of a completed video with the same content as the source
+// of videoID: byte-identical first, otherwise a perceptual match of similar duration.
+// It returns "" when there is none.
+func (p *IngestPipeline) findDuplicate(videoID string, hashes sourceHashes, duration float64) (string, error) {
+	if hashes.content != "" {
+		id, err := p.db.FindVideoByContentHash(hashes.content, videoID)
+		if err != nil || id != "" {
+			return id, err
+		}
+	}
+	if hashes.perceptual == "" || duration <= 0 {
+		return "", nil
+	}
+
+	tolerance := math.Max(1, duration*0.01)
+	candidates, err := p.db.ListPerceptualHashes(duration-tolerance, duration+tolerance, videoID)
+	if err != nil {
+		return "", err
+	}
+	best, bestDistance := "", float64(perceptualMatchBits)
+	for id, hash := range candidates {
+		if distance, ok := perceptualDistance(hashes.perceptual, hash); ok && distance <= bestDistance {
+			best, bestDistance = id, distance
+		}
+	}
+	return best, nil
+}
+
+// linkDuplicate completes videoID on channelID as another reference to the media of
+// existingID. ok is false, and nothing is changed, when the existing video was not
+// transcoded to the channel's profile and so cannot be shared.
+func (p *IngestPipeline) linkDuplicate(videoID string, channelID int, existingID string) (ok bool, err error) {
+	existing, err := p.db.GetVideoByID(existingID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load existing video: %v", err)
+	}
+	profile, err := p.channels.GetChannelProfile(channelID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load transcode profile: %v", err)
+	}
+	if !existing.Profile.Equal(profile) {
+		log.Printf("Video %s duplicates %s but channel %d uses a different transcode profile; transcoding a copy", videoID, existingID, channelID)
+		return false, nil
+	}
+	if err := p.db.LinkVideo(videoID, existingID); err != nil {
+		return false, fmt.Errorf("failed to link video: %v", err)
+	}
+	log.Printf("Video %s on channel %d now shares the media of video %s (%s)", videoID, channelID, existingID, existing.S3Key)
+	return true, nil
+}
+
+// handleDuplicate applies policy to a video found to duplicate existingID. done is true
+// when the video was linked and needs no further processing.
+func (p *IngestPipeline) handleDuplicate(videoID string, channelID int, existingID, policy string) (done bool, err error) {
+	switch policy {
+	case models.DuplicateReject:
+		existing, err := p.db.GetVideoByID(existingID)
+		if err != nil {
+			return false, fmt.Errorf("failed to load existing video: %v", err)
+		}
+		return false, Permanent(&DuplicateError{VideoID: existingID, ChannelID: existing.ChannelID})
+	case models.DuplicateLink:
+		return p.linkDuplicate(videoID, channelID, existingID)
+	}
+	return false, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"live-broadcast-backend/keys"
+	"log"
+)
+
+// DeletionReport lists what deleting a video or library asset removed
+type DeletionReport struct {
+	Objects    []string          `json:"objects"`          // Storage keys that were deleted
+	LocalFiles []string          `json:"localFiles"`       // Playout copies that were deleted
+	Failed     map[string]string `json:"failed,omitempty"` // Key or path -> error, for what is left behind
+	Kept       string            `json:"kept,omitempty"`   // Why the media itself was not deleted
+}
+
+// NewDeletionReport creates an empty report
+func NewDeletionReport() *DeletionReport {
+	return &DeletionReport{Objects: []string{}, LocalFiles: []string{}}
+}
+
+func (r *DeletionReport) fail(what string, err error) {
+	if r.Failed == nil {
+		r.Failed = make(map[string]string)
+	}
+	r.Failed[what] = err.Error()
+}
+
+// MediaDeleter removes a video's media and everything derived from it: the video
+// object, previews, subtitle tracks and local playout copies
+type MediaDeleter struct {
+	videoService *VideoService
+	s3Manager    *S3Manager
+}
+
+// NewMediaDeleter creates a deleter; s3Manager may be nil when nothing is cached locally
+func NewMediaDeleter(videoService *VideoService, s3Manager *S3Manager) *MediaDeleter {
+	return &MediaDeleter{videoService: videoService, s3Manager: s3Manager}
+}
+
+// DeleteMedia removes the video stored at videoKey and the previews and subtitles
+// stored under id, recording each removal in report. Failures are recorded and the
+// remaining artefacts are still deleted.
+func (d *MediaDeleter) DeleteMedia(ctx context.Context, id, videoKey string, report *DeletionReport) {
+	var objectKeys []string
+	if videoKey != "" {
+		objectKeys = append(objectKeys, videoKey)
+	}
+	objectKeys = append(objectKeys, keys.Previews(id)...)
+
+	subtitles, _, err := listAll(ctx, d.videoService.storage, ListOptions{Prefix: keys.SubtitlePrefix(id)})
+	if err != nil {
+		report.fail(keys.SubtitlePrefix(id), err)
+	}
+	for _, object := range subtitles {
+		objectKeys = append(objectKeys, object.Key)
+	}
+
+	for _, key := range objectKeys {
+		d.deleteObject(ctx, key, report)
+	}
+
+	if d.s3Manager != nil && videoKey != "" {
+		removed, err := d.s3Manager.RemoveLocalCopies(videoKey)
+		report.LocalFiles = append(report.LocalFiles, removed...)
+		if err != nil {
+			report.fail(keys.LocalCopy(d.s3Manager.videoDir, videoKey), err)
+		}
+	}
+	log.Printf("Deleted media of %s: %d objects, %d local files, %d failures",
+		id, len(report.Objects), len(report.LocalFiles), len(report.Failed))
+}
+
+// deleteObject deletes key when it exists, so the report only lists what was there
+func (d *MediaDeleter) deleteObject(ctx context.Context, key string, report *DeletionReport) {
+	if _, err := d.videoService.storage.Stat(ctx, key); err != nil {
+		if !errors.Is(err, ErrObjectNotFound) {
+			report.fail(key, err)
+		}
+		return
+	}
+	if err := d.videoService.storage.Delete(ctx, key); err != nil {
+		report.fail(key, err)
+		return
+	}
+	report.Objects = append(report.Objects, key)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const uploadSourcePrefix = "upload://"
+
+// FileIngestor turns completed uploads into channel videos via the ingest queue
+type FileIngestor struct {
+	db       repository.VideoRepository
+	queue    *JobQueue
+	pipeline *IngestPipeline
+	uploads  *UploadStore
+}
+
+// NewFileIngestor creates a file ingestor and registers it with the ingest queue
+func NewFileIngestor(db repository.VideoRepository, queue *JobQueue, pipeline *IngestPipeline, uploads *UploadStore) *FileIngestor {
+	fi := &FileIngestor{
+		db:       db,
+		queue:    queue,
+		pipeline: pipeline,
+		uploads:  uploads,
+	}
+	queue.Register(models.JobKindUpload, fi.handleJob)
+	return fi
+}
+
+// IngestUpload queues a fully received upload for processing and returns the new video ID
+func (fi *FileIngestor) IngestUpload(upload *UploadInfo, uploadedBy string) (string, error) {
+	if !upload.Complete() {
+		return "", fmt.Errorf("upload %s is incomplete (%d of %d bytes)", upload.ID, upload.Offset, upload.Size)
+	}
+	if upload.VideoID != "" {
+		return upload.VideoID, nil // already queued by an earlier request
+	}
+
+	videoID := uuid.New().String()
+	title := upload.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(upload.Filename), filepath.Ext(upload.Filename))
+	}
+
+	// Create a new video record in pending state
+	video := &models.AdminVideo{
+		ID:          videoID,
+		S3Key:       keys.LibraryVideo(videoID),
+		ChannelID:   upload.ChannelID,
+		Title:       title,
+		Description: upload.Description,
+		Status:      models.StatusPending,
+		UploadedBy:  uploadedBy,
+	}
+	if err := fi.db.SaveVideo(video); err != nil {
+		return "", fmt.Errorf("failed to save video to database: %v", err)
+	}
+
+	job := &models.IngestJob{
+		VideoID:   videoID,
+		Kind:      models.JobKindUpload,
+		SourceURL: uploadSourcePrefix + upload.ID,
+		ChannelID: upload.ChannelID,
+		CreatedBy: uploadedBy,
+	}
+	if err := fi.queue.Enqueue(job); err != nil {
+		fi.db.UpdateVideoStatus(videoID, models.StatusFailed, err.Error())
+		return "", err
+	}
+	if err := fi.uploads.SetVideoID(upload.ID, videoID); err != nil {
+		log.Printf("Warning: Failed to link upload %s to video %s: %v", upload.ID, videoID, err)
+	}
+
+	return videoID, nil
+}
+
+// handleJob is the JobHandler for uploaded file ingest jobs
+func (fi *FileIngestor) handleJob(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error {
+	uploadID := strings.TrimPrefix(job.SourceURL, uploadSourcePrefix)
+	path := fi.uploads.DataPath(uploadID)
+	if _, err := os.Stat(path); err != nil {
+		return Permanent(fmt.Errorf("uploaded file is no longer available: %v", err))
+	}
+
+	if err := fi.pipeline.Process(ctx, IngestSource{
+		VideoID:   job.VideoID,
+		ChannelID: job.ChannelID,
+		Path:      path,
+	}, progress); err != nil {
+		return err
+	}
+
+	// The source is only needed for retries; drop it once the video is live
+	if err := fi.uploads.Remove(uploadID); err != nil {
+		log.Printf("Warning: Failed to remove upload %s: %v", uploadID, err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package services
 
 import (
 	"fmt"
+	"live-broadcast-backend/keys"
 	"os"
 	"os/exec"
 	"strings"
@@ -25,18 +26,26 @@ func EnsureFragmented(inPath string) (string, error) {
 	}
 
 	// otherwise rewrite -> *.frag.mp4 alongside the original
-	outPath := strings.TrimSuffix(inPath, ".mp4") + ".frag.mp4"
-	cmd := exec.Command("ffmpeg",
-		"-y",
-		"-i", inPath,
-		"-c", "copy",
-		"-movflags", "+frag_keyframe+empty_moov+default_base_moof+dash",
-		outPath,
-	)
+	outPath := keys.Fragmented(inPath)
+	cmd := exec.Command("ffmpeg", fragmentArgs(inPath, outPath)...)
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	if err := cmd.Run(); err != nil {
 		return "", fmt.Errorf("ffmpeg failed: %w", err)
 	}
 	return outPath, nil
-}
\ No newline at end of file
+}
+
+// fragmentArgs are the ffmpeg arguments for an MSE-friendly stream-copy remux that
+// keeps every audio track, so viewers can still pick a language
+func fragmentArgs(inPath, outPath string) []string {
+	return []string{
+		"-y",
+		"-i", inPath,
+		"-map", "0:v:0",
+		"-map", "0:a?",
+		"-c", "copy",
+		"-movflags", "+frag_keyframe+empty_moov+default_base_moof+dash",
+		outPath,
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/repository"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Orphan is a stored object or cached file that nothing refers to
+type Orphan struct {
+	Key          string    `json:"key"` // Storage key, or local path for cached files
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	Expired      bool      `json:"expired"` // Older than the grace period, so it may be deleted
+	Deleted      bool      `json:"deleted"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// GCReport is the outcome of one garbage collection run
+type GCReport struct {
+	DryRun       bool      `json:"dryRun"`
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	GraceHours   float64   `json:"graceHours"`
+	Objects      []Orphan  `json:"objects"`
+	LocalFiles   []Orphan  `json:"localFiles"`
+	OrphanBytes  int64     `json:"orphanBytes"`
+	DeletedBytes int64     `json:"deletedBytes"`
+}
+
+// GarbageCollector deletes objects in storage and files in the local video cache that
+// no video or library asset refers to, e.g. uploads of failed ingests. Orphans are
+// only deleted once they are older than the grace period, so media being ingested
+// right now is never touched.
+type GarbageCollector struct {
+	videoService *VideoService
+	s3Manager    *S3Manager
+	db           repository.VideoRepository
+	grace        time.Duration
+
+	mu   sync.Mutex // One run at a time
+	last *GCReport
+}
+
+// NewGarbageCollector creates a collector that keeps orphans younger than grace
+func NewGarbageCollector(videoService *VideoService, s3Manager *S3Manager, db repository.VideoRepository, grace time.Duration) *GarbageCollector {
+	return &GarbageCollector{
+		videoService: videoService,
+		s3Manager:    s3Manager,
+		db:           db,
+		grace:        grace,
+	}
+}
+
+// Start runs the collector every interval in the background; an interval of zero
+// disables scheduled runs
+func (gc *GarbageCollector) Start(interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("Storage garbage collection disabled")
+		return
+	}
+	log.Printf("Starting storage garbage collection every %v (grace period %v)", interval, gc.grace)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := gc.Run(context.Background(), false); err != nil {
+				log.Printf("Storage garbage collection failed: %v", err)
+			}
+		}
+	}()
+}
+
+// LastReport returns the report of the last run that deleted, nil before the first one
+func (gc *GarbageCollector) LastReport() *GCReport {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.last
+}
+
+// Run finds orphans and, unless dryRun is set, deletes those past the grace period
+func (gc *GarbageCollector) Run(ctx context.Context, dryRun bool) (*GCReport, error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	report := &GCReport{
+		DryRun:     dryRun,
+		StartedAt:  time.Now(),
+		GraceHours: gc.grace.Hours(),
+		Objects:    []Orphan{},
+		LocalFiles: []Orphan{},
+	}
+
+	videoKeys, ids, err := gc.db.ReferencedMedia()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load referenced media: %v", err)
+	}
+	ingested, err := gc.scanChannelFolders(ctx, videoKeys, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gc.collectObjects(ctx, report, ingested, videoKeys, ids); err != nil {
+		return nil, err
+	}
+	if err := gc.collectLocalFiles(report, videoKeys); err != nil {
+		return nil, err
+	}
+
+	report.FinishedAt = time.Now()
+	if !dryRun {
+		gc.last = report
+	}
+	log.Printf("Storage garbage collection (dry run: %v): %d orphaned objects, %d orphaned local files, %d of %d bytes deleted",
+		dryRun, len(report.Objects), len(report.LocalFiles), report.DeletedBytes, report.OrphanBytes)
+	return report, nil
+}
+
+// scanChannelFolders marks videos placed in channel folders, and their previews, as
+// in use. It returns the ingested videos found there, which predate the library and
+// are checked against the database like library objects.
+func (gc *GarbageCollector) scanChannelFolders(ctx context.Context, videoKeys, ids map[string]bool) ([]ObjectInfo, error) {
+	_, folders, err := listAll(ctx, gc.videoService.storage, ListOptions{Delimiter: "/"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channel folders: %v", err)
+	}
+	var ingested []ObjectInfo
+	for _, folder := range folders {
+		channel := keys.ChannelNumber(folder)
+		if channel == 0 {
+			continue
+		}
+		objects, _, err := listAll(ctx, gc.videoService.storage, ListOptions{Prefix: folder})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", folder, err)
+		}
+		for _, object := range objects {
+			if keys.IsIngestedVideo(object.Key) {
+				ingested = append(ingested, object)
+				continue
+			}
+			videoKeys[object.Key] = true
+			ids[keys.SyncVideoID(object.Key, channel)] = true
+		}
+	}
+	return ingested, nil
+}
+
+// collectObjects checks the prefixes ingest writes to. Objects elsewhere in the bucket
+// are not ours and are never reported.
+func (gc *GarbageCollector) collectObjects(ctx context.Context, report *GCReport, candidates []ObjectInfo, videoKeys, ids map[string]bool) error {
+	for _, prefix := range []string{keys.LibraryPrefix, keys.ThumbnailPrefix, keys.SubtitlesPrefix} {
+		objects, _, err := listAll(ctx, gc.videoService.storage, ListOptions{Prefix: prefix})
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %v", prefix, err)
+		}
+		candidates = append(candidates, objects...)
+	}
+	for _, object := range candidates {
+		// Previews and subtitles belong to an ID, videos to their key; anything else
+		// under these prefixes (e.g. hand-placed thumbnails) is left alone
+		if id, ok := keys.Owner(object.Key); ok {
+			if ids[id] {
+				continue
+			}
+		} else if videoKeys[object.Key] || !(strings.HasPrefix(object.Key, keys.LibraryPrefix) || keys.IsIngestedVideo(object.Key)) {
+			continue
+		}
+
+		orphan := gc.orphan(object.Key, object.Size, object.LastModified)
+		if orphan.Expired && !report.DryRun {
+			if err := gc.videoService.storage.Delete(ctx, object.Key); err != nil {
+				orphan.Error = err.Error()
+			} else {
+				orphan.Deleted = true
+				report.DeletedBytes += object.Size
+			}
+		}
+		report.OrphanBytes += object.Size
+		report.Objects = append(report.Objects, orphan)
+	}
+	return nil
+}
+
+// collectLocalFiles checks the playout cache: downloaded copies and the fragmented
+// copies made from them belong to the key they were downloaded from
+func (gc *GarbageCollector) collectLocalFiles(report *GCReport, videoKeys map[string]bool) error {
+	if gc.s3Manager == nil {
+		return nil
+	}
+	root := gc.s3Manager.videoDir
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key := keys.Unfragmented(filepath.ToSlash(rel))
+		if videoKeys[key] {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		orphan := gc.orphan(path, info.Size(), info.ModTime())
+		if orphan.Expired && !report.DryRun {
+			if err := os.Remove(path); err != nil {
+				orphan.Error = err.Error()
+			} else {
+				orphan.Deleted = true
+				report.DeletedBytes += info.Size()
+				gc.s3Manager.mutex.Lock()
+				delete(gc.s3Manager.downloads, key)
+				gc.s3Manager.mutex.Unlock()
+			}
+		}
+		report.OrphanBytes += info.Size()
+		report.LocalFiles = append(report.LocalFiles, orphan)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %v", root, err)
+	}
+	return nil
+}
+
+func (gc *GarbageCollector) orphan(key string, size int64, lastModified time.Time) Orphan {
+	return Orphan{
+		Key:          key,
+		Size:         size,
+		LastModified: lastModified,
+		Expired:      time.Since(lastModified) >= gc.grace,
+	}
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// IngestSource describes a local media file ready to be turned into a channel video
+type IngestSource struct {
+	VideoID       string
+	ChannelID     int
+	Path          string // Local media file in any container ffmpeg can read
+	Title         string // Keeps the existing title when empty
+	Description   string
+	Duration      float64        // Seconds; probed from the file when zero
+	ThumbnailPath string         // Optional pre-fetched thumbnail; one is extracted when empty
+	Subtitles     []SubtitleFile // Sidecar subtitle files; they win over streams embedded in Path
+	Duplicates    string         // One of the models.Duplicate* policies; the pipeline default when empty
+}
+
+// SubtitleFile is a local SRT or WebVTT file to be stored as a video's track
+type SubtitleFile struct {
+	Path  string
+	Track models.SubtitleTrack // Language, label, kind and source; the URL is filled in on upload
+}
+
+// IngestPipeline probes, normalises, fragments and uploads media for every ingest source
+// (YouTube downloads, direct uploads) and records the result in the videos table
+type IngestPipeline struct {
+	videoService *VideoService
+	db           repository.VideoRepository
+	library      repository.LibraryRepository
+	channels     repository.ChannelRepository // Channel profiles to transcode to
+	thumbnails   *ThumbnailGenerator
+	subtitles    *SubtitleService
+	tempDir      string
+	duplicates   string // Default models.Duplicate* policy
+}
+
+// NewIngestPipeline creates a new ingest pipeline working in tempDir
+func NewIngestPipeline(videoService *VideoService, db repository.VideoRepository, library repository.LibraryRepository, channels repository.ChannelRepository, thumbnails *ThumbnailGenerator, subtitles *SubtitleService, tempDir, duplicates string) (*IngestPipeline, error) {
+	if !models.ValidDuplicatePolicy(duplicates) {
+		return nil, fmt.Errorf("invalid duplicate policy %q", duplicates)
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	return &IngestPipeline{
+		videoService: videoService,
+		db:           db,
+		library:      library,
+		channels:     channels,
+		thumbnails:   thumbnails,
+		subtitles:    subtitles,
+		tempDir:      tempDir,
+		duplicates:   duplicates,
+	}, nil
+}
+
+// Process runs the pipeline for src and marks the video completed on success
+func (p *IngestPipeline) Process(ctx context.Context, src IngestSource, progress *ProgressReporter) error {
+	if err := p.db.UpdateVideoStatus(src.VideoID, models.StatusProcessing, ""); err != nil {
+		return fmt.Errorf("failed to update video status to processing: %v", err)
+	}
+
+	// Probe the source; an unreadable file will not get better on retry
+	info, err := ProbeMedia(ctx, src.Path)
+	if err != nil {
+		return Permanent(fmt.Errorf("unreadable media file: %v", err))
+	}
+	if !info.HasVideo {
+		return Permanent(fmt.Errorf("media file has no video stream"))
+	}
+	duration := src.Duration
+	if duration <= 0 {
+		duration = info.Duration
+	}
+
+	// Look for the same media already in the library before spending a transcode on it
+	hashes := p.hashSource(ctx, src, duration)
+	if err := p.db.UpdateVideoHashes(src.VideoID, hashes.content, hashes.perceptual); err != nil {
+		log.Printf("Warning: Failed to record hashes for video %s: %v", src.VideoID, err)
+	}
+	if policy := p.DuplicatePolicy(src.Duplicates); policy != models.DuplicateAllow {
+		existingID, err := p.findDuplicate(src.VideoID, hashes, duration)
+		if err != nil {
+			return fmt.Errorf("failed to check for duplicate video: %v", err)
+		}
+		if existingID != "" {
+			linked, err := p.handleDuplicate(src.VideoID, src.ChannelID, existingID, policy)
+			if err != nil || linked {
+				return err
+			}
+		}
+	}
+
+	profile, err := p.channels.GetChannelProfile(src.ChannelID)
+	if err != nil {
+		return fmt.Errorf("failed to load transcode profile: %v", err)
+	}
+
+	// Pick the audio renditions to keep and measure each one's loudness so programmes
+	// can be levelled against each other
+	audio := planAudio(info, profile)
+	if err := p.measureAudio(ctx, src, audio, profile, progress); err != nil {
+		return err
+	}
+	loudness := audio[0].loudness
+
+	stamp := time.Now().Unix()
+	normalisedFile := filepath.Join(p.tempDir, fmt.Sprintf("%s_%d.frag.mp4", src.VideoID, stamp))
+	defer os.Remove(normalisedFile)
+
+	// Transcode to the channel profile as an MSE-friendly fragmented MP4
+	if err := p.normalise(ctx, src.Path, normalisedFile, info, audio, profile, duration, progress); err != nil {
+		return err
+	}
+
+	// The video becomes a library asset with the same ID, stored outside any channel folder
+	videoS3Key := keys.LibraryVideo(src.VideoID)
+
+	// Upload video to S3
+	progress.Stage(models.StageUpload)
+	checksum, err := uploadFile(ctx, p.videoService, normalisedFile, videoS3Key, "video/mp4", progress)
+	if err != nil {
+		return fmt.Errorf("video upload failed: %v", err)
+	}
+
+	// Thumbnail (the supplied one or a representative frame), sprite sheet and sprite track
+	previews, err := p.thumbnails.Generate(ctx, src.VideoID, normalisedFile, duration, src.ThumbnailPath)
+	if err != nil {
+		log.Printf("Warning: Failed to generate previews for video %s: %v", src.VideoID, err)
+		// Continue even if thumbnail generation fails
+		previews = &ThumbnailSet{}
+	}
+
+	// Subtitle tracks fetched alongside the source or embedded in it
+	subtitles := p.ingestSubtitles(ctx, src, info)
+
+	// Update database with success status and all metadata
+	video, err := p.db.GetVideoByID(src.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve video from database: %v", err)
+	}
+	if src.Title != "" {
+		video.Title = src.Title
+	}
+	if src.Description != "" {
+		video.Description = src.Description
+	}
+	video.Duration = duration
+	video.S3Key = videoS3Key
+	video.Status = models.StatusCompleted
+	video.ErrorMsg = ""
+	video.ThumbnailURL = previews.ThumbnailURL
+
+	if err := p.db.SaveVideo(video); err != nil {
+		return fmt.Errorf("failed to update video with metadata: %v", err)
+	}
+	if err := p.db.UpdateVideoProfile(src.VideoID, profile); err != nil {
+		log.Printf("Warning: Failed to record transcode profile for video %s: %v", src.VideoID, err)
+	}
+	if err := p.db.UpdateVideoThumbnails(src.VideoID, previews.ThumbnailURL, previews.SpriteURL, previews.SpriteVTTURL); err != nil {
+		log.Printf("Warning: Failed to record previews for video %s: %v", src.VideoID, err)
+	}
+	if len(subtitles) > 0 {
+		if _, err := p.subtitles.AddTracks(src.VideoID, subtitles...); err != nil {
+			log.Printf("Warning: Failed to record subtitles for video %s: %v", src.VideoID, err)
+		}
+	}
+	if err := p.db.UpdateVideoAudioTracks(src.VideoID, audioTracks(audio)); err != nil {
+		log.Printf("Warning: Failed to record audio tracks for video %s: %v", src.VideoID, err)
+	}
+	if loudness != nil {
+		if err := p.db.UpdateVideoLoudness(src.VideoID, loudness); err != nil {
+			log.Printf("Warning: Failed to record loudness for video %s: %v", src.VideoID, err)
+		}
+	}
+	if err := p.db.UpdateVideoChecksum(src.VideoID, checksum); err != nil {
+		log.Printf("Warning: Failed to record checksum for video %s: %v", src.VideoID, err)
+	}
+	// Left for the library migration at the next start when this fails
+	if err := p.library.CreateAssetFromVideo(src.VideoID); err != nil {
+		log.Printf("Warning: Failed to add video %s to the media library: %v", src.VideoID, err)
+	}
+
+	log.Printf("Successfully processed video %s for channel %d (duration: %.1f seconds)", src.VideoID, src.ChannelID, duration)
+	return nil
+}
+
+// ingestSubtitles stores the source's sidecar subtitle files and the text subtitle
+// streams embedded in the media file, one track per language. Failures are logged and
+// the track skipped; subtitles never fail an ingest.
+func (p *IngestPipeline) ingestSubtitles(ctx context.Context, src IngestSource, info *MediaInfo) []models.SubtitleTrack {
+	var tracks []models.SubtitleTrack
+	seen := make(map[string]bool)
+	store := func(track models.SubtitleTrack, data []byte) {
+		stored, err := p.subtitles.Store(ctx, src.VideoID, track, data)
+		if err != nil {
+			log.Printf("Warning: Skipping %s subtitles for video %s: %v", track.Language, src.VideoID, err)
+			return
+		}
+		seen[strings.ToLower(track.Language)] = true
+		tracks = append(tracks, stored)
+	}
+
+	for _, file := range src.Subtitles {
+		if seen[strings.ToLower(file.Track.Language)] {
+			continue
+		}
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			log.Printf("Warning: Skipping %s subtitles for video %s: %v", file.Track.Language, src.VideoID, err)
+			continue
+		}
+		store(file.Track, data)
+	}
+
+	for _, stream := range info.Subtitles {
+		track := models.SubtitleTrack{
+			Language: stream.Language,
+			Label:    stream.Title,
+			Kind:     models.SubtitleKindSubtitles,
+			Source:   models.SubtitleSourceEmbedded,
+		}
+		if track.Language == "" {
+			track.Language = "und"
+		}
+		if stream.Captions {
+			track.Kind = models.SubtitleKindCaptions
+		}
+		if seen[strings.ToLower(track.Language)] {
+			continue
+		}
+		data, err := extractSubtitleStream(ctx, src.Path, stream.Index)
+		if err != nil {
+			log.Printf("Warning: Skipping embedded %s subtitles for video %s: %v", track.Language, src.VideoID, err)
+			continue
+		}
+		store(track, data)
+	}
+	return tracks
+}
+
+// audioRendition is one audio track of the transcoded output
+type audioRendition struct {
+	track    models.AudioTrack
+	source   int // Source audio stream index; -1 for a silent track
+	loudness *models.LoudnessMeasurement
+}
+
+// planAudio picks the output audio tracks. Without profile languages the source's main
+// (default or first) stream is kept. Otherwise there is one track per profile language,
+// in order, taken from the source stream in that language or, when the source has no
+// such dub, from the main stream; every programme on the channel thus has the same
+// track layout. Sources without audio get silent tracks.
+func planAudio(info *MediaInfo, profile *models.TranscodeProfile) []*audioRendition {
+	main := -1
+	for _, stream := range info.Audio {
+		if stream.Default {
+			main = stream.Index
+			break
+		}
+	}
+	if main < 0 && len(info.Audio) > 0 {
+		main = 0
+	}
+
+	if len(profile.AudioLanguages) == 0 {
+		rendition := &audioRendition{source: main, track: models.AudioTrack{Default: true}}
+		if main >= 0 {
+			rendition.track.Language = NormaliseLanguage(info.Audio[main].Language)
+			rendition.track.Label = info.Audio[main].Title
+		}
+		return []*audioRendition{rendition}
+	}
+
+	audio := make([]*audioRendition, 0, len(profile.AudioLanguages))
+	for i, lang := range profile.AudioLanguages {
+		rendition := &audioRendition{
+			source: main,
+			track:  models.AudioTrack{Index: i, Language: lang, Default: i == 0, Fallback: true},
+		}
+		for _, stream := range info.Audio {
+			if SameLanguage(stream.Language, lang) {
+				rendition.source = stream.Index
+				rendition.track.Label = stream.Title
+				rendition.track.Fallback = false
+				break
+			}
+		}
+		audio = append(audio, rendition)
+	}
+	return audio
+}
+
+// measureAudio measures the loudness of every source stream the renditions use.
+// Failures are logged and leave that rendition unnormalised.
+func (p *IngestPipeline) measureAudio(ctx context.Context, src IngestSource, audio []*audioRendition, profile *models.TranscodeProfile, progress *ProgressReporter) error {
+	measured := make(map[int]*models.LoudnessMeasurement)
+	for _, rendition := range audio {
+		if rendition.source < 0 {
+			continue
+		}
+		m, ok := measured[rendition.source]
+		if !ok {
+			progress.Stage(models.StageTranscode)
+			var err error
+			m, err = MeasureLoudness(ctx, src.Path, rendition.source, profile)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				log.Printf("Warning: Skipping loudness normalisation of audio stream %d for video %s: %v", rendition.source, src.VideoID, err)
+			} else {
+				log.Printf("Video %s audio stream %d measured %.1f LUFS (%.1f dBTP)", src.VideoID, rendition.source, m.IntegratedLUFS, m.TruePeak)
+			}
+			measured[rendition.source] = m
+		}
+		rendition.loudness = m
+	}
+	return nil
+}
+
+// audioTracks lists the renditions as recorded on the video row
+func audioTracks(audio []*audioRendition) []models.AudioTrack {
+	tracks := make([]models.AudioTrack, len(audio))
+	for i, rendition := range audio {
+		tracks[i] = rendition.track
+	}
+	return tracks
+}
+
+// normalise transcodes any input to the channel profile: fixed frame size (letterboxed),
+// frame rate, keyframe interval and audio layout, written as fragmented MP4. Inputs
+// without audio get silent tracks so every programme has the same track layout.
+// In transcode loudness mode the measured audio is normalised to the channel target.
+func (p *IngestPipeline) normalise(ctx context.Context, inPath, outPath string, info *MediaInfo, audio []*audioRendition, profile *models.TranscodeProfile, duration float64, progress *ProgressReporter) error {
+	log.Printf("Transcoding %s (%s/%s %dx%d) to %s %dx%d@%d with %d audio track(s)", filepath.Base(inPath), info.VideoCodec, info.AudioCodec,
+		info.Width, info.Height, profile.VideoCodec, profile.Width, profile.Height, profile.FPS, len(audio))
+	progress.Stage(models.StageTranscode)
+
+	output, err := runFFmpegWithProgress(ctx, transcodeArgs(inPath, outPath, audio, profile), duration, progress, models.StageTranscode)
+	if err != nil {
+		return fmt.Errorf("transcoding failed: %v - %s", err, string(output))
+	}
+	progress.Update(models.StageTranscode, 100, 0, 0, 0)
+	return nil
+}
+
+// transcodeArgs builds the ffmpeg arguments for a profile transcode. The MP4 muxer puts
+// every audio track in one alternate group and enables only the default one, so
+// players see the extra tracks as alternate renditions.
+func transcodeArgs(inPath, outPath string, audio []*audioRendition, profile *models.TranscodeProfile) []string {
+	gop := strconv.Itoa(profile.GOPFrames())
+	args := []string{"-y", "-i", inPath}
+
+	silent := false
+	for _, rendition := range audio {
+		if rendition.source < 0 {
+			silent = true
+		}
+	}
+	if silent {
+		layout := "stereo"
+		if profile.AudioChannels == 1 {
+			layout = "mono"
+		}
+		args = append(args, "-f", "lavfi", "-i", fmt.Sprintf("anullsrc=r=%d:cl=%s", profile.AudioSampleRate, layout), "-shortest")
+	}
+
+	args = append(args, "-map", "0:v:0")
+	for _, rendition := range audio {
+		if rendition.source < 0 {
+			args = append(args, "-map", "1:a:0")
+		} else {
+			args = append(args, "-map", fmt.Sprintf("0:a:%d", rendition.source))
+		}
+	}
+
+	args = append(args, "-vf", fmt.Sprintf(
+		"scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease,pad=%[1]d:%[2]d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%[3]d,format=yuv420p",
+		profile.Width, profile.Height, profile.FPS))
+
+	switch profile.VideoCodec {
+	case "hevc":
+		args = append(args, "-c:v", "libx265", "-preset", "fast", "-tag:v", "hvc1",
+			"-x265-params", fmt.Sprintf("keyint=%[1]s:min-keyint=%[1]s:scenecut=0", gop))
+	default:
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-profile:v", "high",
+			"-g", gop, "-keyint_min", gop, "-sc_threshold", "0")
+	}
+	if profile.VideoBitrate > 0 {
+		rate := strconv.Itoa(profile.VideoBitrate) + "k"
+		args = append(args, "-b:v", rate, "-maxrate", rate, "-bufsize", strconv.Itoa(profile.VideoBitrate*2)+"k")
+	} else {
+		args = append(args, "-crf", "21")
+	}
+
+	for i, rendition := range audio {
+		stream := fmt.Sprintf(":a:%d", i)
+		if rendition.loudness != nil && profile.LoudnessMode == models.LoudnessTranscode {
+			args = append(args, "-filter"+stream, loudnormFilter(rendition.loudness, profile))
+		}
+		disposition := "0"
+		if rendition.track.Default {
+			disposition = "default"
+		}
+		args = append(args,
+			"-metadata:s"+stream, "language="+containerLanguage(rendition.track.Language),
+			"-disposition"+stream, disposition)
+		if rendition.track.Label != "" {
+			args = append(args, "-metadata:s"+stream, "title="+rendition.track.Label)
+		}
+	}
+
+	return append(args,
+		"-c:a", "aac",
+		"-ar", strconv.Itoa(profile.AudioSampleRate),
+		"-ac", strconv.Itoa(profile.AudioChannels),
+		"-b:a", strconv.Itoa(profile.AudioBitrate)+"k",
+		"-movflags", "+frag_keyframe+empty_moov+default_base_moof+dash",
+		outPath,
+	)
+}
+
+// multipartThreshold is the file size from which uploads are split into parts
+const multipartThreshold = 64 << 20
+
+// uploadFile stores a file under key, reporting upload progress when a reporter is
+// given, and returns the file's hex SHA-256
+func uploadFile(ctx context.Context, videoService *VideoService, filePath, key string, contentType string, progress *ProgressReporter) (string, error) {
+	// Open the file
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	// Get file info for content length
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to get file info: %v", err)
+	}
+	size := fileInfo.Size()
+
+	checksum, err := ContentHash(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if multipart, ok := videoService.storage.(MultipartStorage); ok && size >= multipartThreshold {
+		var uploaded int64
+		started := time.Now()
+		err = multipart.PutMultipart(ctx, key, file, size, contentType, checksum, func(n int64) {
+			done := atomic.AddInt64(&uploaded, n)
+			progress.Update(models.StageUpload, 0, done, size, float64(done)/time.Since(started).Seconds())
+		})
+	} else {
+		err = videoService.storage.Put(ctx, key, newProgressReader(file, size, progress, models.StageUpload), size, contentType)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to store %s: %v", key, err)
+	}
+
+	return checksum, nil
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"sync"
+	"time"
+)
+
+// JobHandler processes a single ingest job, reporting progress as it goes. Returning an
+// error schedules a retry unless the error is wrapped with Permanent or the attempt budget is exhausted.
+type JobHandler func(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error
+
+// permanentError marks a job failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job queue fails the job without further retries
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// JobQueue runs persisted ingest jobs on a bounded pool of workers
+type JobQueue struct {
+	jobs         repository.JobRepository
+	videos       repository.VideoRepository // Failed jobs fail their video
+	progress     *ProgressHub
+	concurrency  int
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	lease        time.Duration // A running job without a heartbeat for this long is requeued
+	handlers     map[models.JobKind]JobHandler
+	wake         chan struct{}
+	mu           sync.Mutex
+	running      map[string]context.CancelFunc
+}
+
+// NewJobQueue creates a job queue with the given worker count and attempt budget
+func NewJobQueue(jobs repository.JobRepository, videos repository.VideoRepository, progress *ProgressHub, concurrency int, maxAttempts int) *JobQueue {
+	if concurrency <= 0 {
+		concurrency = 2 // Default to two parallel ingests if invalid
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	return &JobQueue{
+		jobs:         jobs,
+		videos:       videos,
+		progress:     progress,
+		concurrency:  concurrency,
+		maxAttempts:  maxAttempts,
+		baseBackoff:  30 * time.Second,
+		maxBackoff:   30 * time.Minute,
+		pollInterval: 5 * time.Second,
+		lease:        2 * time.Minute,
+		handlers:     make(map[models.JobKind]JobHandler),
+		wake:         make(chan struct{}, 1),
+		running:      make(map[string]context.CancelFunc),
+	}
+}
+
+// Register sets the handler used for jobs of the given kind
+func (q *JobQueue) Register(kind models.JobKind, handler JobHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = handler
+}
+
+// Start launches the workers and periodically requeues jobs whose worker stopped, in
+// this process before a restart or in another replica
+func (q *JobQueue) Start() {
+	q.recoverStuckJobs()
+
+	log.Printf("Starting ingest job queue with %d workers (max %d attempts per job)", q.concurrency, q.maxAttempts)
+	for i := 0; i < q.concurrency; i++ {
+		go q.worker(i + 1)
+	}
+
+	go func() {
+		ticker := time.NewTicker(q.lease)
+		defer ticker.Stop()
+		for range ticker.C {
+			q.recoverStuckJobs()
+		}
+	}()
+}
+
+// recoverStuckJobs requeues running jobs that have had no heartbeat for a lease
+func (q *JobQueue) recoverStuckJobs() {
+	recovered, err := q.jobs.RecoverStuckJobs(time.Now().Add(-q.lease))
+	if err != nil {
+		log.Printf("Error recovering stuck ingest jobs: %v", err)
+		return
+	}
+	if recovered > 0 {
+		log.Printf("Requeued %d ingest jobs whose worker stopped", recovered)
+		q.notify()
+	}
+}
+
+// Enqueue persists a new job and wakes an idle worker
+func (q *JobQueue) Enqueue(job *models.IngestJob) error {
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.maxAttempts
+	}
+	if err := q.jobs.CreateJob(job); err != nil {
+		return fmt.Errorf("failed to create ingest job: %v", err)
+	}
+	q.notify()
+	return nil
+}
+
+// Retry requeues a failed or cancelled job
+func (q *JobQueue) Retry(jobID string) (*models.IngestJob, error) {
+	ok, err := q.jobs.RequeueJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.jobs.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return job, fmt.Errorf("job %s is %s and cannot be retried", jobID, job.Status)
+	}
+
+	q.updateVideoStatus(job, models.StatusPending, "")
+	q.notify()
+	return job, nil
+}
+
+// Cancel stops a queued or running job
+func (q *JobQueue) Cancel(jobID string) (*models.IngestJob, error) {
+	ok, err := q.jobs.CancelJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.jobs.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return job, fmt.Errorf("job %s is already %s", jobID, job.Status)
+	}
+
+	// Interrupt the worker if the job is in flight
+	q.mu.Lock()
+	if cancel, exists := q.running[jobID]; exists {
+		cancel()
+	}
+	q.mu.Unlock()
+
+	q.updateVideoStatus(job, models.StatusCancelled, "Cancelled by admin")
+	q.progress.Reporter(job).Finish(models.StatusCancelled, "Cancelled by admin")
+	return job, nil
+}
+
+// List returns recent jobs, optionally filtered by status, on the given channels (nil for all)
+func (q *JobQueue) List(status models.JobStatus, channels []int, limit int) ([]models.IngestJob, error) {
+	return q.jobs.ListJobs(status, channels, limit)
+}
+
+// updateVideoStatus mirrors a job's state on its video; expand jobs have none
+func (q *JobQueue) updateVideoStatus(job *models.IngestJob, status models.VideoStatus, errorMsg string) {
+	if job.VideoID == "" {
+		return
+	}
+	if err := q.videos.UpdateVideoStatus(job.VideoID, status, errorMsg); err != nil {
+		log.Printf("Error setting video %s to %s: %v", job.VideoID, status, err)
+	}
+}
+
+// notify wakes one idle worker without blocking
+func (q *JobQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker claims and runs jobs until the process exits
+func (q *JobQueue) worker(n int) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := q.jobs.ClaimNextJob()
+		if err != nil {
+			log.Printf("Ingest worker %d: error claiming job: %v", n, err)
+		}
+		if job == nil {
+			select {
+			case <-q.wake:
+			case <-ticker.C:
+			}
+			continue
+		}
+
+		q.run(n, job)
+	}
+}
+
+// run executes one claimed job and records its outcome
+func (q *JobQueue) run(n int, job *models.IngestJob) {
+	q.mu.Lock()
+	handler := q.handlers[job.Kind]
+	ctx, cancel := context.WithCancel(context.Background())
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	reporter := q.progress.Reporter(job)
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+		cancel()
+	}()
+
+	log.Printf("Ingest worker %d: running job %s (%s, attempt %d/%d)", n, job.ID, job.Kind, job.Attempts, job.MaxAttempts)
+	go q.heartbeat(ctx, cancel, job.ID)
+
+	var err error
+	if handler == nil {
+		err = Permanent(fmt.Errorf("no handler registered for job kind %q", job.Kind))
+	} else {
+		err = q.safeCall(ctx, handler, job, reporter)
+	}
+
+	// A cancelled job has already been recorded as such by Cancel
+	if ctx.Err() != nil {
+		log.Printf("Ingest worker %d: job %s was cancelled", n, job.ID)
+		return
+	}
+
+	if err == nil {
+		if err := q.jobs.CompleteJob(job.ID); err != nil {
+			log.Printf("Error marking job %s completed: %v", job.ID, err)
+		}
+		reporter.Finish(models.StatusCompleted, "")
+		log.Printf("Ingest worker %d: job %s completed", n, job.ID)
+		return
+	}
+
+	var perm *permanentError
+	if errors.As(err, &perm) || job.Attempts >= job.MaxAttempts {
+		log.Printf("Ingest worker %d: job %s failed permanently: %v", n, job.ID, err)
+		if dbErr := q.jobs.FailJob(job.ID, err.Error()); dbErr != nil {
+			log.Printf("Error marking job %s failed: %v", job.ID, dbErr)
+		}
+		q.updateVideoStatus(job, models.StatusFailed, err.Error())
+		reporter.Finish(models.StatusFailed, err.Error())
+		return
+	}
+
+	delay := q.backoff(job.Attempts)
+	log.Printf("Ingest worker %d: job %s failed (%v), retrying in %v", n, job.ID, err, delay)
+	if dbErr := q.jobs.RescheduleJob(job.ID, err.Error(), time.Now().Add(delay)); dbErr != nil {
+		log.Printf("Error rescheduling job %s: %v", job.ID, dbErr)
+	}
+	retryMsg := fmt.Sprintf("Attempt %d failed, retrying in %v: %v", job.Attempts, delay, err)
+	q.updateVideoStatus(job, models.StatusPending, retryMsg)
+	reporter.Retrying(retryMsg)
+}
+
+// heartbeat keeps a running job's lease until ctx ends, and stops the job if it was
+// cancelled or recovered by another process meanwhile
+func (q *JobQueue) heartbeat(ctx context.Context, cancel context.CancelFunc, jobID string) {
+	ticker := time.NewTicker(q.lease / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			running, err := q.jobs.HeartbeatJob(jobID)
+			if err != nil {
+				log.Printf("Error renewing lease of job %s: %v", jobID, err)
+				continue
+			}
+			if !running {
+				log.Printf("Job %s is no longer running here; stopping it", jobID)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// safeCall runs the handler and converts a panic into a job error
+func (q *JobQueue) safeCall(ctx context.Context, handler JobHandler, job *models.IngestJob, reporter *ProgressReporter) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while processing job: %v", r)
+		}
+	}()
+	return handler(ctx, job, reporter)
+}
+
+// backoff returns the exponential delay before the given attempt is retried
+func (q *JobQueue) backoff(attempt int) time.Duration {
+	delay := q.baseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= q.maxBackoff {
+			return q.maxBackoff
+		}
+	}
+	return delay
+}
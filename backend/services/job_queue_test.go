@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+)
+
+// testJobKind is handled by whatever handler a test registers
+const testJobKind models.JobKind = "test"
+
+// returning makes a job handler that returns err
+func returning(err error) JobHandler {
+	return func(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error { return err }
+}
+
+func TestJobQueueRunOutcome(t *testing.T) {
+	tests := []struct {
+		name        string
+		kind        models.JobKind
+		maxAttempts int
+		handler     JobHandler
+		wantJob     models.JobStatus
+		wantVideo   models.VideoStatus
+	}{
+		{
+			name:        "success",
+			kind:        testJobKind,
+			maxAttempts: 3,
+			handler:     returning(nil),
+			wantJob:     models.JobCompleted,
+			wantVideo:   models.StatusPending, // the pipeline, not the queue, completes the video
+		},
+		{
+			name:        "error with attempts left",
+			kind:        testJobKind,
+			maxAttempts: 3,
+			handler:     returning(errors.New("network down")),
+			wantJob:     models.JobQueued,
+			wantVideo:   models.StatusPending,
+		},
+		{
+			name:        "error on last attempt",
+			kind:        testJobKind,
+			maxAttempts: 1,
+			handler:     returning(errors.New("network down")),
+			wantJob:     models.JobFailed,
+			wantVideo:   models.StatusFailed,
+		},
+		{
+			name:        "permanent error",
+			kind:        testJobKind,
+			maxAttempts: 3,
+			handler:     returning(Permanent(errors.New("unsupported format"))),
+			wantJob:     models.JobFailed,
+			wantVideo:   models.StatusFailed,
+		},
+		{
+			name:        "panic",
+			kind:        testJobKind,
+			maxAttempts: 3,
+			handler:     func(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error { panic("nil map") },
+			wantJob:     models.JobQueued,
+			wantVideo:   models.StatusPending,
+		},
+		{
+			name:        "no handler for kind",
+			kind:        "unknown",
+			maxAttempts: 3,
+			wantJob:     models.JobFailed,
+			wantVideo:   models.StatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := repository.NewMemory()
+			if err := db.CreateDefaultChannels(); err != nil {
+				t.Fatal(err)
+			}
+			video := &models.AdminVideo{ChannelID: 1, Title: "Clip", Status: models.StatusPending}
+			if err := db.SaveVideo(video); err != nil {
+				t.Fatal(err)
+			}
+			q := NewJobQueue(db, db, NewProgressHub(db), 1, 3)
+			if tt.handler != nil {
+				q.Register(testJobKind, tt.handler)
+			}
+			job := &models.IngestJob{VideoID: video.ID, Kind: tt.kind, ChannelID: 1, MaxAttempts: tt.maxAttempts}
+			if err := q.Enqueue(job); err != nil {
+				t.Fatal(err)
+			}
+
+			claimed, err := db.ClaimNextJob()
+			if err != nil || claimed == nil {
+				t.Fatalf("claim: %v, %v", claimed, err)
+			}
+			q.run(1, claimed)
+
+			stored, err := db.GetJob(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantJob {
+				t.Errorf("job is %s, want %s", stored.Status, tt.wantJob)
+			}
+			if tt.wantJob == models.JobQueued && !stored.NextRunAt.After(time.Now()) {
+				t.Errorf("retry is not delayed: next run at %v", stored.NextRunAt)
+			}
+			v, err := db.GetVideoByID(video.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Status != tt.wantVideo {
+				t.Errorf("video is %s, want %s", v.Status, tt.wantVideo)
+			}
+		})
+	}
+}
+
+func TestJobQueueBackoff(t *testing.T) {
+	db := repository.NewMemory()
+	q := NewJobQueue(db, db, nil, 1, 3)
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 30 * time.Minute},
+		{20, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := q.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestJobQueueRecoverStuckJobs(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat time.Duration // before now
+		want      models.JobStatus
+	}{
+		{name: "lease expired", heartbeat: 3 * time.Minute, want: models.JobQueued},
+		{name: "lease held", heartbeat: 30 * time.Second, want: models.JobRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := repository.NewMemory()
+			q := NewJobQueue(db, db, nil, 1, 3)
+			job := &models.IngestJob{Kind: models.JobKindExpand, ChannelID: 1}
+			if err := q.Enqueue(job); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.ClaimNextJob(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Recover as if the clock had moved on since the last heartbeat
+			if _, err := db.RecoverStuckJobs(time.Now().Add(tt.heartbeat).Add(-q.lease)); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := db.GetJob(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.want {
+				t.Errorf("job is %s, want %s", stored.Status, tt.want)
+			}
+		})
+	}
+}
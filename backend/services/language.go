@@ -0,0 +1,98 @@
+package services
+
+import (
+	"live-broadcast-backend/models"
+	"regexp"
+	"strings"
+)
+
+// languageTagPattern accepts BCP 47 style tags such as "en", "pt-BR" or "zh-Hans"
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// ValidLanguage reports whether lang can be used as a subtitle or audio track language
+func ValidLanguage(lang string) bool {
+	return languageTagPattern.MatchString(lang)
+}
+
+// iso639Alpha3 maps the ISO 639-2 codes containers usually carry (both the
+// terminology and bibliographic forms) to ISO 639-1
+var iso639Alpha3 = map[string]string{
+	"ara": "ar", "ben": "bn", "bul": "bg", "cat": "ca", "ces": "cs", "cze": "cs",
+	"chi": "zh", "zho": "zh", "dan": "da", "deu": "de", "ger": "de", "ell": "el",
+	"gre": "el", "eng": "en", "est": "et", "fas": "fa", "per": "fa", "fin": "fi",
+	"fra": "fr", "fre": "fr", "heb": "he", "hin": "hi", "hrv": "hr", "hun": "hu",
+	"ind": "id", "ita": "it", "jpn": "ja", "kor": "ko", "lav": "lv", "lit": "lt",
+	"may": "ms", "msa": "ms", "nld": "nl", "dut": "nl", "nor": "no", "pol": "pl",
+	"por": "pt", "ron": "ro", "rum": "ro", "rus": "ru", "slk": "sk", "slo": "sk",
+	"slv": "sl", "spa": "es", "srp": "sr", "swe": "sv", "tam": "ta", "tel": "te",
+	"tha": "th", "tur": "tr", "ukr": "uk", "urd": "ur", "vie": "vi",
+}
+
+// NormaliseLanguage returns tag with its primary subtag in the two-letter form where one
+// is known ("eng" -> "en", "por-BR" -> "pt-BR"). Unknown tags are returned unchanged;
+// empty and "und" tags return "".
+func NormaliseLanguage(tag string) string {
+	tag = strings.TrimSpace(tag)
+	primary, rest, hasRest := strings.Cut(tag, "-")
+	primary = strings.ToLower(primary)
+	if primary == "" || primary == "und" {
+		return ""
+	}
+	if short, ok := iso639Alpha3[primary]; ok {
+		primary = short
+	}
+	if hasRest {
+		return primary + "-" + rest
+	}
+	return primary
+}
+
+// SameLanguage reports whether two tags name the same language, ignoring region and
+// script subtags and the two/three-letter distinction
+func SameLanguage(a, b string) bool {
+	a, _, _ = strings.Cut(NormaliseLanguage(a), "-")
+	b, _, _ = strings.Cut(NormaliseLanguage(b), "-")
+	return a != "" && a == b
+}
+
+// containerLanguage is the ISO 639-2 code written to MP4 track headers, which cannot
+// hold two-letter codes; "und" when unknown
+func containerLanguage(tag string) string {
+	primary, _, _ := strings.Cut(NormaliseLanguage(tag), "-")
+	for long, short := range iso639Alpha3 {
+		if short == primary && !bibliographicCodes[long] {
+			return long
+		}
+	}
+	if len(primary) == 3 {
+		return primary
+	}
+	return "und"
+}
+
+// bibliographicCodes are the ISO 639-2/B codes not used when writing tags
+var bibliographicCodes = map[string]bool{
+	"chi": true, "cze": true, "dut": true, "fre": true, "ger": true, "gre": true,
+	"may": true, "per": true, "rum": true, "slo": true,
+}
+
+// PickAudioTrack returns the track to play for a preferred language: a real dub in that
+// language, otherwise the default track. ok is false when the video lists no tracks.
+func PickAudioTrack(tracks []models.AudioTrack, preferred string) (track models.AudioTrack, ok bool) {
+	if len(tracks) == 0 {
+		return track, false
+	}
+	if preferred != "" {
+		for _, t := range tracks {
+			if !t.Fallback && SameLanguage(t.Language, preferred) {
+				return t, true
+			}
+		}
+	}
+	for _, t := range tracks {
+		if t.Default {
+			return t, true
+		}
+	}
+	return tracks[0], true
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// livePreviewWidth is the width of the stills captured from live channels
+const livePreviewWidth = 480
+
+// CaptureLivePreview decodes the first frame of a keyframe fragment and returns it as
+// a JPEG. The init segment and fragment are piped to ffmpeg as one fMP4 stream.
+func CaptureLivePreview(ctx context.Context, init, frag []byte) ([]byte, error) {
+	if len(init) == 0 || len(frag) == 0 {
+		return nil, fmt.Errorf("no fragment to capture")
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-f", "mp4",
+		"-i", "pipe:0",
+		"-map", "0:v:0",
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", livePreviewWidth),
+		"-q:v", "4",
+		"-f", "image2pipe",
+		"-c:v", "mjpeg",
+		"pipe:1")
+	cmd.Stdin = bytes.NewReader(append(append([]byte{}, init...), frag...))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v - %s", err, stderr.String())
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no frame")
+	}
+	return stdout.Bytes(), nil
+}
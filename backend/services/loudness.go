@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"live-broadcast-backend/models"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// loudnormLRA is the loudness range target passed to loudnorm
+const loudnormLRA = 11
+
+// loudnormStats is the print_format=json block loudnorm writes to stderr. ffmpeg
+// prints every value as a string.
+type loudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// MeasureLoudness runs loudnorm's analysis pass over the audio stream at audioIndex
+func MeasureLoudness(ctx context.Context, path string, audioIndex int, profile *models.TranscodeProfile) (*models.LoudnessMeasurement, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats",
+		"-i", path,
+		"-map", fmt.Sprintf("0:a:%d", audioIndex),
+		"-af", fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%d:print_format=json", profile.TargetLUFS, profile.TruePeak, loudnormLRA),
+		"-f", "null", "-")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("loudness analysis failed: %v", err)
+	}
+
+	// The JSON block is the last thing ffmpeg prints
+	start := strings.LastIndex(string(output), "{")
+	end := strings.LastIndex(string(output), "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("loudness analysis produced no measurement")
+	}
+	var stats loudnormStats
+	if err := json.Unmarshal(output[start:end+1], &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse loudness analysis: %v", err)
+	}
+
+	m := &models.LoudnessMeasurement{
+		TargetLUFS: profile.TargetLUFS,
+		Mode:       profile.LoudnessMode,
+	}
+	values := []*float64{&m.IntegratedLUFS, &m.TruePeak, &m.LRA, &m.Threshold, &m.TargetOffset}
+	for i, raw := range []string{stats.InputI, stats.InputTP, stats.InputLRA, stats.InputThresh, stats.TargetOffset} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+			// Digital silence measures as -inf; there is nothing to normalise
+			return nil, fmt.Errorf("audio is silent or unmeasurable (%q)", raw)
+		}
+		*values[i] = v
+	}
+	return m, nil
+}
+
+// loudnormFilter is the second, linear loudnorm pass using a prior measurement
+func loudnormFilter(m *models.LoudnessMeasurement, profile *models.TranscodeProfile) string {
+	return fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%d:measured_I=%.2f:measured_TP=%.2f:measured_LRA=%.2f:measured_thresh=%.2f:offset=%.2f:linear=true",
+		profile.TargetLUFS, profile.TruePeak, loudnormLRA,
+		m.IntegratedLUFS, m.TruePeak, m.LRA, m.Threshold, m.TargetOffset)
+}
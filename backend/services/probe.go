@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// MediaInfo summarises the streams of a media file as reported by ffprobe
+type MediaInfo struct {
+	Duration   float64 // seconds
+	HasVideo   bool
+	VideoCodec string
+	Width      int
+	Height     int
+	HasAudio   bool
+	AudioCodec string
+	Audio      []AudioStream    // Every audio stream, in file order
+	Subtitles  []SubtitleStream // Text subtitle streams; bitmap subtitles are not listed
+}
+
+// AudioStream is an audio stream inside a media file
+type AudioStream struct {
+	Index    int    // Position among the audio streams, for -map 0:a:N
+	Language string // As tagged in the container, often ISO 639-2 ("eng"); "" when untagged
+	Title    string
+	Default  bool
+}
+
+// SubtitleStream is a text subtitle stream inside a media file
+type SubtitleStream struct {
+	Index    int // Absolute stream index, for -map 0:N
+	Codec    string
+	Language string // As tagged in the container, often ISO 639-2 ("eng"); "" when untagged
+	Title    string
+	Captions bool // Flagged for the hearing impaired
+}
+
+// textSubtitleCodecs are subtitle codecs ffmpeg can convert to WebVTT
+var textSubtitleCodecs = map[string]bool{
+	"subrip": true, "srt": true, "ass": true, "ssa": true, "webvtt": true, "mov_text": true, "text": true,
+}
+
+// ffprobeOutput mirrors the parts of `ffprobe -show_format -show_streams -of json` we use
+type ffprobeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		Index     int    `json:"index"`
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		Tags      struct {
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"tags"`
+		Disposition struct {
+			Default         int `json:"default"`
+			HearingImpaired int `json:"hearing_impaired"`
+		} `json:"disposition"`
+	} `json:"streams"`
+}
+
+// ProbeMedia inspects a local media file with ffprobe
+func ProbeMedia(ctx context.Context, path string) (*MediaInfo, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_format",
+		"-show_streams",
+		"-of", "json",
+		path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %v", err)
+	}
+
+	info := &MediaInfo{}
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			// Cover art is reported as a video stream too; only take the first real one
+			if !info.HasVideo && stream.CodecName != "mjpeg" && stream.CodecName != "png" {
+				info.HasVideo = true
+				info.VideoCodec = stream.CodecName
+				info.Width = stream.Width
+				info.Height = stream.Height
+			}
+		case "audio":
+			if !info.HasAudio {
+				info.HasAudio = true
+				info.AudioCodec = stream.CodecName
+			}
+			info.Audio = append(info.Audio, AudioStream{
+				Index:    len(info.Audio),
+				Language: stream.Tags.Language,
+				Title:    stream.Tags.Title,
+				Default:  stream.Disposition.Default == 1,
+			})
+		case "subtitle":
+			if textSubtitleCodecs[stream.CodecName] {
+				info.Subtitles = append(info.Subtitles, SubtitleStream{
+					Index:    stream.Index,
+					Codec:    stream.CodecName,
+					Language: stream.Tags.Language,
+					Title:    stream.Tags.Title,
+					Captions: stream.Disposition.HearingImpaired == 1,
+				})
+			}
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressPersistInterval throttles how often progress is written to the videos table
+const progressPersistInterval = 2 * time.Second
+
+// ProgressHub fans ingest progress out to subscribers (admin WebSocket connections)
+// and persists the latest report on the video row
+type ProgressHub struct {
+	db          repository.VideoRepository
+	mu          sync.Mutex
+	subscribers map[chan models.IngestProgress]struct{}
+}
+
+// NewProgressHub creates a new progress hub
+func NewProgressHub(db repository.VideoRepository) *ProgressHub {
+	return &ProgressHub{
+		db:          db,
+		subscribers: make(map[chan models.IngestProgress]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every progress report and a function to unsubscribe.
+// Reports are dropped for subscribers that fall behind.
+func (h *ProgressHub) Subscribe() (<-chan models.IngestProgress, func()) {
+	ch := make(chan models.IngestProgress, 64)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publish delivers a report to all subscribers without blocking
+func (h *ProgressHub) publish(p models.IngestProgress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// Reporter creates a progress reporter for one run of a job. Jobs without a video, such as
+// expand jobs, get nil, which reports nothing.
+func (h *ProgressHub) Reporter(job *models.IngestJob) *ProgressReporter {
+	if job.VideoID == "" {
+		return nil
+	}
+	return &ProgressReporter{
+		hub:       h,
+		videoID:   job.VideoID,
+		jobID:     job.ID,
+		channelID: job.ChannelID,
+	}
+}
+
+// ProgressReporter records progress for a single video ingest
+type ProgressReporter struct {
+	hub         *ProgressHub
+	videoID     string
+	jobID       string
+	channelID   int
+	mu          sync.Mutex
+	stage       models.IngestStage
+	lastPersist time.Time
+}
+
+// Stage announces the start of a pipeline stage
+func (r *ProgressReporter) Stage(stage models.IngestStage) {
+	r.Update(stage, 0, 0, 0, 0)
+}
+
+// Update reports progress within a stage. percent is derived from done/total when total is known.
+func (r *ProgressReporter) Update(stage models.IngestStage, percent float64, done, total int64, speed float64) {
+	if r == nil {
+		return
+	}
+	if total > 0 {
+		percent = float64(done) / float64(total) * 100
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	r.report(models.IngestProgress{
+		Stage:      stage,
+		Status:     statusForStage(stage),
+		Percent:    percent,
+		BytesDone:  done,
+		BytesTotal: total,
+		SpeedBps:   speed,
+	})
+}
+
+// Finish reports the final outcome of the job
+func (r *ProgressReporter) Finish(status models.VideoStatus, errorMsg string) {
+	if r == nil {
+		return
+	}
+	percent := 0.0
+	if status == models.StatusCompleted {
+		percent = 100
+	}
+	r.report(models.IngestProgress{
+		Stage:    models.StageDone,
+		Status:   status,
+		Percent:  percent,
+		ErrorMsg: errorMsg,
+	})
+}
+
+// Retrying reports that the current attempt failed and the job went back to the queue
+func (r *ProgressReporter) Retrying(errorMsg string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	stage := r.stage
+	r.mu.Unlock()
+	r.report(models.IngestProgress{
+		Stage:    stage,
+		Status:   models.StatusPending,
+		ErrorMsg: errorMsg,
+	})
+}
+
+func (r *ProgressReporter) report(p models.IngestProgress) {
+	p.VideoID = r.videoID
+	p.JobID = r.jobID
+	p.ChannelID = r.channelID
+	p.UpdatedAt = time.Now()
+
+	r.hub.publish(p)
+
+	// Persist on stage changes, completion, and otherwise at most every few seconds
+	r.mu.Lock()
+	persist := p.Stage != r.stage || p.Percent >= 100 || time.Since(r.lastPersist) >= progressPersistInterval
+	if persist {
+		r.stage = p.Stage
+		r.lastPersist = p.UpdatedAt
+	}
+	r.mu.Unlock()
+
+	if persist {
+		if err := r.hub.db.UpdateVideoProgress(p.VideoID, string(p.Stage), p.Percent); err != nil {
+			log.Printf("Error persisting progress for video %s: %v", p.VideoID, err)
+		}
+	}
+}
+
+// statusForStage maps a pipeline stage to the video status shown while it runs
+func statusForStage(stage models.IngestStage) models.VideoStatus {
+	switch stage {
+	case models.StageMetadata, models.StageDownload:
+		return models.StatusDownloading
+	case models.StageDone:
+		return models.StatusCompleted
+	default:
+		return models.StatusProcessing
+	}
+}
+
+// progressReader counts bytes read from an upload body. Seeking back to the start
+// (the SDK does this after hashing the payload) resets the count.
+type progressReader struct {
+	rs       io.ReadSeeker
+	total    int64
+	read     int64
+	started  time.Time
+	reporter *ProgressReporter
+	stage    models.IngestStage
+}
+
+func newProgressReader(rs io.ReadSeeker, total int64, reporter *ProgressReporter, stage models.IngestStage) *progressReader {
+	return &progressReader{rs: rs, total: total, started: time.Now(), reporter: reporter, stage: stage}
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.rs.Read(p)
+	pr.read += int64(n)
+	speed := 0.0
+	if elapsed := time.Since(pr.started).Seconds(); elapsed > 0 {
+		speed = float64(pr.read) / elapsed
+	}
+	pr.reporter.Update(pr.stage, 0, pr.read, pr.total, speed)
+	return n, err
+}
+
+func (pr *progressReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := pr.rs.Seek(offset, whence)
+	if err == nil {
+		pr.read = pos
+		if pos == 0 {
+			pr.started = time.Now()
+		}
+	}
+	return pos, err
+}
+
+// progressWriter counts bytes written, e.g. while saving a plain HTTP download
+type progressWriter struct {
+	total    int64
+	written  int64
+	started  time.Time
+	reporter *ProgressReporter
+	stage    models.IngestStage
+}
+
+func newProgressWriter(total int64, reporter *ProgressReporter, stage models.IngestStage) *progressWriter {
+	return &progressWriter{total: total, started: time.Now(), reporter: reporter, stage: stage}
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.written += int64(len(p))
+	speed := 0.0
+	if elapsed := time.Since(pw.started).Seconds(); elapsed > 0 {
+		speed = float64(pw.written) / elapsed
+	}
+	pw.reporter.Update(pw.stage, 0, pw.written, pw.total, speed)
+	return len(p), nil
+}
+
+// ytdlpProgressTemplate makes yt-dlp print one machine-readable progress line per update
+const ytdlpProgressTemplate = "download:[progress] %(progress.downloaded_bytes)s %(progress.total_bytes)s %(progress.total_bytes_estimate)s %(progress.speed)s"
+
+// runYTDLPWithProgress runs yt-dlp with the progress template and forwards download progress.
+// It returns the remaining combined output for error reporting.
+func runYTDLPWithProgress(ctx context.Context, args []string, reporter *ProgressReporter) ([]byte, error) {
+	args = append([]string{"--newline", "--progress-template", ytdlpProgressTemplate}, args...)
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+
+	return runWithLineProgress(cmd, func(line string) {
+		fields := strings.Fields(strings.TrimPrefix(line, "[progress]"))
+		if !strings.HasPrefix(line, "[progress]") || len(fields) < 4 {
+			return
+		}
+		done := parseProgressNumber(fields[0])
+		total := parseProgressNumber(fields[1])
+		if total == 0 {
+			total = parseProgressNumber(fields[2])
+		}
+		speed := float64(parseProgressNumber(fields[3]))
+		reporter.Update(models.StageDownload, 0, done, total, speed)
+	})
+}
+
+// runFFmpegWithProgress runs ffmpeg with -progress on stdout and reports the position
+// against the known media duration (in seconds)
+func runFFmpegWithProgress(ctx context.Context, args []string, duration float64, reporter *ProgressReporter, stage models.IngestStage) ([]byte, error) {
+	args = append([]string{"-hide_banner", "-nostats", "-progress", "pipe:1"}, args...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	started := time.Now()
+	return runWithLineProgress(cmd, func(line string) {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || duration <= 0 {
+			return
+		}
+		switch key {
+		case "out_time_us", "out_time_ms": // both are microseconds in ffmpeg's progress output
+			us, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return
+			}
+			position := float64(us) / 1e6
+			speed := 0.0
+			if elapsed := time.Since(started).Seconds(); elapsed > 0 {
+				speed = position / elapsed
+			}
+			reporter.Update(stage, position/duration*100, 0, 0, speed)
+		}
+	})
+}
+
+// runWithLineProgress starts cmd, feeds each stdout line to onLine, and returns stderr
+// plus any non-progress stdout lines for diagnostics
+func runWithLineProgress(cmd *exec.Cmd, onLine func(line string)) ([]byte, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", cmd.Path, err)
+	}
+
+	var other strings.Builder
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		onLine(line)
+		if !strings.HasPrefix(line, "[progress]") && !strings.Contains(line, "=") {
+			other.WriteString(line)
+			other.WriteByte('\n')
+		}
+	}
+
+	err = cmd.Wait()
+	return []byte(other.String() + stderr.String()), err
+}
+
+// parseProgressNumber parses yt-dlp template values, which are "NA" when unknown
+func parseProgressNumber(s string) int64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(f)
+}
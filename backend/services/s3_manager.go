@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"live-broadcast-backend/keys"
 	"live-broadcast-backend/models"
 	"log"
 	"os"
@@ -12,19 +16,26 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-// S3Manager handles interaction with S3 for video management
+// ErrObjectCorrupt is returned when a downloaded object does not match the SHA-256
+// recorded when it was uploaded
+var ErrObjectCorrupt = errors.New("object does not match its recorded checksum")
+
+// ChecksumLookup finds the recorded SHA-256 of an object, "" when none is known
+type ChecksumLookup interface {
+	GetChecksumByS3Key(s3Key string) (string, error)
+}
+
+// S3Manager lists channel folders in storage and keeps a local copy of videos for playout
 type S3Manager struct {
-	s3Client  *s3.Client
-	bucket    string
+	storage   Storage
 	videoDir  string
 	baseURL   string
 	mutex     sync.Mutex
 	downloads map[string]bool // Track which S3Keys are downloaded
+	objects   map[string]ObjectInfo // Channel folder objects seen by the last sync
+	checksums ChecksumLookup
 }
 
 // NewS3Manager creates a new S3 manager for video operations
@@ -35,25 +46,23 @@ func NewS3Manager(videoService *VideoService, videoDir string) (*S3Manager, erro
 	}
 
 	return &S3Manager{
-		s3Client: videoService.s3Client,
-		bucket:   videoService.bucket,
+		storage:  videoService.storage,
 		videoDir: videoDir,
 		baseURL:  "/videos", // Local URL path for videos
 		downloads: make(map[string]bool),
+		objects:   make(map[string]ObjectInfo),
 	}, nil
 }
 
-// ListChannelFolders lists all channel folders in the S3 bucket
-func (sm *S3Manager) ListChannelFolders() ([]string, error) {
-	// Get AWS region from environment
-	awsRegion := os.Getenv("AWS_REGION")
-	log.Printf("Using AWS region: %s for bucket: %s", awsRegion, sm.bucket)
+// SetChecksumLookup makes downloads verify objects against their recorded checksum
+func (sm *S3Manager) SetChecksumLookup(checksums ChecksumLookup) {
+	sm.checksums = checksums
+}
 
+// ListChannelFolders lists all channel folders in storage
+func (sm *S3Manager) ListChannelFolders() ([]string, error) {
 	// List objects with delimiter to get "directories"
-	resp, err := sm.s3Client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
-		Bucket:    &sm.bucket,
-		Delimiter: aws.String("/"),
-	})
+	_, prefixes, err := listAll(context.Background(), sm.storage, ListOptions{Delimiter: "/"})
 
 	if err != nil {
 		log.Printf("Error listing S3 folders: %v", err)
@@ -61,13 +70,11 @@ func (sm *S3Manager) ListChannelFolders() ([]string, error) {
 	}
 
 	var folders []string
-	for _, prefix := range resp.CommonPrefixes {
-		if prefix.Prefix != nil {
-			// Remove trailing slash
-			folderName := strings.TrimSuffix(*prefix.Prefix, "/")
-			if strings.HasPrefix(folderName, "channel_") {
-				folders = append(folders, folderName)
-			}
+	for _, prefix := range prefixes {
+		// Remove trailing slash
+		folderName := strings.TrimSuffix(prefix, "/")
+		if keys.ChannelNumber(folderName) > 0 {
+			folders = append(folders, folderName)
 		}
 	}
 
@@ -76,28 +83,88 @@ func (sm *S3Manager) ListChannelFolders() ([]string, error) {
 
 // ListVideosInFolder lists all videos in a specific S3 folder
 func (sm *S3Manager) ListVideosInFolder(folder string) ([]string, error) {
+	objects, err := sm.listFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	videos := make([]string, 0, len(objects))
+	for _, object := range objects {
+		videos = append(videos, object.Key)
+	}
+
+	return videos, nil
+}
+
+// listFolder returns every object in a folder, skipping the folder placeholder itself
+func (sm *S3Manager) listFolder(folder string) ([]ObjectInfo, error) {
 	// Ensure folder name ends with a slash for prefix search
 	if !strings.HasSuffix(folder, "/") {
 		folder = folder + "/"
 	}
 
-	resp, err := sm.s3Client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
-		Bucket: &sm.bucket,
-		Prefix: &folder,
-	})
-
+	objects, _, err := listAll(context.Background(), sm.storage, ListOptions{Prefix: folder})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list videos in folder %s: %v", folder, err)
 	}
 
-	var videos []string
-	for _, object := range resp.Contents {
-		if object.Key != nil && *object.Key != folder {
-			videos = append(videos, *object.Key)
+	result := objects[:0]
+	for _, object := range objects {
+		if object.Key != folder {
+			result = append(result, object)
 		}
 	}
+	return result, nil
+}
 
-	return videos, nil
+// SyncChanges lists the objects in channel folders that differ from the previous sync.
+// On the first sync every object is Added.
+type SyncChanges struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+// recordListing compares a full listing with the previous one by ETag and
+// LastModified, remembers it for the next sync and drops local copies of objects
+// that were replaced or removed so they are downloaded again
+func (sm *S3Manager) recordListing(objects map[string]ObjectInfo) *SyncChanges {
+	sm.mutex.Lock()
+	changes := &SyncChanges{}
+	for key, object := range objects {
+		previous, ok := sm.objects[key]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, key)
+		case previous.ETag != object.ETag || !previous.LastModified.Equal(object.LastModified):
+			changes.Changed = append(changes.Changed, key)
+		}
+	}
+	for key := range sm.objects {
+		if _, ok := objects[key]; !ok {
+			changes.Removed = append(changes.Removed, key)
+		}
+	}
+	sm.objects = objects
+	sm.mutex.Unlock()
+
+	for _, key := range append(append([]string{}, changes.Changed...), changes.Removed...) {
+		if err := sm.DeleteVideo(key); err != nil {
+			log.Printf("Warning: failed to drop stale local copy of %s: %v", key, err)
+		}
+	}
+	return changes
+}
+
+// Forget makes the next sync report keys as changed, e.g. after processing them failed
+func (sm *S3Manager) Forget(keys []string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	for _, key := range keys {
+		if _, ok := sm.objects[key]; ok {
+			sm.objects[key] = ObjectInfo{Key: key}
+		}
+	}
 }
 
 // DownloadVideo downloads a video from S3 to the local file system
@@ -127,28 +194,36 @@ func (sm *S3Manager) IsVideoDownloaded(s3Key string) bool {
 
 // DeleteVideo removes a downloaded video from the file system
 func (sm *S3Manager) DeleteVideo(s3Key string) error {
-	// Get the local path
-	localPath := filepath.Join(sm.videoDir, s3Key)
-	
-	// Check if the file exists
-	if _, err := os.Stat(localPath); os.IsNotExist(err) {
-		// File doesn't exist, nothing to delete
-		return nil
-	}
-	
-	// Delete the file
-	err := os.Remove(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to delete video %s: %v", s3Key, err)
+	_, err := sm.RemoveLocalCopies(s3Key)
+	return err
+}
+
+// RemoveLocalCopies deletes the downloaded copy of a video and the fragmented copy
+// made for playout, returning the paths that were removed
+func (sm *S3Manager) RemoveLocalCopies(s3Key string) ([]string, error) {
+	localPath := keys.LocalCopy(sm.videoDir, s3Key)
+
+	var removed []string
+	for _, path := range []string{localPath, keys.Fragmented(localPath)} {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to delete video %s: %v", s3Key, err)
+		}
+		removed = append(removed, path)
 	}
-	
+
 	// Update tracking
 	sm.mutex.Lock()
 	delete(sm.downloads, s3Key)
 	sm.mutex.Unlock()
-	
-	log.Printf("Successfully deleted video: %s", s3Key)
-	return nil
+
+	if len(removed) > 0 {
+		log.Printf("Successfully deleted video: %s", s3Key)
+	}
+	return removed, nil
 }
 
 func (sm *S3Manager) CreateVideoObject(s3Key string, localPath string, channelNum int) *models.Video {
@@ -175,7 +250,7 @@ func (sm *S3Manager) CreateVideoObject(s3Key string, localPath string, channelNu
 
 	// Create Video object
 	video := &models.Video{
-		ID:          fmt.Sprintf("s3-%s-%d", videoTitle, channelNum),
+		ID:          keys.SyncVideoID(s3Key, channelNum),
 		Title:       fmt.Sprintf("Channel %d - %s", channelNum, videoTitle),
 		Description: fmt.Sprintf("Video from S3: %s", s3Key),
 		Duration:    duration,
@@ -185,27 +260,35 @@ func (sm *S3Manager) CreateVideoObject(s3Key string, localPath string, channelNu
 		URL:         videoURL,
 	}
 
+	// Previews are generated by the thumbnail backfill after each sync
+	previews := ThumbnailURLs(keys.PreviewID(s3Key, video.ID))
+	video.ThumbnailURL = previews.ThumbnailURL
+	video.SpriteURL = previews.SpriteURL
+	video.SpriteVTTURL = previews.SpriteVTTURL
+
 	return video
 }
 
 // GetChannelsAndVideos retrieves all channels and their metadata from S3,
-// but only downloads the first video for each channel
-func (sm *S3Manager) GetChannelsAndVideos() ([]*models.Channel, map[string]*models.Video, error) {
+// but only downloads the first video for each channel. The changes are relative
+// to the previous call.
+func (sm *S3Manager) GetChannelsAndVideos() ([]*models.Channel, map[string]*models.Video, *SyncChanges, error) {
 	// List all channel folders
 	folders, err := sm.ListChannelFolders()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	channels := make([]*models.Channel, 0, len(folders))
 	videos := make(map[string]*models.Video)
 	videosByChannel := make(map[int][]string)
+	listing := make(map[string]ObjectInfo)
+	complete := true
 
 	// First pass: collect information about all videos without downloading
 	for _, folder := range folders {
 		// Extract channel number from folder name
-		var channelNum int
-		fmt.Sscanf(folder, "channel_%d", &channelNum)
+		channelNum := keys.ChannelNumber(folder)
 		if channelNum < 1 {
 			log.Printf("Invalid channel folder name: %s", folder)
 			continue
@@ -221,11 +304,17 @@ func (sm *S3Manager) GetChannelsAndVideos() ([]*models.Channel, map[string]*mode
 		channels = append(channels, channel)
 
 		// List videos in folder
-		videoKeys, err := sm.ListVideosInFolder(folder)
+		objects, err := sm.listFolder(folder)
 		if err != nil {
 			log.Printf("Error listing videos in folder %s: %v", folder, err)
+			complete = false
 			continue
 		}
+		videoKeys := make([]string, 0, len(objects))
+		for _, object := range objects {
+			videoKeys = append(videoKeys, object.Key)
+			listing[object.Key] = object
+		}
 
 		// Store video keys for this channel
 		videosByChannel[channelNum] = videoKeys
@@ -233,7 +322,7 @@ func (sm *S3Manager) GetChannelsAndVideos() ([]*models.Channel, map[string]*mode
 		// Create video objects without downloading
 		for _, videoKey := range videoKeys {
 			// The local path where video would be stored if/when downloaded
-			video := sm.CreateVideoObject(videoKey, filepath.Join(sm.videoDir, videoKey), channelNum)
+			video := sm.CreateVideoObject(videoKey, keys.LocalCopy(sm.videoDir, videoKey), channelNum)
 			videos[video.ID] = video
 		}
 
@@ -243,6 +332,18 @@ func (sm *S3Manager) GetChannelsAndVideos() ([]*models.Channel, map[string]*mode
 		}
 	}
 
+	// A folder that failed to list would otherwise look like all its videos were removed
+	if !complete {
+		sm.mutex.Lock()
+		for key, object := range sm.objects {
+			if _, ok := listing[key]; !ok {
+				listing[key] = object
+			}
+		}
+		sm.mutex.Unlock()
+	}
+	changes := sm.recordListing(listing)
+
 	// Second pass: download only the first video for each channel
 	for channelNum, videoKeys := range videosByChannel {
 		if len(videoKeys) > 0 {
@@ -257,7 +358,7 @@ func (sm *S3Manager) GetChannelsAndVideos() ([]*models.Channel, map[string]*mode
 		}
 	}
 
-	return channels, videos, nil
+	return channels, videos, changes, nil
 }
 
 // getThemeForChannel returns an appropriate theme for a given channel number
@@ -279,25 +380,19 @@ func (sm *S3Manager) rawDownload(s3Key string) (string, error) {
 	sm.mutex.Lock()
 	if sm.downloads[s3Key] {
 		sm.mutex.Unlock()
-		return filepath.Join(sm.videoDir, s3Key), nil
+		return keys.LocalCopy(sm.videoDir, s3Key), nil
 	}
 	sm.mutex.Unlock()
 
-	// Get the object from S3
-	resp, err := sm.s3Client.GetObject(
-		context.Background(),
-		&s3.GetObjectInput{
-			Bucket: aws.String(sm.bucket),
-			Key:    aws.String(s3Key),
-		},
-	)
+	// Get the object from storage
+	body, err := sm.storage.Get(context.Background(), s3Key)
 	if err != nil {
-		return "", fmt.Errorf("s3 getObject %s: %w", s3Key, err)
+		return "", fmt.Errorf("get %s: %w", s3Key, err)
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 
 	// Make sure parent folders exist
-	localPath := filepath.Join(sm.videoDir, s3Key)
+	localPath := keys.LocalCopy(sm.videoDir, s3Key)
 	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
 		return "", fmt.Errorf("mkdir %s: %w", filepath.Dir(localPath), err)
 	}
@@ -307,17 +402,40 @@ func (sm *S3Manager) rawDownload(s3Key string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("create %s: %w", localPath, err)
 	}
-	if _, err := io.Copy(out, resp.Body); err != nil {
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), body); err != nil {
 		out.Close()
 		os.Remove(localPath)
 		return "", fmt.Errorf("copy %s: %w", s3Key, err)
 	}
 	out.Close()
 
+	// Objects synced straight into S3 have no recorded checksum
+	if expected := sm.expectedChecksum(s3Key); expected != "" {
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+			os.Remove(localPath)
+			return "", fmt.Errorf("%s: %w (expected %s, got %s)", s3Key, ErrObjectCorrupt, expected, actual)
+		}
+	}
+
 	// mark as downloaded
 	sm.mutex.Lock()
 	sm.downloads[s3Key] = true
 	sm.mutex.Unlock()
 
 	return localPath, nil
-}
\ No newline at end of file
+}
+
+// expectedChecksum returns the recorded SHA-256 of an object, or "" when there is none
+// or it cannot be looked up
+func (sm *S3Manager) expectedChecksum(s3Key string) string {
+	if sm.checksums == nil {
+		return ""
+	}
+	checksum, err := sm.checksums.GetChecksumByS3Key(s3Key)
+	if err != nil {
+		log.Printf("Warning: cannot look up checksum of %s, skipping verification: %v", s3Key, err)
+		return ""
+	}
+	return checksum
+}
@@ -1,129 +1,95 @@
 package services
 
 import (
+	"bytes"
 	"context"
-	"fmt"
+	"errors"
+	"io"
+	"live-broadcast-backend/keys"
 	"log"
-	"os"
 	"time"
-
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+// VideoService gives handlers and services access to stored videos, previews and
+// subtitles in the configured Storage
 type VideoService struct {
-	s3Client *s3.Client
-	bucket   string
+	storage Storage
 }
 
-func NewVideoService(bucket string) (*VideoService, error) {
-	// Get region from environment or default to us-east-1
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = "us-east-1" // Default region if not specified
-	}
-
-	log.Printf("Initializing S3 client with region: %s for bucket: %s", region, bucket)
-
-	// Load the AWS configuration with explicit region
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %v", err)
-	}
-
-	// Create an S3 client
-	client := s3.NewFromConfig(cfg)
+// NewVideoService creates a video service on top of storage
+func NewVideoService(storage Storage) *VideoService {
+	return &VideoService{storage: storage}
+}
 
-	return &VideoService{
-		s3Client: client,
-		bucket:   bucket,
-	}, nil
+// Storage returns the object store the service uses
+func (vs *VideoService) Storage() Storage {
+	return vs.storage
 }
 
 // GetVideoURL generates a pre-signed URL for a video in the S3 bucket
 func (vs *VideoService) GetVideoURL(channelNumber int) (string, error) {
 	// Construct the video key based on channel number
-	videoKey := fmt.Sprintf("channel_%d/video.mp4", channelNumber)
+	videoKey := keys.LegacyChannelVideo(channelNumber)
 
 	// Create a pre-signed URL that expires in 1 hour
-	presignClient := s3.NewPresignClient(vs.s3Client)
-	presignParams := &s3.GetObjectInput{
-		Bucket: &vs.bucket,
-		Key:    &videoKey,
-	}
-
-	presignedURL, err := presignClient.PresignGetObject(context.TODO(), presignParams, func(po *s3.PresignOptions) {
-		po.Expires = time.Hour
-	})
+	presignedURL, err := vs.storage.PresignGet(context.TODO(), videoKey, time.Hour)
 	if err != nil {
 		log.Printf("Error generating pre-signed URL for channel %d: %v", channelNumber, err)
 		return "", err
 	}
 
-	return presignedURL.URL, nil
+	return presignedURL, nil
 }
 
 // ValidateVideoExists checks if a video exists for a given channel
 func (vs *VideoService) ValidateVideoExists(channelNumber int) bool {
-	videoKey := fmt.Sprintf("channel_%d/video.mp4", channelNumber)
+	videoKey := keys.LegacyChannelVideo(channelNumber)
 
-	_, err := vs.s3Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
-		Bucket: &vs.bucket,
-		Key:    &videoKey,
-	})
+	_, err := vs.storage.Stat(context.TODO(), videoKey)
 
 	return err == nil
 }
 
-// DeleteVideo deletes a video and its thumbnail from the S3 bucket
-func (vs *VideoService) DeleteVideo(channelID int, videoID string, videoPosition int) error {
-	// Build the S3 keys for both the video and its thumbnail
-	videoKey := fmt.Sprintf("videos/%s.mp4", videoID)
-	thumbnailKey := fmt.Sprintf("thumbnails/channel_%d_video%d.jpg", channelID, videoPosition)
-
-	log.Printf("Deleting video from S3: %s, thumbnail: %s", videoKey, thumbnailKey)
-
-	// Delete the video from S3
-	_, err := vs.s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Bucket: &vs.bucket,
-		Key:    &videoKey,
-	})
+// GetThumbnailURL generates a pre-signed URL for a thumbnail in the S3 bucket
+func (vs *VideoService) GetThumbnailURL(thumbnailKey string) (string, error) {
+	// Create a pre-signed URL that expires in 10 minutes
+	presignedURL, err := vs.storage.PresignGet(context.TODO(), thumbnailKey, 10*time.Minute)
 	if err != nil {
-		log.Printf("Error deleting video from S3: %v", err)
-		// Continue even if there's an error, try to delete the thumbnail anyway
+		log.Printf("Error generating pre-signed URL for thumbnail %s: %v", thumbnailKey, err)
+		return "", err
 	}
 
-	// Delete the thumbnail from S3
-	_, err = vs.s3Client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
-		Bucket: &vs.bucket,
-		Key:    &thumbnailKey,
-	})
-	if err != nil {
-		log.Printf("Error deleting thumbnail from S3: %v", err)
-		// Continue even if there's an error, we've already tried our best
-	}
+	return presignedURL, nil
+}
 
-	return nil // Return nil even if there were errors, since we want to continue with database cleanup
+// PresignGetURL generates a pre-signed GET URL for any stored object
+func (vs *VideoService) PresignGetURL(key string, expires time.Duration) (string, error) {
+	return vs.storage.PresignGet(context.TODO(), key, expires)
 }
 
-// GetThumbnailURL generates a pre-signed URL for a thumbnail in the S3 bucket
-func (vs *VideoService) GetThumbnailURL(thumbnailKey string) (string, error) {
-	// Create a pre-signed URL that expires in 10 minutes
-	presignClient := s3.NewPresignClient(vs.s3Client)
-	presignParams := &s3.GetObjectInput{
-		Bucket: &vs.bucket,
-		Key:    &thumbnailKey,
+// ObjectExists reports whether key exists in storage
+func (vs *VideoService) ObjectExists(ctx context.Context, key string) (bool, error) {
+	_, err := vs.storage.Stat(ctx, key)
+	if err == nil {
+		return true, nil
 	}
-
-	presignedURL, err := presignClient.PresignGetObject(context.TODO(), presignParams, func(po *s3.PresignOptions) {
-		po.Expires = 10 * time.Minute
-	})
-	if err != nil {
-		log.Printf("Error generating pre-signed URL for thumbnail %s: %v", thumbnailKey, err)
-		return "", err
+	if errors.Is(err, ErrObjectNotFound) {
+		return false, nil
 	}
+	return false, err
+}
+
+// OpenObject streams an object from storage; the caller closes the body
+func (vs *VideoService) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	return vs.storage.Get(ctx, key)
+}
+
+// PutObject stores a small in-memory object
+func (vs *VideoService) PutObject(ctx context.Context, key, contentType string, data []byte) error {
+	return vs.storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
 
-	return presignedURL.URL, nil
+// DeleteObject removes key from storage; deleting a missing key is not an error
+func (vs *VideoService) DeleteObject(ctx context.Context, key string) error {
+	return vs.storage.Delete(ctx, key)
 }
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"time"
+)
+
+// sessionTokenBytes is the amount of randomness in a session token
+const sessionTokenBytes = 32
+
+// SessionManager issues, checks, renews and revokes login sessions. Sessions slide: one
+// used after half its lifetime is extended to a full lifetime again.
+type SessionManager struct {
+	sessions repository.SessionRepository
+	ttl      time.Duration
+}
+
+// NewSessionManager creates a session manager whose sessions last ttl since last use
+func NewSessionManager(sessions repository.SessionRepository, ttl time.Duration) *SessionManager {
+	return &SessionManager{sessions: sessions, ttl: ttl}
+}
+
+// Start purges expired sessions now and then every interval
+func (m *SessionManager) Start(interval time.Duration) {
+	go func() {
+		m.purgeExpired()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.purgeExpired()
+		}
+	}()
+}
+
+func (m *SessionManager) purgeExpired() {
+	n, err := m.sessions.PurgeExpiredSessions()
+	if err != nil {
+		log.Printf("Error purging expired sessions: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Purged %d expired sessions", n)
+	}
+}
+
+// Create starts a session for a user with a fresh random token
+func (m *SessionManager) Create(userID string) (*models.Session, error) {
+	buf := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session token: %v", err)
+	}
+	now := time.Now()
+	session := &models.Session{
+		Token:     base64.RawURLEncoding.EncodeToString(buf),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.ttl),
+	}
+	if err := m.sessions.CreateSession(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Lookup returns the live session for a token, or nil when there is none
+func (m *SessionManager) Lookup(token string) (*models.Session, error) {
+	if token == "" {
+		return nil, nil
+	}
+	return m.sessions.GetSession(token)
+}
+
+// Renew extends a session used after half its lifetime and reports whether it did, in
+// which case session.ExpiresAt holds the new expiry
+func (m *SessionManager) Renew(session *models.Session) (bool, error) {
+	now := time.Now()
+	if session.ExpiresAt.Sub(now) > m.ttl/2 {
+		return false, nil
+	}
+	expiresAt := now.Add(m.ttl)
+	if err := m.sessions.RenewSession(session.Token, expiresAt); err != nil {
+		return false, err
+	}
+	session.ExpiresAt = expiresAt
+	return true, nil
+}
+
+// Revoke ends one session
+func (m *SessionManager) Revoke(token string) error {
+	return m.sessions.DeleteSession(token)
+}
+
+// RevokeAll ends every session of a user and returns how many there were
+func (m *SessionManager) RevokeAll(userID string) (int, error) {
+	return m.sessions.DeleteUserSessions(userID)
+}
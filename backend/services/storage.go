@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrObjectNotFound is returned by Storage for keys that do not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// defaultListLimit is the page size used when ListOptions.Limit is not set
+const defaultListLimit = 1000
+
+// Storage is the object store media, previews and subtitles are kept in. Keys are
+// slash-separated paths such as "library/video_{id}.mp4".
+type Storage interface {
+	// Put stores size bytes read from body under key, replacing any existing object
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get streams a whole object; the caller closes the body
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetRange streams length bytes from offset, or the rest of the object when length < 0
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Stat describes an object without reading it
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// List returns one page of the objects under opts.Prefix in key order
+	List(ctx context.Context, opts ListOptions) (*ListPage, error)
+	// Delete removes an object; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that lets a client fetch the object without credentials
+	// until expires has passed
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// MultipartStorage is implemented by backends that upload large files in parts
+type MultipartStorage interface {
+	// PutMultipart uploads size bytes of file as parallel parts, each verified by the
+	// backend against its own SHA-256 and retried on failure. checksum is the hex
+	// SHA-256 of the whole file, kept with the object. onPart is called with the size
+	// of every part that was uploaded.
+	PutMultipart(ctx context.Context, key string, file io.ReaderAt, size int64, contentType, checksum string, onPart func(int64)) error
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	ContentType  string
+}
+
+// ListOptions selects a page of objects. With a Delimiter, keys containing it after the
+// prefix are rolled up into Prefixes, like directories.
+type ListOptions struct {
+	Prefix    string
+	Delimiter string
+	Token     string // NextToken of the previous page; empty for the first page
+	Limit     int    // Maximum objects plus prefixes per page; defaultListLimit when zero
+}
+
+// ListPage is one page of a listing. NextToken is empty on the last page.
+type ListPage struct {
+	Objects   []ObjectInfo
+	Prefixes  []string
+	NextToken string
+}
+
+// MediaInput returns a location ffmpeg can read an object from: the file itself for
+// local storage, otherwise a presigned URL valid for expires
+func MediaInput(ctx context.Context, storage Storage, key string, expires time.Duration) (string, error) {
+	if local, ok := storage.(*LocalStorage); ok {
+		return local.Path(key)
+	}
+	return storage.PresignGet(ctx, key, expires)
+}
+
+// listAll follows continuation tokens until the listing is complete
+func listAll(ctx context.Context, storage Storage, opts ListOptions) ([]ObjectInfo, []string, error) {
+	var objects []ObjectInfo
+	var prefixes []string
+	for {
+		page, err := storage.List(ctx, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, page.Objects...)
+		prefixes = append(prefixes, page.Prefixes...)
+		if page.NextToken == "" {
+			return objects, prefixes, nil
+		}
+		opts.Token = page.NextToken
+	}
+}
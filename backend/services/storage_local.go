@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorageURLPrefix is where signed URLs for a local storage are served from
+const LocalStorageURLPrefix = "/api/storage/"
+
+// LocalStorage keeps objects as files under a directory, for deployments without S3.
+// Presigned URLs point at the storage handler and carry an HMAC of key and expiry.
+type LocalStorage struct {
+	root       string
+	signingKey []byte
+}
+
+// NewLocalStorage creates a storage rooted at dir. URLs are signed with signingKey, or
+// with a random key (so they do not survive a restart) when it is empty.
+func NewLocalStorage(dir, signingKey string) (*LocalStorage, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage directory: %v", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	key := []byte(signingKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %v", err)
+		}
+	}
+	return &LocalStorage{root: root, signingKey: key}, nil
+}
+
+// Path returns the file an object is stored in
+func (s *LocalStorage) Path(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if key == "" || clean != key || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes body to a temporary file and renames it into place
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	target, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("wrote %d of %d bytes", written, size)
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// Get opens an object's file
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.GetRange(ctx, key, 0, -1)
+}
+
+// GetRange opens an object's file at offset
+func (s *LocalStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	file, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+func (s *LocalStorage) open(key string) (*os.File, error) {
+	name, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return file, err
+}
+
+// Stat describes an object's file. The ETag is derived from its size and modification time.
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	name, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(name)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	object := localObjectInfo(key, info)
+	return &object, nil
+}
+
+func localObjectInfo(key string, info fs.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		ETag:         fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+		LastModified: info.ModTime(),
+		ContentType:  mime.TypeByExtension(path.Ext(key)),
+	}
+}
+
+// List walks the directory holding opts.Prefix and returns the matching keys in byte
+// order, like S3. The token is the last key or prefix of the previous page.
+func (s *LocalStorage) List(ctx context.Context, opts ListOptions) (*ListPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	// Only the directory the prefix points into can contain matches
+	dir := s.root
+	if i := strings.LastIndex(opts.Prefix, "/"); i >= 0 {
+		dir = filepath.Join(s.root, filepath.FromSlash(opts.Prefix[:i]))
+	}
+	var objects []ObjectInfo
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, opts.Prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, localObjectInfo(key, info))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	page := &ListPage{}
+	last := ""
+	for _, object := range objects {
+		entry := object.Key
+		isPrefix := false
+		if opts.Delimiter != "" {
+			if i := strings.Index(object.Key[len(opts.Prefix):], opts.Delimiter); i >= 0 {
+				entry, isPrefix = object.Key[:len(opts.Prefix)+i+len(opts.Delimiter)], true
+			}
+		}
+		if entry <= opts.Token || entry == last {
+			continue
+		}
+		if len(page.Objects)+len(page.Prefixes) == limit {
+			page.NextToken = last
+			break
+		}
+		if isPrefix {
+			page.Prefixes = append(page.Prefixes, entry)
+		} else {
+			page.Objects = append(page.Objects, object)
+		}
+		last = entry
+	}
+	return page, nil
+}
+
+// Delete removes an object's file
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PresignGet returns a signed URL served by the storage handler, relative to the site root
+func (s *LocalStorage) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if _, err := s.Path(key); err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{"expires": {expiry}, "signature": {s.sign(key, expiry)}}
+	return LocalStorageURLPrefix + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// Verify checks a signed URL's expiry and signature
+func (s *LocalStorage) Verify(key, expiry, signature string) bool {
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(key, expiry)))
+}
+
+func (s *LocalStorage) sign(key, expiry string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(key + "\n" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
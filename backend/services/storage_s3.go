@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	multipartPartSize    = 16 << 20 // Smallest part; larger files use bigger parts
+	multipartMaxParts    = 10000    // S3 limit on parts per upload
+	multipartConcurrency = 4        // Parts uploaded at the same time
+	multipartAttempts    = 3        // Tries per part before the upload is aborted
+)
+
+// S3Storage keeps objects in an S3 (or S3-compatible, e.g. MinIO) bucket
+type S3Storage struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Storage creates an S3 storage for bucket using the default AWS configuration
+// (AWS_REGION, credentials and AWS_ENDPOINT_URL from the environment)
+func NewS3Storage(bucket string) (*S3Storage, error) {
+	// Get region from environment or default to us-east-1
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1" // Default region if not specified
+	}
+
+	log.Printf("Initializing S3 client with region: %s for bucket: %s", region, bucket)
+
+	// Load the AWS configuration with explicit region
+	cfg, err := config.LoadDefaultConfig(context.TODO(),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config: %v", err)
+	}
+
+	return &S3Storage{
+		client: s3.NewFromConfig(cfg),
+		bucket: bucket,
+	}, nil
+}
+
+// Put uploads body as key in a single request
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+	})
+	return err
+}
+
+// PutMultipart uploads file in parts using S3's multipart API. Each part carries its
+// SHA-256 so S3 rejects corrupted parts; the whole-file checksum is stored as the
+// "sha256" object metadata. A failed upload is aborted so no parts are left behind.
+func (s *S3Storage) PutMultipart(ctx context.Context, key string, file io.ReaderAt, size int64, contentType, checksum string, onPart func(int64)) error {
+	partSize := int64(multipartPartSize)
+	if min := (size + multipartMaxParts - 1) / multipartMaxParts; min > partSize {
+		partSize = min
+	}
+
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(key),
+		ContentType:       aws.String(contentType),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		Metadata:          map[string]string{"sha256": checksum},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %v", err)
+	}
+	uploadID := created.UploadId
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make(chan int32)
+	var mu sync.Mutex
+	var completed []types.CompletedPart
+	var uploadErr error
+	var wg sync.WaitGroup
+	for i := 0; i < multipartConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range parts {
+				offset := int64(number-1) * partSize
+				length := partSize
+				if offset+length > size {
+					length = size - offset
+				}
+				part, err := s.uploadPart(ctx, key, uploadID, number, io.NewSectionReader(file, offset, length))
+				mu.Lock()
+				if err != nil {
+					if uploadErr == nil {
+						uploadErr = err
+						cancel()
+					}
+				} else {
+					completed = append(completed, *part)
+				}
+				mu.Unlock()
+				if err == nil && onPart != nil {
+					onPart(length)
+				}
+			}
+		}()
+	}
+	count := int32((size + partSize - 1) / partSize)
+feed:
+	for number := int32(1); number <= count; number++ {
+		select {
+		case parts <- number:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(parts)
+	wg.Wait()
+
+	if uploadErr == nil {
+		uploadErr = ctx.Err()
+	}
+	if uploadErr == nil {
+		sort.Slice(completed, func(i, j int) bool {
+			return aws.ToInt32(completed[i].PartNumber) < aws.ToInt32(completed[j].PartNumber)
+		})
+		_, uploadErr = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(key),
+			UploadId:        uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		})
+	}
+	if uploadErr != nil {
+		// The upload context may be cancelled already
+		abortCtx, abortCancel := context.WithTimeout(context.Background(), time.Minute)
+		defer abortCancel()
+		if _, err := s.client.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: uploadID,
+		}); err != nil {
+			log.Printf("Warning: failed to abort multipart upload of %s: %v", key, err)
+		}
+		return fmt.Errorf("multipart upload failed: %v", uploadErr)
+	}
+	return nil
+}
+
+// uploadPart uploads one part with its SHA-256, retrying with a growing delay
+func (s *S3Storage) uploadPart(ctx context.Context, key string, uploadID *string, number int32, body *io.SectionReader) (*types.CompletedPart, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return nil, fmt.Errorf("failed to read part %d: %v", number, err)
+	}
+	checksum := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+
+	var err error
+	for attempt := 1; attempt <= multipartAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("Retrying part %d of %s (attempt %d): %v", number, key, attempt, err)
+			select {
+			case <-time.After(time.Duration(attempt-1) * 2 * time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if _, err = body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		var out *s3.UploadPartOutput
+		out, err = s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:         aws.String(s.bucket),
+			Key:            aws.String(key),
+			UploadId:       uploadID,
+			PartNumber:     aws.Int32(number),
+			Body:           body,
+			ContentLength:  aws.Int64(body.Size()),
+			ChecksumSHA256: aws.String(checksum),
+		})
+		if err == nil {
+			return &types.CompletedPart{
+				PartNumber:     aws.Int32(number),
+				ETag:           out.ETag,
+				ChecksumSHA256: out.ChecksumSHA256,
+			}, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("part %d: %v", number, err)
+}
+
+// Get streams an object
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.GetRange(ctx, key, 0, -1)
+}
+
+// GetRange streams part of an object using an HTTP Range request
+func (s *S3Storage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if length >= 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	out, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return out.Body, nil
+}
+
+// Stat reads an object's metadata with a HEAD request
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(out.ContentLength),
+		ETag:         strings.Trim(aws.ToString(out.ETag), `"`),
+		LastModified: aws.ToTime(out.LastModified),
+		ContentType:  aws.ToString(out.ContentType),
+	}, nil
+}
+
+// List returns one ListObjectsV2 page
+func (s *S3Storage) List(ctx context.Context, opts ListOptions) (*ListPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		MaxKeys: aws.Int32(int32(limit)),
+	}
+	if opts.Prefix != "" {
+		input.Prefix = aws.String(opts.Prefix)
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+	if opts.Token != "" {
+		input.ContinuationToken = aws.String(opts.Token)
+	}
+
+	out, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	page := &ListPage{}
+	for _, object := range out.Contents {
+		page.Objects = append(page.Objects, ObjectInfo{
+			Key:          aws.ToString(object.Key),
+			Size:         aws.ToInt64(object.Size),
+			ETag:         strings.Trim(aws.ToString(object.ETag), `"`),
+			LastModified: aws.ToTime(object.LastModified),
+		})
+	}
+	for _, prefix := range out.CommonPrefixes {
+		page.Prefixes = append(page.Prefixes, aws.ToString(prefix.Prefix))
+	}
+	if aws.ToBool(out.IsTruncated) {
+		page.NextToken = aws.ToString(out.NextContinuationToken)
+	}
+	return page, nil
+}
+
+// Delete removes an object
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// PresignGet returns a pre-signed GET URL for an object
+func (s *S3Storage) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	presignedURL, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, func(po *s3.PresignOptions) {
+		po.Expires = expires
+	})
+	if err != nil {
+		return "", err
+	}
+	return presignedURL.URL, nil
+}
+
+// s3Error maps S3's missing-object errors to ErrObjectNotFound
+func s3Error(err error) error {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+		return fmt.Errorf("%w: %v", ErrObjectNotFound, err)
+	}
+	return err
+}
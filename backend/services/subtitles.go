@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// subtitleCacheTTL bounds how long parsed cues and track lists are served from memory
+const subtitleCacheTTL = time.Minute
+
+// ErrSubtitleNotFound is returned when a video has no track in the requested language
+var ErrSubtitleNotFound = errors.New("subtitle track not found")
+
+// MaxSubtitleBytes caps the size of a subtitle file accepted for one track
+const MaxSubtitleBytes = 5 << 20
+
+var (
+	// cueTimingPattern matches SRT ("00:00:01,000 --> ...") and WebVTT ("00:01.000 --> ...") timings
+	cueTimingPattern = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})`)
+
+	// unsupportedCueMarkup is SRT/ASS styling WebVTT does not understand
+	unsupportedCueMarkup = regexp.MustCompile(`</?font[^>]*>|\{\\[^}]*\}`)
+)
+
+// SubtitleURL is the URL a video's track in one language is served from, relative to /api
+func SubtitleURL(videoID, lang string) string {
+	return fmt.Sprintf("/subtitles/%s/%s.vtt", videoID, lang)
+}
+
+// ParseSubtitles reads SRT or WebVTT into cues sorted by start time. Blocks without a
+// timing line (headers, NOTE, STYLE, REGION) are skipped.
+func ParseSubtitles(data []byte) ([]models.SubtitleCue, error) {
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("subtitles must be UTF-8 encoded")
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var cues []models.SubtitleCue
+	var block []string
+	flush := func() {
+		defer func() { block = block[:0] }()
+		for i, line := range block {
+			m := cueTimingPattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			start, err1 := parseCueTime(m[1])
+			end, err2 := parseCueTime(m[2])
+			body := strings.TrimSpace(unsupportedCueMarkup.ReplaceAllString(strings.Join(block[i+1:], "\n"), ""))
+			if err1 == nil && err2 == nil && end > start && body != "" {
+				// "-->" would be read as a timing line by players
+				cues = append(cues, models.SubtitleCue{Start: start, End: end, Text: strings.ReplaceAll(body, "-->", "->")})
+			}
+			return
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		block = append(block, line)
+	}
+	flush()
+
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("no subtitle cues found")
+	}
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+	return cues, nil
+}
+
+// parseCueTime parses [HH:]MM:SS,mmm or [HH:]MM:SS.mmm into seconds
+func parseCueTime(value string) (float64, error) {
+	parts := strings.Split(strings.Replace(value, ",", ".", 1), ":")
+	seconds := 0.0
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, err
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+// FormatWebVTT writes cues as a WebVTT file
+func FormatWebVTT(cues []models.SubtitleCue) []byte {
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&vtt, "\n%s --> %s\n%s\n", vttTimestamp(cue.Start), vttTimestamp(cue.End), cue.Text)
+	}
+	return []byte(vtt.String())
+}
+
+// SubtitleService stores subtitle tracks as WebVTT in S3, records them on the video row
+// and serves parsed cues for live delivery
+type SubtitleService struct {
+	videoService *VideoService
+	db           repository.VideoRepository
+
+	mu     sync.Mutex
+	cues   map[string]subtitleCacheEntry // by S3 key
+	tracks map[string]trackCacheEntry    // by video ID
+}
+
+type subtitleCacheEntry struct {
+	cues     []models.SubtitleCue
+	loadedAt time.Time
+}
+
+type trackCacheEntry struct {
+	tracks   []models.SubtitleTrack
+	loadedAt time.Time
+}
+
+// NewSubtitleService creates a subtitle service
+func NewSubtitleService(videoService *VideoService, db repository.VideoRepository) *SubtitleService {
+	return &SubtitleService{
+		videoService: videoService,
+		db:           db,
+		cues:         make(map[string]subtitleCacheEntry),
+		tracks:       make(map[string]trackCacheEntry),
+	}
+}
+
+// Store converts SRT or WebVTT data to WebVTT and uploads it as the video's track in
+// track.Language, returning the track with its URL filled in. The video row is not updated.
+func (s *SubtitleService) Store(ctx context.Context, videoID string, track models.SubtitleTrack, data []byte) (models.SubtitleTrack, error) {
+	if !ValidLanguage(track.Language) {
+		return track, fmt.Errorf("invalid subtitle language %q", track.Language)
+	}
+	if track.Kind != models.SubtitleKindCaptions {
+		track.Kind = models.SubtitleKindSubtitles
+	}
+	cues, err := ParseSubtitles(data)
+	if err != nil {
+		return track, err
+	}
+
+	key := keys.Subtitle(videoID, track.Language)
+	if err := s.videoService.PutObject(ctx, key, "text/vtt", FormatWebVTT(cues)); err != nil {
+		return track, fmt.Errorf("subtitle upload failed: %v", err)
+	}
+	s.mu.Lock()
+	s.cues[key] = subtitleCacheEntry{cues: cues, loadedAt: time.Now()}
+	s.mu.Unlock()
+
+	track.URL = SubtitleURL(videoID, track.Language)
+	return track, nil
+}
+
+// AddTracks records tracks on the video (or library asset), replacing any existing track
+// in the same language
+func (s *SubtitleService) AddTracks(videoID string, tracks ...models.SubtitleTrack) ([]models.SubtitleTrack, error) {
+	merged, err := s.db.GetSubtitles(videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load video: %v", err)
+	}
+	for _, track := range tracks {
+		replaced := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Language, track.Language) {
+				merged[i], replaced = track, true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, track)
+		}
+	}
+	if err := s.db.UpdateVideoSubtitles(videoID, merged); err != nil {
+		return nil, fmt.Errorf("failed to record subtitles: %v", err)
+	}
+	s.forgetTracks(videoID)
+	return merged, nil
+}
+
+// Remove deletes the video's track in lang from S3 and the video row
+func (s *SubtitleService) Remove(ctx context.Context, videoID, lang string) ([]models.SubtitleTrack, error) {
+	existing, err := s.db.GetSubtitles(videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load video: %v", err)
+	}
+	remaining := make([]models.SubtitleTrack, 0, len(existing))
+	removed := ""
+	for _, track := range existing {
+		if strings.EqualFold(track.Language, lang) {
+			removed = track.Language
+		} else {
+			remaining = append(remaining, track)
+		}
+	}
+	if removed == "" {
+		return nil, ErrSubtitleNotFound
+	}
+
+	key := keys.Subtitle(videoID, removed)
+	if err := s.videoService.DeleteObject(ctx, key); err != nil {
+		log.Printf("Warning: Failed to delete subtitle object %s: %v", key, err)
+	}
+	if err := s.db.UpdateVideoSubtitles(videoID, remaining); err != nil {
+		return nil, fmt.Errorf("failed to record subtitles: %v", err)
+	}
+	s.mu.Lock()
+	delete(s.cues, key)
+	s.mu.Unlock()
+	s.forgetTracks(videoID)
+	return remaining, nil
+}
+
+// Tracks returns the subtitle tracks recorded for a video or library asset. Videos
+// without a row (synced-only S3 objects) have none.
+func (s *SubtitleService) Tracks(videoID string) []models.SubtitleTrack {
+	s.mu.Lock()
+	entry, ok := s.tracks[videoID]
+	s.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < subtitleCacheTTL {
+		return entry.tracks
+	}
+
+	tracks, _ := s.db.GetSubtitles(videoID)
+	s.mu.Lock()
+	s.tracks[videoID] = trackCacheEntry{tracks: tracks, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return tracks
+}
+
+// Cues returns the parsed cues of a video's track in lang, loading them from S3 on
+// first use. A missing track yields no cues and no error.
+func (s *SubtitleService) Cues(ctx context.Context, videoID, lang string) ([]models.SubtitleCue, error) {
+	key := keys.Subtitle(videoID, lang)
+	s.mu.Lock()
+	entry, ok := s.cues[key]
+	s.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < subtitleCacheTTL {
+		return entry.cues, nil
+	}
+
+	var cues []models.SubtitleCue
+	exists, err := s.videoService.ObjectExists(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		body, err := s.videoService.OpenObject(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(body, MaxSubtitleBytes))
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if cues, err = ParseSubtitles(data); err != nil {
+			return nil, fmt.Errorf("unreadable subtitles %s: %v", key, err)
+		}
+	}
+
+	s.mu.Lock()
+	s.cues[key] = subtitleCacheEntry{cues: cues, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return cues, nil
+}
+
+func (s *SubtitleService) forgetTracks(videoID string) {
+	s.mu.Lock()
+	delete(s.tracks, videoID)
+	s.mu.Unlock()
+}
+
+// extractSubtitleStream converts one embedded text subtitle stream to WebVTT
+func extractSubtitleStream(ctx context.Context, path string, index int) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-i", path,
+		"-map", fmt.Sprintf("0:%d", index),
+		"-c:s", "webvtt",
+		"-f", "webvtt",
+		"pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v - %s", err, stderr.String())
+	}
+	return output, nil
+}
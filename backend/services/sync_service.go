@@ -16,11 +16,13 @@ type ChannelManager interface {
 type SyncService struct {
 	s3Manager      *S3Manager
 	channelManager ChannelManager
+	thumbnails     *ThumbnailGenerator
 	syncInterval   time.Duration
 }
 
-// NewSyncService creates a new service to periodically sync S3 content
-func NewSyncService(s3Manager *S3Manager, channelManager ChannelManager, syncIntervalMinutes int) *SyncService {
+// NewSyncService creates a new service to periodically sync S3 content. When thumbnails
+// is set, synced videos without previews get them generated in the background.
+func NewSyncService(s3Manager *S3Manager, channelManager ChannelManager, syncIntervalMinutes int, thumbnails *ThumbnailGenerator) *SyncService {
 	if syncIntervalMinutes <= 0 {
 		syncIntervalMinutes = 15 // Default to 15 minutes if invalid
 	}
@@ -28,6 +30,7 @@ func NewSyncService(s3Manager *S3Manager, channelManager ChannelManager, syncInt
 	return &SyncService{
 		s3Manager:      s3Manager,
 		channelManager: channelManager,
+		thumbnails:     thumbnails,
 		syncInterval:   time.Duration(syncIntervalMinutes) * time.Minute,
 	}
 }
@@ -61,11 +64,13 @@ func (ss *SyncService) syncVideos() {
 	}
 	
 	// Get updated channels and videos from S3
-	channels, videos, err := ss.s3Manager.GetChannelsAndVideos()
+	channels, videos, changes, err := ss.s3Manager.GetChannelsAndVideos()
 	if err != nil {
 		log.Printf("Error syncing S3 content: %v", err)
 		return
 	}
+	log.Printf("S3 sync: %d objects added, %d changed, %d removed",
+		len(changes.Added), len(changes.Changed), len(changes.Removed))
 	
 	// Update the channel manager with the new content using reflection
 	// We use type assertion to call the InitializeWithS3Content method without
@@ -77,4 +82,28 @@ func (ss *SyncService) syncVideos() {
 	} else {
 		log.Printf("Error: ChannelManager does not implement required interface for initialization")
 	}
+
+	// Videos placed in S3 directly never went through the ingest pipeline. Only new and
+	// replaced objects are checked, replaced ones get fresh previews, and failures are
+	// retried on the next sync.
+	if ss.thumbnails != nil && len(changes.Added)+len(changes.Changed) > 0 {
+		updated := make(map[string]bool)
+		for _, key := range changes.Added {
+			updated[key] = true
+		}
+		replaced := make(map[string]bool)
+		for _, key := range changes.Changed {
+			updated[key] = true
+			replaced[key] = true
+		}
+		pending := make(map[string]*models.Video)
+		for id, video := range videos {
+			if updated[video.S3Key] {
+				pending[id] = video
+			}
+		}
+		go func() {
+			ss.s3Manager.Forget(ss.thumbnails.Backfill(pending, replaced))
+		}()
+	}
 }
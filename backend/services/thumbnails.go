@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live-broadcast-backend/keys"
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sprite sheet layout: tiles are spriteTileWidth x spriteTileHeight, spriteColumns per row
+const (
+	spriteTileWidth  = 160
+	spriteTileHeight = 90
+	spriteColumns    = 10
+	spriteMaxTiles   = 100
+	spriteMinSpacing = 2.0 // Seconds between tiles for short videos
+)
+
+// ThumbnailSet is the set of preview images generated for a video. URLs are
+// relative to /api, like the existing thumbnail URLs.
+type ThumbnailSet struct {
+	ThumbnailURL string
+	SpriteURL    string
+	SpriteVTTURL string
+}
+
+// ThumbnailGenerator extracts a representative frame, a sprite sheet and a WebVTT
+// thumbnail track for a video and stores them under thumbnails/ in S3
+type ThumbnailGenerator struct {
+	videoService *VideoService
+	db           repository.VideoRepository
+	tempDir      string
+
+	mu          sync.Mutex
+	backfilling bool
+}
+
+// NewThumbnailGenerator creates a thumbnail generator working in tempDir
+func NewThumbnailGenerator(videoService *VideoService, db repository.VideoRepository, tempDir string) *ThumbnailGenerator {
+	return &ThumbnailGenerator{
+		videoService: videoService,
+		db:           db,
+		tempDir:      tempDir,
+	}
+}
+
+// ThumbnailURLs returns the URLs a video's previews are served from
+func ThumbnailURLs(id string) ThumbnailSet {
+	return ThumbnailSet{
+		ThumbnailURL: keys.PreviewURL(keys.Thumbnail(id)),
+		SpriteURL:    keys.PreviewURL(keys.Sprite(id)),
+		SpriteVTTURL: keys.PreviewURL(keys.SpriteVTT(id)),
+	}
+}
+
+// Generate builds and uploads previews for the video at input, which may be a local
+// path or a URL ffmpeg can read. An existing thumbnail file (e.g. from yt-dlp) is
+// used instead of extracting a frame when given. Sprite failures are logged and
+// leave the sprite URLs empty.
+func (g *ThumbnailGenerator) Generate(ctx context.Context, id, input string, duration float64, thumbnailPath string) (*ThumbnailSet, error) {
+	thumbnailURL, err := g.generateThumbnail(ctx, id, input, duration, thumbnailPath)
+	if err != nil {
+		return nil, err
+	}
+	set := &ThumbnailSet{ThumbnailURL: thumbnailURL}
+	set.SpriteURL, set.SpriteVTTURL = g.generateSprite(ctx, id, input, duration)
+	return set, nil
+}
+
+// generateThumbnail uploads thumbnailPath, or a frame extracted from input when empty
+func (g *ThumbnailGenerator) generateThumbnail(ctx context.Context, id, input string, duration float64, thumbnailPath string) (string, error) {
+	thumbKey := keys.Thumbnail(id)
+	if thumbnailPath == "" {
+		thumbnailPath = filepath.Join(g.tempDir, fmt.Sprintf("%s_%d_thumb.jpg", id, time.Now().UnixNano()))
+		defer os.Remove(thumbnailPath)
+		if err := extractThumbnail(ctx, input, duration, thumbnailPath); err != nil {
+			return "", err
+		}
+	}
+	if _, err := uploadFile(ctx, g.videoService, thumbnailPath, thumbKey, "image/jpeg", nil); err != nil {
+		return "", fmt.Errorf("thumbnail upload failed: %v", err)
+	}
+	return ThumbnailURLs(id).ThumbnailURL, nil
+}
+
+// generateSprite builds and uploads the sprite sheet and its WebVTT track, returning
+// empty URLs when that is not possible
+func (g *ThumbnailGenerator) generateSprite(ctx context.Context, id, input string, duration float64) (string, string) {
+	if duration <= 0 {
+		log.Printf("Warning: Skipping preview sprite for %s: unknown duration", id)
+		return "", ""
+	}
+
+	spriteKey, vttKey := keys.Sprite(id), keys.SpriteVTT(id)
+	stamp := time.Now().UnixNano()
+	spritePath := filepath.Join(g.tempDir, fmt.Sprintf("%s_%d_sprite.jpg", id, stamp))
+	vttPath := filepath.Join(g.tempDir, fmt.Sprintf("%s_%d_sprite.vtt", id, stamp))
+	defer os.Remove(spritePath)
+	defer os.Remove(vttPath)
+
+	if err := buildSprite(ctx, input, duration, spritePath, vttPath, filepath.Base(spriteKey)); err != nil {
+		log.Printf("Warning: Failed to build preview sprite for %s: %v", id, err)
+		return "", ""
+	}
+	if _, err := uploadFile(ctx, g.videoService, spritePath, spriteKey, "image/jpeg", nil); err != nil {
+		log.Printf("Warning: Failed to upload preview sprite for %s: %v", id, err)
+		return "", ""
+	}
+	if _, err := uploadFile(ctx, g.videoService, vttPath, vttKey, "text/vtt", nil); err != nil {
+		log.Printf("Warning: Failed to upload preview track for %s: %v", id, err)
+		return "", ""
+	}
+	urls := ThumbnailURLs(id)
+	return urls.SpriteURL, urls.SpriteVTTURL
+}
+
+// extractThumbnail picks the most representative of a run of frames starting roughly
+// a tenth of the way into the video, skipping black or transitional frames
+func extractThumbnail(ctx context.Context, input string, duration float64, outPath string) error {
+	offset := duration * 0.1
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-ss", fmt.Sprintf("%.2f", offset),
+		"-i", input,
+		"-vf", "thumbnail=120,scale=640:-2",
+		"-frames:v", "1",
+		"-q:v", "3",
+		outPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %v - %s", err, string(output))
+	}
+	return nil
+}
+
+// buildSprite tiles evenly spaced frames into one image and writes a WebVTT track
+// mapping each time range to its tile (spriteName#xywh=x,y,w,h)
+func buildSprite(ctx context.Context, input string, duration float64, spritePath, vttPath, spriteName string) error {
+	spacing := math.Max(spriteMinSpacing, duration/spriteMaxTiles)
+	tiles := int(math.Ceil(duration / spacing))
+	if tiles > spriteMaxTiles {
+		tiles = spriteMaxTiles
+	}
+	columns := spriteColumns
+	if tiles < columns {
+		columns = tiles
+	}
+	rows := (tiles + columns - 1) / columns
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-i", input,
+		"-vf", fmt.Sprintf("fps=1/%.3f,scale=%[2]d:%[3]d:force_original_aspect_ratio=decrease,pad=%[2]d:%[3]d:(ow-iw)/2:(oh-ih)/2,tile=%[4]dx%[5]d",
+			spacing, spriteTileWidth, spriteTileHeight, columns, rows),
+		"-frames:v", "1",
+		"-q:v", "5",
+		spritePath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %v - %s", err, string(output))
+	}
+
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+	for i := 0; i < tiles; i++ {
+		start := float64(i) * spacing
+		end := math.Min(start+spacing, duration)
+		fmt.Fprintf(&vtt, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end), spriteName,
+			(i%columns)*spriteTileWidth, (i/columns)*spriteTileHeight, spriteTileWidth, spriteTileHeight)
+	}
+	return os.WriteFile(vttPath, []byte(vtt.String()), 0644)
+}
+
+// vttTimestamp formats seconds as HH:MM:SS.mmm
+func vttTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Backfill generates previews for synced videos that have no sprite yet, and again for
+// those whose S3 key is in replaced, reading each video straight from S3. Only one
+// backfill runs at a time. It returns the keys of videos it failed on or, when another
+// backfill is running, skipped.
+func (g *ThumbnailGenerator) Backfill(videos map[string]*models.Video, replaced map[string]bool) []string {
+	g.mu.Lock()
+	if g.backfilling {
+		g.mu.Unlock()
+		keys := make([]string, 0, len(videos))
+		for _, video := range videos {
+			keys = append(keys, video.S3Key)
+		}
+		return keys
+	}
+	g.backfilling = true
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.backfilling = false
+		g.mu.Unlock()
+	}()
+
+	upToDate := 0
+	var failed []string
+	for _, video := range videos {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		if err := g.backfillOne(ctx, keys.PreviewID(video.S3Key, video.ID), video.S3Key, replaced[video.S3Key]); err != nil {
+			log.Printf("Warning: Preview backfill failed for %s: %v", video.S3Key, err)
+			failed = append(failed, video.S3Key)
+		} else {
+			upToDate++
+		}
+		cancel()
+	}
+	log.Printf("Preview backfill finished: %d of %d videos have previews", upToDate, len(videos))
+	return failed
+}
+
+func (g *ThumbnailGenerator) backfillOne(ctx context.Context, id, s3Key string, replaced bool) error {
+	thumbKey, spriteKey := keys.Thumbnail(id), keys.Sprite(id)
+	hasThumbnail := false
+	if !replaced {
+		hasSprite, err := g.videoService.ObjectExists(ctx, spriteKey)
+		if err != nil || hasSprite {
+			return err
+		}
+		if hasThumbnail, err = g.videoService.ObjectExists(ctx, thumbKey); err != nil {
+			return err
+		}
+	}
+
+	input, err := MediaInput(ctx, g.videoService.storage, s3Key, time.Hour)
+	if err != nil {
+		return fmt.Errorf("failed to locate video: %v", err)
+	}
+	info, err := ProbeMedia(ctx, input)
+	if err != nil {
+		return err
+	}
+	if !info.HasVideo {
+		return fmt.Errorf("object has no video stream")
+	}
+
+	// Keep thumbnails that already exist, e.g. ones fetched from YouTube
+	urls := ThumbnailURLs(id)
+	if !hasThumbnail {
+		if urls.ThumbnailURL, err = g.generateThumbnail(ctx, id, input, info.Duration, ""); err != nil {
+			return err
+		}
+	}
+	urls.SpriteURL, urls.SpriteVTTURL = g.generateSprite(ctx, id, input, info.Duration)
+	if urls.SpriteURL == "" {
+		return fmt.Errorf("sprite generation failed")
+	}
+
+	// Synced-only videos have no row; the update is then a no-op
+	return g.db.UpdateVideoThumbnails(id, urls.ThumbnailURL, urls.SpriteURL, urls.SpriteVTTURL)
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUploadNotFound is returned for unknown or removed upload IDs
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrOffsetMismatch is returned when a chunk does not start at the current upload offset
+	ErrOffsetMismatch = errors.New("chunk offset does not match upload offset")
+	// ErrUploadTooLarge is returned when a chunk would exceed the declared upload size
+	ErrUploadTooLarge = errors.New("chunk exceeds declared upload size")
+	// ErrChecksumMismatch is returned when a chunk does not match its declared checksum
+	ErrChecksumMismatch = errors.New("chunk checksum mismatch")
+)
+
+// ChunkChecksum is the expected digest of a single chunk
+type ChunkChecksum struct {
+	Hash     hash.Hash
+	Expected []byte
+}
+
+// UploadInfo is the persisted state of an upload in progress
+type UploadInfo struct {
+	ID          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`   // Declared total size in bytes
+	Offset      int64     `json:"offset"` // Bytes received so far
+	ChannelID   int       `json:"channelId"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedBy   string    `json:"createdBy"`
+	VideoID     string    `json:"videoId,omitempty"` // Set once the upload has been queued for ingest
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"` // Incomplete uploads are purged after this time
+}
+
+// Complete reports whether every declared byte has been received
+func (u *UploadInfo) Complete() bool {
+	return u.Offset == u.Size
+}
+
+// UploadStore keeps partially uploaded media on disk so uploads survive restarts.
+// Each upload is a {id}.data file plus a {id}.info JSON sidecar.
+type UploadStore struct {
+	dir    string
+	expiry time.Duration
+	mu     sync.Mutex
+	locks  map[string]*sync.Mutex
+}
+
+// NewUploadStore creates an upload store rooted at dir. Uploads that receive no data
+// for the expiry duration are purged.
+func NewUploadStore(dir string, expiry time.Duration) (*UploadStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %v", err)
+	}
+	if expiry <= 0 {
+		expiry = 24 * time.Hour
+	}
+	return &UploadStore{
+		dir:    dir,
+		expiry: expiry,
+		locks:  make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// Start purges expired uploads now and then at the given interval in the background
+func (s *UploadStore) Start(interval time.Duration) {
+	go func() {
+		s.PurgeExpired()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.PurgeExpired()
+		}
+	}()
+}
+
+// PurgeExpired removes incomplete uploads past their expiry. Uploads already queued
+// for ingest are left for the ingest job to clean up.
+func (s *UploadStore) PurgeExpired() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("Error scanning upload directory: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".info")
+		if id == entry.Name() {
+			continue
+		}
+		info, err := s.Get(id)
+		if err != nil || info.VideoID != "" || info.ExpiresAt.IsZero() || now.Before(info.ExpiresAt) {
+			continue
+		}
+		if err := s.Remove(id); err != nil {
+			log.Printf("Error removing expired upload %s: %v", id, err)
+		} else {
+			log.Printf("Removed expired upload %s (%s, %d of %d bytes)", id, info.Filename, info.Offset, info.Size)
+		}
+	}
+}
+
+// lock serialises writes to a single upload
+func (s *UploadStore) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[id] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// validID guards the filesystem against path traversal through upload IDs
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+// DataPath returns the location of the upload's bytes on disk
+func (s *UploadStore) DataPath(id string) string {
+	return filepath.Join(s.dir, id+".data")
+}
+
+func (s *UploadStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+// Create registers a new empty upload and assigns its ID
+func (s *UploadStore) Create(info *UploadInfo) error {
+	info.ID = uuid.New().String()
+	info.Offset = 0
+	info.CreatedAt = time.Now()
+	info.UpdatedAt = info.CreatedAt
+	info.ExpiresAt = info.CreatedAt.Add(s.expiry)
+
+	f, err := os.Create(s.DataPath(info.ID))
+	if err != nil {
+		return fmt.Errorf("failed to create upload file: %v", err)
+	}
+	f.Close()
+
+	return s.saveInfo(info)
+}
+
+// Import stores a complete upload from a single stream, e.g. a multipart form file.
+// The upload size is whatever the stream contains.
+func (s *UploadStore) Import(info *UploadInfo, r io.Reader) error {
+	if err := s.Create(info); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.DataPath(info.ID), os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open upload file: %v", err)
+	}
+	n, err := io.Copy(f, r)
+	f.Close()
+	if err != nil {
+		s.Remove(info.ID)
+		return fmt.Errorf("failed to store upload: %v", err)
+	}
+
+	info.Size = n
+	info.Offset = n
+	info.UpdatedAt = time.Now()
+	return s.saveInfo(info)
+}
+
+// Get loads the current state of an upload
+func (s *UploadStore) Get(id string) (*UploadInfo, error) {
+	if !validID(id) {
+		return nil, ErrUploadNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var info UploadInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("corrupt upload info for %s: %v", id, err)
+	}
+
+	// The data file is authoritative: a crash between writing bytes and saving
+	// the sidecar leaves a valid prefix that the client can resume after
+	if stat, err := os.Stat(s.DataPath(id)); err == nil {
+		info.Offset = stat.Size()
+	}
+	return &info, nil
+}
+
+// Expired reports whether an incomplete upload has passed its expiry
+func (u *UploadInfo) Expired() bool {
+	return u.VideoID == "" && !u.ExpiresAt.IsZero() && time.Now().After(u.ExpiresAt)
+}
+
+// WriteChunk appends r at offset, which must equal the upload's current offset.
+// When check is given the chunk is discarded unless it arrives whole and matches
+// the expected digest. It returns the updated upload state.
+func (s *UploadStore) WriteChunk(id string, offset int64, r io.Reader, check *ChunkChecksum) (*UploadInfo, error) {
+	if !validID(id) {
+		return nil, ErrUploadNotFound
+	}
+	unlock := s.lock(id)
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Expired() {
+		return nil, ErrUploadNotFound
+	}
+	if offset != info.Offset {
+		return info, ErrOffsetMismatch
+	}
+	if check != nil {
+		r = io.TeeReader(r, check.Hash)
+	}
+
+	f, err := os.OpenFile(s.DataPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload file: %v", err)
+	}
+	defer f.Close()
+
+	// Read one byte past the remaining size to detect oversized chunks
+	remaining := info.Size - info.Offset
+	n, copyErr := io.Copy(f, io.LimitReader(r, remaining+1))
+	if n > remaining {
+		f.Truncate(info.Size)
+		n = remaining
+		copyErr = ErrUploadTooLarge
+	}
+
+	// A checksummed chunk is all-or-nothing
+	if check != nil && (copyErr != nil || !bytes.Equal(check.Hash.Sum(nil), check.Expected)) {
+		if err := f.Truncate(offset); err != nil {
+			return nil, fmt.Errorf("failed to discard chunk: %v", err)
+		}
+		if copyErr == nil {
+			copyErr = ErrChecksumMismatch
+		}
+		return info, copyErr
+	}
+
+	// Keep whatever arrived, even on a dropped connection, so the client can resume
+	info.Offset += n
+	info.UpdatedAt = time.Now()
+	info.ExpiresAt = info.UpdatedAt.Add(s.expiry)
+	if err := s.saveInfo(info); err != nil {
+		return nil, err
+	}
+	return info, copyErr
+}
+
+// SetVideoID records the video created from a completed upload
+func (s *UploadStore) SetVideoID(id string, videoID string) error {
+	unlock := s.lock(id)
+	defer unlock()
+
+	info, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	info.VideoID = videoID
+	info.UpdatedAt = time.Now()
+	return s.saveInfo(info)
+}
+
+// Remove deletes the upload's data and state
+func (s *UploadStore) Remove(id string) error {
+	if !validID(id) {
+		return ErrUploadNotFound
+	}
+	os.Remove(s.infoPath(id))
+	err := os.Remove(s.DataPath(id))
+
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// saveInfo atomically replaces the upload's sidecar
+func (s *UploadStore) saveInfo(info *UploadInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save upload info: %v", err)
+	}
+	return os.Rename(tmp, s.infoPath(info.ID))
+}
@@ -3,41 +3,56 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"live-broadcast-backend/database"
+	"live-broadcast-backend/keys"
 	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
 )
 
-// YouTubeDownloader handles downloading videos from YouTube and uploading to S3
+// YouTubeDownloader handles downloading videos from YouTube, other yt-dlp supported sites and
+// plain HTTP(S) URLs and passing them to the ingest pipeline
 type YouTubeDownloader struct {
-	videoService *VideoService
-	db           *database.DB
-	tempDir      string
+	db            repository.VideoRepository
+	queue         *JobQueue
+	pipeline      *IngestPipeline
+	tempDir       string
+	subtitleLangs string // Default yt-dlp --sub-langs list
+	ytdlp         func(ctx context.Context, args ...string) ([]byte, error) // Runs yt-dlp for its JSON output
 }
 
-// NewYouTubeDownloader creates a new YouTube downloader
-func NewYouTubeDownloader(videoService *VideoService, db *database.DB, tempDir string) (*YouTubeDownloader, error) {
+// NewYouTubeDownloader creates a new YouTube downloader and registers it with the ingest queue
+func NewYouTubeDownloader(db repository.VideoRepository, queue *JobQueue, pipeline *IngestPipeline, tempDir, subtitleLangs string) (*YouTubeDownloader, error) {
 	// Create temp directory if it doesn't exist
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create temp directory: %v", err)
 	}
 
-	return &YouTubeDownloader{
-		videoService: videoService,
-		db:           db,
-		tempDir:      tempDir,
-	}, nil
+	yd := &YouTubeDownloader{
+		db:            db,
+		queue:         queue,
+		pipeline:      pipeline,
+		tempDir:       tempDir,
+		subtitleLangs: subtitleLangs,
+		ytdlp:         runYTDLP,
+	}
+	queue.Register(models.JobKindYouTube, yd.handleJob)
+	queue.Register(models.JobKindHTTP, yd.handleJob)
+	queue.Register(models.JobKindExpand, yd.expandJob)
+	return yd, nil
 }
 
 // VideoMetadata holds extracted metadata from a YouTube video
@@ -46,25 +61,189 @@ type VideoMetadata struct {
 	Description string  `json:"description"`
 	Duration    float64 `json:"duration"`
 	ThumbnailURL string `json:"thumbnail_url"`
+	UploadDate  string  `json:"upload_date"` // YYYYMMDD when the site reports it
+	SubtitleLanguages    []string `json:"subtitle_languages,omitempty"`     // Languages with published subtitles
+	AutoCaptionLanguages []string `json:"auto_caption_languages,omitempty"` // Languages with automatic captions
+}
+
+// ErrInvalidSourceURL is returned for import sources that are not http(s) URLs
+var ErrInvalidSourceURL = errors.New("source URL must be an http(s) URL")
+
+// maxImportEntries caps how many entries one playlist or channel import may queue
+const maxImportEntries = 500
+
+// directMediaExtensions are URL path extensions downloaded over plain HTTP instead of yt-dlp
+var directMediaExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true,
+	".avi": true, ".ts": true, ".mpg": true, ".mpeg": true, ".flv": true, ".wmv": true,
+}
+
+// ImportEntry is a single video discovered at a remote source
+type ImportEntry struct {
+	SourceID string
+	URL      string
+	Title    string
+}
+
+// SkippedEntry is a discovered video that was not queued
+type SkippedEntry struct {
+	SourceID string `json:"sourceId"`
+	Title    string `json:"title,omitempty"`
+	Reason   string `json:"reason"`
+	VideoID  string `json:"videoId,omitempty"` // Existing video for duplicates
+}
+
+// ImportResult summarises what expanding a remote source queued
+type ImportResult struct {
+	VideoIDs []string       `json:"videoIds"`
+	Skipped  []SkippedEntry `json:"skipped,omitempty"`
+}
+
+// ytdlpEntry is the subset of yt-dlp's JSON info dict used to expand sources
+type ytdlpEntry struct {
+	Type         string       `json:"_type"`
+	ID           string       `json:"id"`
+	Title        string       `json:"title"`
+	URL          string       `json:"url"`
+	WebpageURL   string       `json:"webpage_url"`
+	ExtractorKey string       `json:"extractor_key"`
+	IEKey        string       `json:"ie_key"`
+	UploadDate   string       `json:"upload_date"`
+	Entries      []ytdlpEntry `json:"entries"`
+}
+
+// Import queues a job that expands sourceURL into one download job per video found. Listing
+// a playlist or channel can take minutes, so it happens on a worker rather than in the request.
+func (yd *YouTubeDownloader) Import(sourceURL string, channelID int, uploadedBy string, opts models.ImportOptions) (*models.IngestJob, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidSourceURL
+	}
+
+	job := &models.IngestJob{
+		Kind:      models.JobKindExpand,
+		SourceURL: sourceURL,
+		ChannelID: channelID,
+		Options:   opts,
+		CreatedBy: uploadedBy,
+	}
+	if err := yd.queue.Enqueue(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// expandJob is the JobHandler for expand jobs. A retry after a partial expansion skips the
+// entries already queued, by source ID.
+func (yd *YouTubeDownloader) expandJob(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error {
+	result, err := yd.queueSource(ctx, job.SourceURL, job.ChannelID, job.CreatedBy, job.Options)
+	if result != nil {
+		for _, skipped := range result.Skipped {
+			log.Printf("Import of %s skipped %s (%s): %s", job.SourceURL, skipped.SourceID, skipped.Title, skipped.Reason)
+		}
+		log.Printf("Import of %s queued %d videos, skipped %d", job.SourceURL, len(result.VideoIDs), len(result.Skipped))
+	}
+	return err
+}
+
+// queueSource queues every video found at sourceURL: a single video page on any yt-dlp
+// supported site, a playlist or channel (one job per entry), or a plain HTTP(S) media
+// file. Entries already on the channel are skipped by source ID; entries already on
+// another channel are handled by the duplicate policy in opts.
+func (yd *YouTubeDownloader) queueSource(ctx context.Context, sourceURL string, channelID int, uploadedBy string, opts models.ImportOptions) (*ImportResult, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, Permanent(ErrInvalidSourceURL)
+	}
+
+	kind := models.JobKindYouTube
+	var entries []ImportEntry
+	if isDirectMediaURL(ctx, u) {
+		kind = models.JobKindHTTP
+		name := path.Base(u.Path)
+		entries = []ImportEntry{{
+			SourceID: "http:" + u.String(),
+			URL:      sourceURL,
+			Title:    strings.TrimSuffix(name, path.Ext(name)),
+		}}
+	} else {
+		entries, err = yd.expandSource(ctx, sourceURL, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) == 0 {
+		return nil, Permanent(fmt.Errorf("no videos found at source URL"))
+	}
+
+	policy := yd.pipeline.DuplicatePolicy(opts.Duplicates)
+	result := &ImportResult{VideoIDs: []string{}}
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if seen[entry.SourceID] {
+			continue
+		}
+		seen[entry.SourceID] = true
+
+		existingID, err := yd.db.FindVideoBySource(channelID, entry.SourceID)
+		if err != nil {
+			return result, fmt.Errorf("failed to check for duplicate video: %v", err)
+		}
+		if existingID != "" {
+			result.Skipped = append(result.Skipped, SkippedEntry{
+				SourceID: entry.SourceID,
+				Title:    entry.Title,
+				Reason:   "already imported",
+				VideoID:  existingID,
+			})
+			continue
+		}
+		if policy == models.DuplicateReject {
+			existingID, err := yd.db.FindCompletedVideoBySource(entry.SourceID)
+			if err != nil {
+				return result, fmt.Errorf("failed to check for duplicate video: %v", err)
+			}
+			if existingID != "" {
+				result.Skipped = append(result.Skipped, SkippedEntry{
+					SourceID: entry.SourceID,
+					Title:    entry.Title,
+					Reason:   "already imported on another channel",
+					VideoID:  existingID,
+				})
+				continue
+			}
+		}
+
+		videoID, err := yd.queueEntry(entry, kind, channelID, uploadedBy, opts)
+		if err != nil {
+			return result, err
+		}
+		result.VideoIDs = append(result.VideoIDs, videoID)
+	}
+
+	return result, nil
 }
 
-// ProcessVideo queues a new video for download and processing
-func (yd *YouTubeDownloader) ProcessVideo(youtubeURL string, channelID int, uploadedBy string) (string, error) {
+// queueEntry creates a pending video for entry and queues its download
+func (yd *YouTubeDownloader) queueEntry(entry ImportEntry, kind models.JobKind, channelID int, uploadedBy string, opts models.ImportOptions) (string, error) {
 	// Generate a new ID for the video
 	videoID := uuid.New().String()
-	
-	// Use the video ID in the S3 key instead of sequential numbering
-	videoS3Key := fmt.Sprintf("channel_%d/video_%s.mp4", channelID, videoID)
-	
+
+	title := entry.Title
+	if title == "" {
+		title = "Processing..."
+	}
+
 	// Create a new video record in pending state
 	video := &models.AdminVideo{
-		ID:          videoID,
-		YoutubeURL:  youtubeURL,
-		S3Key:       videoS3Key,
-		ChannelID:   channelID,
-		Title:       "Processing...",
-		Status:      models.StatusPending,
-		UploadedBy:  uploadedBy,
+		ID:         videoID,
+		YoutubeURL: entry.URL,
+		S3Key:      keys.LibraryVideo(videoID),
+		ChannelID:  channelID,
+		Title:      title,
+		Status:     models.StatusPending,
+		UploadedBy: uploadedBy,
+		SourceID:   entry.SourceID,
 	}
 
 	// Save to database
@@ -72,22 +251,183 @@ func (yd *YouTubeDownloader) ProcessVideo(youtubeURL string, channelID int, uplo
 		return "", fmt.Errorf("failed to save video to database: %v", err)
 	}
 
-	// Start async download process
-	go yd.downloadAndUpload(videoID, youtubeURL, channelID)
+	// Queue the download; a worker picks it up when a slot is free
+	job := &models.IngestJob{
+		VideoID:   videoID,
+		Kind:      kind,
+		SourceURL: entry.URL,
+		ChannelID: channelID,
+		Options:   opts,
+		CreatedBy: uploadedBy,
+	}
+	if err := yd.queue.Enqueue(job); err != nil {
+		yd.db.UpdateVideoStatus(videoID, models.StatusFailed, err.Error())
+		return "", err
+	}
 
 	return videoID, nil
 }
 
-// getVideoMetadata extracts metadata from a YouTube video
-func (yd *YouTubeDownloader) getVideoMetadata(youtubeURL string) (*VideoMetadata, error) {
-	// Use yt-dlp to extract video metadata in JSON format with user-agent and browser cookies to bypass bot detection
-	cmd := exec.Command("yt-dlp", "-j", 
-		"--user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36", 
+// isDirectMediaURL reports whether u points straight at a media file rather than a page
+func isDirectMediaURL(ctx context.Context, u *url.URL) bool {
+	if directMediaExtensions[strings.ToLower(path.Ext(u.Path))] {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "video/")
+}
+
+// expandSource lists the videos behind a yt-dlp supported URL without downloading them
+func (yd *YouTubeDownloader) expandSource(ctx context.Context, sourceURL string, opts models.ImportOptions) ([]ImportEntry, error) {
+	limit := opts.Limit
+	if limit <= 0 || limit > maxImportEntries {
+		limit = maxImportEntries
+	}
+
+	info, err := yd.listSource(ctx, sourceURL, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ImportEntry
+	var collect func(e ytdlpEntry, depth int) error
+	collect = func(e ytdlpEntry, depth int) error {
+		if len(entries) >= limit {
+			return nil
+		}
+		switch {
+		case e.Type == "playlist":
+			for _, child := range e.Entries {
+				if err := collect(child, depth); err != nil {
+					return err
+				}
+			}
+		case e.Type == "url" && isPlaylistExtractor(e.IEKey) && depth < 1:
+			// Channel pages list their tabs (videos, shorts, ...) as nested playlists
+			nested, err := yd.listSource(ctx, e.URL, limit-len(entries))
+			if err != nil {
+				return err
+			}
+			return collect(*nested, depth+1)
+		case e.ID != "":
+			if !withinDateRange(opts, e.UploadDate) {
+				return nil
+			}
+			extractor := e.ExtractorKey
+			if extractor == "" {
+				extractor = e.IEKey
+			}
+			entryURL := e.WebpageURL
+			if entryURL == "" {
+				entryURL = e.URL
+			}
+			if entryURL == "" {
+				entryURL = sourceURL
+			}
+			entries = append(entries, ImportEntry{
+				SourceID: strings.ToLower(extractor) + ":" + e.ID,
+				URL:      entryURL,
+				Title:    e.Title,
+			})
+		}
+		return nil
+	}
+	if err := collect(*info, 0); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// listSource runs a flat yt-dlp extraction of sourceURL
+func (yd *YouTubeDownloader) listSource(ctx context.Context, sourceURL string, limit int) (*ytdlpEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	args := append(ytdlpBaseArgs(), "--flat-playlist", "-J", "--playlist-end", strconv.Itoa(limit), sourceURL)
+	output, err := yd.ytdlp(ctx, args...)
+	if err != nil {
+		log.Printf("Error listing source %s: %v", sourceURL, err)
+		return nil, fmt.Errorf("failed to read source URL: %v", err)
+	}
+
+	var info ytdlpEntry
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse source listing: %v", err)
+	}
+	return &info, nil
+}
+
+// isPlaylistExtractor reports whether a flat "url" entry is itself a list of videos
+func isPlaylistExtractor(ieKey string) bool {
+	return strings.HasSuffix(ieKey, "Tab") || strings.Contains(ieKey, "Playlist")
+}
+
+// withinDateRange checks a YYYYMMDD upload date against the import options.
+// Unknown dates pass; they are checked again once full metadata is fetched.
+func withinDateRange(opts models.ImportOptions, uploadDate string) bool {
+	if uploadDate == "" {
+		return true
+	}
+	if opts.DateAfter != "" && uploadDate < opts.DateAfter {
+		return false
+	}
+	if opts.DateBefore != "" && uploadDate > opts.DateBefore {
+		return false
+	}
+	return true
+}
+
+// ytdlpUserAgent is sent with every yt-dlp request to bypass bot detection
+const ytdlpUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36"
+
+// runYTDLP runs yt-dlp and returns its standard output
+func runYTDLP(ctx context.Context, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, "yt-dlp", args...).Output()
+}
+
+// ytdlpBaseArgs are shared by every yt-dlp invocation
+func ytdlpBaseArgs() []string {
+	return []string{
+		"--user-agent", ytdlpUserAgent,
 		"--cookies-from-browser", "chrome", // Use cookies from Chrome browser
 		"--no-check-certificate", // Skip certificate validation if needed
-		youtubeURL)
-	
-	output, err := cmd.Output()
+	}
+}
+
+// ytdlpFormat selects the best MP4-friendly streams, capped at maxHeight when set.
+// Sites without MP4 streams fall back to whatever is best; the pipeline transcodes it.
+func ytdlpFormat(maxHeight int) string {
+	h := ""
+	if maxHeight > 0 {
+		h = fmt.Sprintf("[height<=%d]", maxHeight)
+	}
+	return fmt.Sprintf("bestvideo[ext=mp4]%[1]s+bestaudio[ext=m4a]/best[ext=mp4]%[1]s/bestvideo%[1]s+bestaudio/best%[1]s", h)
+}
+
+// handleJob is the JobHandler for remote (yt-dlp and plain HTTP) ingest jobs
+func (yd *YouTubeDownloader) handleJob(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error {
+	if job.Kind == models.JobKindHTTP {
+		return yd.downloadHTTP(ctx, job, progress)
+	}
+	return yd.downloadAndUpload(ctx, job, progress)
+}
+
+// getVideoMetadata extracts metadata from a YouTube video
+func (yd *YouTubeDownloader) getVideoMetadata(ctx context.Context, youtubeURL string) (*VideoMetadata, error) {
+	// Use yt-dlp to extract video metadata in JSON format with user-agent and browser cookies to bypass bot detection
+	args := append([]string{"-j", "--no-playlist"}, ytdlpBaseArgs()...)
+	output, err := yd.ytdlp(ctx, append(args, youtubeURL)...)
 	if err != nil {
 		log.Printf("Error retrieving YouTube metadata: %v", err)
 		return nil, fmt.Errorf("failed to get video metadata: %v", err)
@@ -120,6 +460,23 @@ func (yd *YouTubeDownloader) getVideoMetadata(youtubeURL string) (*VideoMetadata
 		metadata.Duration = duration
 	}
 
+	// Extract upload date
+	if uploadDate, ok := rawMetadata["upload_date"].(string); ok {
+		metadata.UploadDate = uploadDate
+	}
+
+	// Extract the languages subtitles are published in
+	if subtitles, ok := rawMetadata["subtitles"].(map[string]interface{}); ok {
+		for lang := range subtitles {
+			metadata.SubtitleLanguages = append(metadata.SubtitleLanguages, lang)
+		}
+	}
+	if captions, ok := rawMetadata["automatic_captions"].(map[string]interface{}); ok {
+		for lang := range captions {
+			metadata.AutoCaptionLanguages = append(metadata.AutoCaptionLanguages, lang)
+		}
+	}
+
 	// Extract thumbnail URL (prefer high resolution)
 	if thumbnails, ok := rawMetadata["thumbnails"].([]interface{}); ok && len(thumbnails) > 0 {
 		// Try to get the highest quality thumbnail
@@ -140,9 +497,13 @@ func (yd *YouTubeDownloader) getVideoMetadata(youtubeURL string) (*VideoMetadata
 }
 
 // downloadThumbnail downloads a thumbnail from a URL
-func (yd *YouTubeDownloader) downloadThumbnail(thumbnailURL, destPath string) error {
+func (yd *YouTubeDownloader) downloadThumbnail(ctx context.Context, thumbnailURL, destPath string) error {
 	// Create the HTTP request
-	resp, err := http.Get(thumbnailURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, thumbnailURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build thumbnail request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download thumbnail: %v", err)
 	}
@@ -169,31 +530,61 @@ func (yd *YouTubeDownloader) downloadThumbnail(thumbnailURL, destPath string) er
 	return nil
 }
 
-// downloadAndUpload handles the async download and upload process
-func (yd *YouTubeDownloader) downloadAndUpload(videoID string, youtubeURL string, channelID int) {
+// handleSourceDuplicate applies the duplicate policy when a completed video from the same
+// source already exists, e.g. on another channel, before anything is downloaded. done is
+// true when the job's video was linked to it.
+func (yd *YouTubeDownloader) handleSourceDuplicate(job *models.IngestJob) (done bool, err error) {
+	video, err := yd.db.GetVideoByID(job.VideoID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load video: %v", err)
+	}
+	if video.SourceID == "" {
+		return false, nil
+	}
+	existingID, err := yd.db.FindCompletedVideoBySource(video.SourceID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for duplicate video: %v", err)
+	}
+	if existingID == "" {
+		return false, nil
+	}
+	return yd.pipeline.handleDuplicate(job.VideoID, job.ChannelID, existingID, yd.pipeline.DuplicatePolicy(job.Options.Duplicates))
+}
+
+// downloadAndUpload downloads a video and hands it to the ingest pipeline. Errors are returned
+// to the job queue, which decides whether to retry and records the final video status.
+func (yd *YouTubeDownloader) downloadAndUpload(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error {
+	videoID, youtubeURL, channelID := job.VideoID, job.SourceURL, job.ChannelID
+
 	// Update status to downloading
 	if err := yd.db.UpdateVideoStatus(videoID, models.StatusDownloading, ""); err != nil {
-		log.Printf("Error updating video status to downloading: %v", err)
-		return
+		return fmt.Errorf("failed to update video status to downloading: %v", err)
+	}
+	if done, err := yd.handleSourceDuplicate(job); err != nil || done {
+		return err
 	}
 	
 	// Fetch video metadata first
-	metadata, err := yd.getVideoMetadata(youtubeURL)
+	progress.Stage(models.StageMetadata)
+	metadata, err := yd.getVideoMetadata(ctx, youtubeURL)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Failed to get video metadata: %v", err)
-		log.Printf("Error: %s", errorMsg)
-		yd.db.UpdateVideoStatus(videoID, models.StatusFailed, errorMsg)
-		return
+		return fmt.Errorf("failed to get video metadata: %v", err)
+	}
+	progress.Update(models.StageMetadata, 100, 0, 0, 0)
+	if !withinDateRange(job.Options, metadata.UploadDate) {
+		return Permanent(fmt.Errorf("uploaded %s, outside the requested date range", metadata.UploadDate))
 	}
 
-	// Create unique temporary files
+	// Create unique temporary files, removed however this attempt ends
 	tempVideoFile := filepath.Join(yd.tempDir, fmt.Sprintf("%s_%d.mp4", videoID, time.Now().Unix()))
 	tempThumbnailFile := filepath.Join(yd.tempDir, fmt.Sprintf("%s_%d_thumb.jpg", videoID, time.Now().Unix()))
+	defer os.Remove(tempVideoFile)
+	defer os.Remove(tempThumbnailFile)
 	
 	// Download thumbnail if available
 	hasThumbnail := false
 	if metadata.ThumbnailURL != "" {
-		err := yd.downloadThumbnail(metadata.ThumbnailURL, tempThumbnailFile)
+		err := yd.downloadThumbnail(ctx, metadata.ThumbnailURL, tempThumbnailFile)
 		if err != nil {
 			log.Printf("Warning: Failed to download thumbnail: %v", err)
 			// Continue with video download even if thumbnail fails
@@ -203,122 +594,138 @@ func (yd *YouTubeDownloader) downloadAndUpload(videoID string, youtubeURL string
 	}
 	
 	// Download video using yt-dlp and user-agent, outputting to tempVideoFile
-	// Force mp4 format with -f "bestvideo[ext=mp4]+bestaudio[ext=m4a]/mp4" and prevent yt-dlp from adding extension
+	// Prefer mp4 streams (capped at the requested height) and prevent yt-dlp from adding extension
 	// Include cookies from browser to bypass YouTube bot detection
-	cmd := exec.Command("yt-dlp", 
-		"--user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36", 
-		"-f", "bestvideo[ext=mp4]+bestaudio[ext=m4a]/mp4", 
-		"--merge-output-format", "mp4", 
-		"--cookies-from-browser", "chrome", 
-		"--no-check-certificate", 
-		"-o", tempVideoFile, 
-		youtubeURL)
-	output, err := cmd.CombinedOutput()
+	progress.Stage(models.StageDownload)
+	output, err := runYTDLPWithProgress(ctx, append(ytdlpBaseArgs(),
+		"--no-playlist",
+		"-f", ytdlpFormat(job.Options.MaxHeight),
+		"--merge-output-format", "mp4",
+		"-o", tempVideoFile,
+		youtubeURL,
+	), progress)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Download failed: %v - %s", err, string(output))
-		log.Printf("Error downloading video: %s", errorMsg)
-		yd.db.UpdateVideoStatus(videoID, models.StatusFailed, errorMsg)
-		// Clean up temp files
-		os.Remove(tempVideoFile)
-		if hasThumbnail {
-			os.Remove(tempThumbnailFile)
-		}
-		return
+		return fmt.Errorf("download failed: %v - %s", err, string(output))
 	}
 
-	// Update status to processing
-	if err := yd.db.UpdateVideoStatus(videoID, models.StatusProcessing, ""); err != nil {
-		log.Printf("Error updating video status to processing: %v", err)
-		return
+	source := IngestSource{
+		VideoID:     videoID,
+		ChannelID:   channelID,
+		Path:        tempVideoFile,
+		Title:       metadata.Title,
+		Description: metadata.Description,
+		Duration:    metadata.Duration,
+		Duplicates:  job.Options.Duplicates,
 	}
-
-	// Define the S3 keys for video and thumbnail using the video ID
-	videoS3Key := fmt.Sprintf("channel_%d/video_%s.mp4", channelID, videoID)
-	thumbnailS3Key := fmt.Sprintf("thumbnails/thumbnail_%s.jpg", videoID)
-
-	// Upload video to S3
-	if err := yd.uploadToS3(tempVideoFile, videoS3Key, "video/mp4"); err != nil {
-		errorMsg := fmt.Sprintf("Video upload failed: %v", err)
-		log.Printf("Error uploading to S3: %s", errorMsg)
-		yd.db.UpdateVideoStatus(videoID, models.StatusFailed, errorMsg)
-		// Clean up temp files
-		os.Remove(tempVideoFile)
-		if hasThumbnail {
-			os.Remove(tempThumbnailFile)
+	source.Subtitles = yd.downloadSubtitles(ctx, youtubeURL, tempVideoFile, metadata, job.Options)
+	defer func() {
+		for _, file := range source.Subtitles {
+			os.Remove(file.Path)
 		}
-		return
-	}
-	
-	// Upload thumbnail to S3 if available
-	thumbnailURL := ""
+	}()
 	if hasThumbnail {
-		if err := yd.uploadToS3(tempThumbnailFile, thumbnailS3Key, "image/jpeg"); err != nil {
-			log.Printf("Warning: Failed to upload thumbnail: %v", err)
-			// Continue even if thumbnail upload fails
-		} else {
-			thumbnailURL = fmt.Sprintf("/thumbnails/thumbnail_%s.jpg", videoID)
-		}
+		source.ThumbnailPath = tempThumbnailFile
+	}
+	return yd.pipeline.Process(ctx, source, progress)
+}
+
+// downloadSubtitles fetches the source's published subtitles, or its automatic captions
+// where no human ones exist, as WebVTT files next to videoPath. Failures are logged;
+// the video is ingested without them.
+func (yd *YouTubeDownloader) downloadSubtitles(ctx context.Context, sourceURL, videoPath string, metadata *VideoMetadata, opts models.ImportOptions) []SubtitleFile {
+	langs := opts.SubtitleLanguages
+	if langs == "" {
+		langs = yd.subtitleLangs
+	}
+	if langs == "" || langs == "none" || len(metadata.SubtitleLanguages)+len(metadata.AutoCaptionLanguages) == 0 {
+		return nil
 	}
 
-	// Update database with success status and all metadata
-	video, err := yd.db.GetVideoByID(videoID)
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	cmd := exec.CommandContext(ctx, "yt-dlp", append(ytdlpBaseArgs(),
+		"--no-playlist",
+		"--skip-download",
+		"--write-subs",
+		"--write-auto-subs",
+		"--sub-langs", langs,
+		"--sub-format", "vtt/srt/best",
+		"--convert-subs", "vtt",
+		"-o", base+".%(ext)s",
+		sourceURL,
+	)...)
+	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Printf("Error retrieving video from database: %v", err)
-	} else {
-		video.Title = metadata.Title
-		video.Description = metadata.Description
-		video.Duration = metadata.Duration
-		video.S3Key = videoS3Key
-		video.Status = models.StatusCompleted
-		video.ThumbnailURL = thumbnailURL
-		
-		// Save the updated video
-		if err := yd.db.SaveVideo(video); err != nil {
-			log.Printf("Error updating video with metadata: %v", err)
+		log.Printf("Warning: Failed to download subtitles for %s: %v - %s", sourceURL, err, string(output))
+	}
+
+	// yt-dlp names the files <base>.<lang>.vtt; keep whatever it managed to fetch
+	paths, _ := filepath.Glob(base + ".*.vtt")
+	published := make(map[string]bool, len(metadata.SubtitleLanguages))
+	for _, lang := range metadata.SubtitleLanguages {
+		published[lang] = true
+	}
+	var files []SubtitleFile
+	for _, path := range paths {
+		lang := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), filepath.Base(base)+"."), ".vtt")
+		if !ValidLanguage(lang) {
+			os.Remove(path) // e.g. live_chat
+			continue
 		}
-		
-		// Also update the status
-		if err := yd.db.UpdateVideoStatus(videoID, models.StatusCompleted, ""); err != nil {
-			log.Printf("Error updating video status to completed: %v", err)
+		track := models.SubtitleTrack{Language: lang, Kind: models.SubtitleKindSubtitles, Source: models.SubtitleSourceRemote}
+		if !published[lang] {
+			track.Source = models.SubtitleSourceAuto
+			track.Label = lang + " (auto-generated)"
 		}
+		files = append(files, SubtitleFile{Path: path, Track: track})
 	}
+	return files
+}
 
-	// Clean up temp files
-	os.Remove(tempVideoFile)
-	if hasThumbnail {
-		os.Remove(tempThumbnailFile)
+// downloadHTTP fetches a plain HTTP(S) media file and hands it to the ingest pipeline
+func (yd *YouTubeDownloader) downloadHTTP(ctx context.Context, job *models.IngestJob, progress *ProgressReporter) error {
+	if err := yd.db.UpdateVideoStatus(job.VideoID, models.StatusDownloading, ""); err != nil {
+		return fmt.Errorf("failed to update video status to downloading: %v", err)
 	}
-	
-	log.Printf("Successfully processed video %s for channel %d (duration: %.1f seconds)", videoID, channelID, metadata.Duration)
-}
+	if done, err := yd.handleSourceDuplicate(job); err != nil || done {
+		return err
+	}
+	progress.Stage(models.StageDownload)
 
-// uploadToS3 uploads a file to S3
-func (yd *YouTubeDownloader) uploadToS3(filePath, s3Key string, contentType string) error {
-	// Open the file
-	file, err := os.Open(filePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.SourceURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %v", err)
+		return Permanent(fmt.Errorf("invalid source URL: %v", err))
 	}
-	defer file.Close()
-
-	// Get file info for content length
-	fileInfo, err := file.Stat()
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to get file info: %v", err)
+		return fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Client errors will not change on retry; server errors might
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return Permanent(fmt.Errorf("download failed, status: %d", resp.StatusCode))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed, status: %d", resp.StatusCode)
 	}
 
-	// Upload to S3
-	_, err = yd.videoService.s3Client.PutObject(context.Background(), &s3.PutObjectInput{
-		Bucket:        aws.String(yd.videoService.bucket),
-		Key:           aws.String(s3Key),
-		Body:          file,
-		ContentLength: aws.Int64(fileInfo.Size()),
-		ContentType:   aws.String(contentType),
-	})
+	tempFile := filepath.Join(yd.tempDir, fmt.Sprintf("%s_%d.download", job.VideoID, time.Now().Unix()))
+	defer os.Remove(tempFile)
 
+	file, err := os.Create(tempFile)
 	if err != nil {
-		return fmt.Errorf("failed to upload to S3: %v", err)
+		return fmt.Errorf("failed to create download file: %v", err)
+	}
+	_, err = io.Copy(io.MultiWriter(file, newProgressWriter(resp.ContentLength, progress, models.StageDownload)), resp.Body)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("download failed: %v", err)
 	}
 
-	return nil
+	return yd.pipeline.Process(ctx, IngestSource{
+		VideoID:    job.VideoID,
+		ChannelID:  job.ChannelID,
+		Path:       tempFile,
+		Duplicates: job.Options.Duplicates,
+	}, progress)
 }
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+)
+
+// playlist is a flat yt-dlp listing of videos a, b and a again, uploaded in 2023 and 2024
+const playlist = `{"_type": "playlist", "entries": [
+	{"id": "a", "title": "A", "ie_key": "Youtube", "url": "https://www.youtube.com/watch?v=a", "upload_date": "20230101"},
+	{"id": "b", "title": "B", "ie_key": "Youtube", "url": "https://www.youtube.com/watch?v=b", "upload_date": "20240101"},
+	{"id": "a", "title": "A", "ie_key": "Youtube", "url": "https://www.youtube.com/watch?v=a", "upload_date": "20230101"}
+]}`
+
+// newTestDownloader returns a downloader on an in-memory store with the predefined
+// channels, and the URL of a local site whose pages are not media files. yt-dlp
+// answers with listings, by the URL it is asked to list.
+func newTestDownloader(t *testing.T, listings func(site string) map[string]string) (*YouTubeDownloader, *repository.Memory, string) {
+	t.Helper()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	t.Cleanup(site.Close)
+
+	db := repository.NewMemory()
+	if err := db.CreateDefaultChannels(); err != nil {
+		t.Fatal(err)
+	}
+	queue := NewJobQueue(db, db, nil, 1, 3)
+	pipeline, err := NewIngestPipeline(nil, db, db, db, nil, nil, t.TempDir(), models.DuplicateLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yd, err := NewYouTubeDownloader(db, queue, pipeline, t.TempDir(), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	known := listings(site.URL)
+	yd.ytdlp = func(ctx context.Context, args ...string) ([]byte, error) {
+		listing, ok := known[args[len(args)-1]]
+		if !ok {
+			return nil, fmt.Errorf("yt-dlp run for %v", args)
+		}
+		return []byte(listing), nil
+	}
+	return yd, db, site.URL
+}
+
+func TestImportRejectsInvalidURL(t *testing.T) {
+	yd, db, _ := newTestDownloader(t, func(string) map[string]string { return nil })
+	for _, sourceURL := range []string{"ftp://example.com/clip.mp4", "example.com/clip.mp4", "https://", "::"} {
+		if _, err := yd.Import(sourceURL, 1, "editor", models.ImportOptions{}); !errors.Is(err, ErrInvalidSourceURL) {
+			t.Errorf("Import(%q) = %v, want %v", sourceURL, err, ErrInvalidSourceURL)
+		}
+	}
+	jobs, err := db.ListJobs("", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("queued %d jobs for invalid URLs", len(jobs))
+	}
+}
+
+func TestQueueSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string // of the source URL on the test site
+		existing    *models.AdminVideo
+		opts        models.ImportOptions
+		wantQueued  []string // source IDs
+		wantSkipped []string // reasons
+		wantKind    models.JobKind
+	}{
+		{
+			name:       "playlist",
+			path:       "/playlist",
+			wantQueued: []string{"youtube:a", "youtube:b"},
+			wantKind:   models.JobKindYouTube,
+		},
+		{
+			name:       "channel tabs",
+			path:       "/channel",
+			wantQueued: []string{"youtube:a", "youtube:b"},
+			wantKind:   models.JobKindYouTube,
+		},
+		{
+			name:       "limit",
+			path:       "/playlist",
+			opts:       models.ImportOptions{Limit: 1},
+			wantQueued: []string{"youtube:a"},
+			wantKind:   models.JobKindYouTube,
+		},
+		{
+			name:       "date range",
+			path:       "/playlist",
+			opts:       models.ImportOptions{DateAfter: "20231231"},
+			wantQueued: []string{"youtube:b"},
+			wantKind:   models.JobKindYouTube,
+		},
+		{
+			name:        "already on the channel",
+			path:        "/playlist",
+			existing:    &models.AdminVideo{ChannelID: 1, SourceID: "youtube:a", Status: models.StatusPending},
+			wantQueued:  []string{"youtube:b"},
+			wantSkipped: []string{"already imported"},
+			wantKind:    models.JobKindYouTube,
+		},
+		{
+			name:       "on another channel, linked",
+			path:       "/playlist",
+			existing:   &models.AdminVideo{ChannelID: 2, SourceID: "youtube:a", Status: models.StatusCompleted},
+			wantQueued: []string{"youtube:a", "youtube:b"},
+			wantKind:   models.JobKindYouTube,
+		},
+		{
+			name:        "on another channel, rejected",
+			path:        "/playlist",
+			existing:    &models.AdminVideo{ChannelID: 2, SourceID: "youtube:a", Status: models.StatusCompleted},
+			opts:        models.ImportOptions{Duplicates: models.DuplicateReject},
+			wantQueued:  []string{"youtube:b"},
+			wantSkipped: []string{"already imported on another channel"},
+			wantKind:    models.JobKindYouTube,
+		},
+		{
+			name:     "media file",
+			path:     "/clip.mp4",
+			wantKind: models.JobKindHTTP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yd, db, site := newTestDownloader(t, func(site string) map[string]string {
+				return map[string]string{
+					site + "/playlist":       playlist,
+					site + "/channel":        fmt.Sprintf(`{"_type": "playlist", "entries": [{"_type": "url", "ie_key": "YoutubeTab", "url": "%s/channel/videos"}]}`, site),
+					site + "/channel/videos": playlist,
+				}
+			})
+			if tt.existing != nil {
+				if err := db.SaveVideo(tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.wantKind == models.JobKindHTTP {
+				tt.wantQueued = []string{"http:" + site + tt.path}
+			}
+
+			result, err := yd.queueSource(context.Background(), site+tt.path, 1, "editor", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var queued []string
+			for _, id := range result.VideoIDs {
+				video, err := db.GetVideoByID(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if video.ChannelID != 1 || video.Status != models.StatusPending {
+					t.Errorf("video %s is on channel %d, %s", video.SourceID, video.ChannelID, video.Status)
+				}
+				queued = append(queued, video.SourceID)
+			}
+			sort.Strings(queued)
+			if !reflect.DeepEqual(queued, tt.wantQueued) {
+				t.Errorf("queued %v, want %v", queued, tt.wantQueued)
+			}
+			var skipped []string
+			for _, entry := range result.Skipped {
+				skipped = append(skipped, entry.Reason)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("skipped %v, want %v", skipped, tt.wantSkipped)
+			}
+
+			jobs, err := db.ListJobs(models.JobQueued, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(jobs) != len(tt.wantQueued) {
+				t.Errorf("%d jobs queued, want %d", len(jobs), len(tt.wantQueued))
+			}
+			for _, job := range jobs {
+				if job.Kind != tt.wantKind || job.Options != tt.opts {
+					t.Errorf("queued a %s job with %+v, want %s with %+v", job.Kind, job.Options, tt.wantKind, tt.opts)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		listing    string
+		wantJob    models.JobStatus
+		wantQueued int
+	}{
+		{name: "videos found", listing: playlist, wantJob: models.JobCompleted, wantQueued: 2},
+		{name: "nothing found", listing: `{"_type": "playlist", "entries": []}`, wantJob: models.JobFailed},
+		{name: "unreadable listing", listing: "not json", wantJob: models.JobQueued},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yd, db, site := newTestDownloader(t, func(site string) map[string]string {
+				return map[string]string{site + "/playlist": tt.listing}
+			})
+			job, err := yd.Import(site+"/playlist", 1, "editor", models.ImportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claimed, err := db.ClaimNextJob()
+			if err != nil || claimed == nil || claimed.ID != job.ID {
+				t.Fatalf("claim: %v, %v", claimed, err)
+			}
+			yd.queue.run(1, claimed)
+
+			stored, err := db.GetJob(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantJob {
+				t.Errorf("expand job is %s, want %s", stored.Status, tt.wantJob)
+			}
+			downloads, err := db.ListJobs(models.JobQueued, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			queued := 0
+			for _, download := range downloads {
+				if download.Kind == models.JobKindYouTube {
+					queued++
+				}
+			}
+			if queued != tt.wantQueued {
+				t.Errorf("queued %d downloads, want %d", queued, tt.wantQueued)
+			}
+		})
+	}
+}
@@ -1,11 +1,13 @@
 package state
 
 import (
+	"context"
 	"fmt"
+	"live-broadcast-backend/keys"
 	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
 	"live-broadcast-backend/services"
 	"log"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -19,12 +21,6 @@ type VideoProvider interface {
 	DeleteVideo(s3Key string) error
 }
 
-type DBProvider interface {
-	GetAllChannels() ([]*models.Channel, error)
-	GetChannel(int) (*models.Channel, error)
-	GetChannelVideos(int) ([]*models.Video, error)
-}
-
 /* ---------- ChannelManager ---------- */
 
 type ChannelManager struct {
@@ -33,12 +29,23 @@ type ChannelManager struct {
 	videos             map[string]*models.Video
 	validS3Keys        []string
 	videoProvider      VideoProvider
-	dbProvider         DBProvider
+	dbProvider         repository.ChannelRepository
 	channelVideoMap    map[int][]*models.Video
 	prefetchThreshold  float64
 	nextVideoByChannel map[int]*models.Video
 	broadcasters       map[int]*services.Broadcaster // NEW
 	initialized        bool
+
+	previewMu       sync.RWMutex
+	previews        map[int]*ChannelPreview
+	previewInterval time.Duration
+}
+
+// ChannelPreview is a still of what a channel was airing when it was captured
+type ChannelPreview struct {
+	Image      []byte // JPEG
+	CapturedAt time.Time
+	VideoID    string
 }
 
 /* ---------- constructor ---------- */
@@ -52,6 +59,7 @@ func NewChannelManager() *ChannelManager {
 		prefetchThreshold:  0.80,
 		nextVideoByChannel: map[int]*models.Video{},
 		broadcasters:       map[int]*services.Broadcaster{},
+		previews:           map[int]*ChannelPreview{},
 	}
 	go cm.videoScheduler()
 	return cm
@@ -60,14 +68,14 @@ func NewChannelManager() *ChannelManager {
 /* ---------- public setters ---------- */
 
 func (cm *ChannelManager) SetVideoProvider(p VideoProvider) { cm.mu.Lock(); cm.videoProvider = p; cm.mu.Unlock() }
-func (cm *ChannelManager) SetDBProvider(p DBProvider)      { cm.mu.Lock(); cm.dbProvider = p; cm.mu.Unlock() }
+func (cm *ChannelManager) SetDBProvider(p repository.ChannelRepository) { cm.mu.Lock(); cm.dbProvider = p; cm.mu.Unlock() }
 
 /* ---------- helper: ensure a local copy and return its path ---------- */
 func (cm *ChannelManager) getLocalPath(s3Key string) (string, error) {
 	// make sure we have the file locally first
 	var local string
 	if cm.videoProvider.IsVideoDownloaded(s3Key) {
-		local = filepath.Join("./videos", s3Key)
+		local = keys.LocalCopy("./videos", s3Key)
 	} else {
 		var err error
 		local, err = cm.videoProvider.DownloadVideo(s3Key)
@@ -109,6 +117,26 @@ func (cm *ChannelManager) InitializeWithS3Content(channels []*models.Channel, vi
 		}
 	}
 
+	// Playlists kept in the database win over channel folders: library assets live
+	// outside them and may be scheduled on several channels
+	if cm.dbProvider != nil {
+		for _, ch := range channels {
+			playlist, err := cm.dbProvider.GetChannelVideos(ch.Number)
+			if err != nil {
+				log.Printf("channel %d: cannot load playlist, using S3 folder: %v", ch.Number, err)
+				continue
+			}
+			if len(playlist) == 0 {
+				continue
+			}
+			cm.channelVideoMap[ch.Number] = playlist
+			for _, v := range playlist {
+				cm.videos[v.ID] = v
+				cm.validS3Keys = append(cm.validS3Keys, v.S3Key)
+			}
+		}
+	}
+
 	for _, ch := range channels {
 		videoList := cm.channelVideoMap[ch.Number]
 		var first *models.Video
@@ -294,6 +322,82 @@ func (cm *ChannelManager) pickNextVideo(ch int, current *models.Video) *models.V
 	return list[0]
 }
 
+/* ---------- live preview stills ---------- */
+
+// StartPreviewCapture captures a still from every channel's latest keyframe fragment
+// each interval
+func (cm *ChannelManager) StartPreviewCapture(interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("Live channel previews disabled")
+		return
+	}
+	cm.previewMu.Lock()
+	cm.previewInterval = interval
+	cm.previewMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			cm.capturePreviews()
+			<-ticker.C
+		}
+	}()
+}
+
+func (cm *ChannelManager) capturePreviews() {
+	cm.mu.RLock()
+	broadcasters := make(map[int]*services.Broadcaster, len(cm.broadcasters))
+	for chNum, bc := range cm.broadcasters {
+		broadcasters[chNum] = bc
+	}
+	cm.mu.RUnlock()
+
+	for chNum, bc := range broadcasters {
+		init, frag, at := bc.LatestKeyframe()
+		if frag == nil {
+			continue
+		}
+
+		cm.previewMu.RLock()
+		prev := cm.previews[chNum]
+		cm.previewMu.RUnlock()
+		if prev != nil && !at.After(prev.CapturedAt) {
+			continue // nothing new has aired
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		image, err := services.CaptureLivePreview(ctx, init, frag)
+		cancel()
+		if err != nil {
+			log.Printf("channel %d: preview capture failed: %v", chNum, err)
+			continue
+		}
+
+		videoID := ""
+		if st, err := cm.GetChannelState(chNum); err == nil && st.CurrentVideo != nil {
+			videoID = st.CurrentVideo.ID
+		}
+		cm.previewMu.Lock()
+		cm.previews[chNum] = &ChannelPreview{Image: image, CapturedAt: at, VideoID: videoID}
+		cm.previewMu.Unlock()
+	}
+}
+
+// GetChannelPreview returns the latest still captured for a channel, or nil
+func (cm *ChannelManager) GetChannelPreview(num int) *ChannelPreview {
+	cm.previewMu.RLock()
+	defer cm.previewMu.RUnlock()
+	return cm.previews[num]
+}
+
+// PreviewInterval is how often channel previews are refreshed
+func (cm *ChannelManager) PreviewInterval() time.Duration {
+	cm.previewMu.RLock()
+	defer cm.previewMu.RUnlock()
+	return cm.previewInterval
+}
+
 /* ---------- accessors used by handlers ---------- */
 
 func (cm *ChannelManager) GetChannelState(num int) (*models.ChannelState, error) {
@@ -340,6 +444,11 @@ func (cm *ChannelManager) GetAllChannelGuideInfo() interface{} {
 		if next := cm.nextVideoByChannel[chNum]; next != nil {
 			channelInfo["nextVideo"] = next
 		}
+
+		// Live still; the capture time in the URL busts caches when it changes
+		if preview := cm.GetChannelPreview(chNum); preview != nil {
+			channelInfo["previewUrl"] = fmt.Sprintf("/api/channels/%d/preview.jpg?t=%d", chNum, preview.CapturedAt.Unix())
+		}
 		
 		guideInfo[chNum] = channelInfo
 	}
@@ -0,0 +1,107 @@
+package state
+
+import (
+	"testing"
+
+	"live-broadcast-backend/models"
+	"live-broadcast-backend/repository"
+)
+
+// newTestManager returns a channel manager reading playlists from an in-memory store
+// holding the given completed videos, by title, on each channel in playlist order
+func newTestManager(t *testing.T, playlists map[int][]string) *ChannelManager {
+	t.Helper()
+	db := repository.NewMemory()
+	if err := db.CreateDefaultChannels(); err != nil {
+		t.Fatal(err)
+	}
+	for channel, titles := range playlists {
+		for i, title := range titles {
+			video := &models.AdminVideo{
+				ID:           title,
+				ChannelID:    channel,
+				Title:        title,
+				S3Key:        title + ".mp4",
+				Status:       models.StatusCompleted,
+				Duration:     60,
+				DisplayOrder: i + 1,
+			}
+			if err := db.SaveVideo(video); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cm := NewChannelManager()
+	cm.SetDBProvider(db)
+	if err := cm.InitializeFromDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
+
+func TestInitializeFromDatabase(t *testing.T) {
+	cm := newTestManager(t, map[int][]string{
+		1: {"news-a", "news-b", "news-c"},
+		2: {"sports-a"},
+	})
+
+	tests := []struct {
+		channel     int
+		wantCurrent string // empty when the channel should have no state
+		wantNext    string
+	}{
+		{channel: 1, wantCurrent: "news-a", wantNext: "news-b"},
+		{channel: 2, wantCurrent: "sports-a"},
+		{channel: 3},
+	}
+	for _, tt := range tests {
+		st, err := cm.GetChannelState(tt.channel)
+		if tt.wantCurrent == "" {
+			if err == nil {
+				t.Errorf("channel %d: got state without videos", tt.channel)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("channel %d: %v", tt.channel, err)
+		}
+		if st.CurrentVideo.ID != tt.wantCurrent {
+			t.Errorf("channel %d: current = %s, want %s", tt.channel, st.CurrentVideo.ID, tt.wantCurrent)
+		}
+		next := ""
+		if v := cm.nextVideoByChannel[tt.channel]; v != nil {
+			next = v.ID
+		}
+		if next != tt.wantNext {
+			t.Errorf("channel %d: next = %q, want %q", tt.channel, next, tt.wantNext)
+		}
+	}
+}
+
+func TestPickNextVideo(t *testing.T) {
+	cm := newTestManager(t, map[int][]string{
+		1: {"news-a", "news-b", "news-c"},
+		2: {"sports-a"},
+	})
+
+	tests := []struct {
+		name    string
+		channel int
+		current string
+		want    string
+	}{
+		{name: "middle of playlist", channel: 1, current: "news-a", want: "news-b"},
+		{name: "wraps around", channel: 1, current: "news-c", want: "news-a"},
+		{name: "current no longer listed", channel: 1, current: "deleted", want: "news-a"},
+		{name: "single video repeats", channel: 2, current: "sports-a", want: "sports-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cm.pickNextVideo(tt.channel, &models.Video{ID: tt.current})
+			if got == nil || got.ID != tt.want {
+				t.Errorf("next = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import FloatingMenu from "./components/FloatingMenu";
 import ChannelGuide from "./components/ChannelGuide";
 import AdminDashboard from "./components/AdminDashboard";
 import UnmuteButton from "./components/UnmuteButton";
+import SubtitleOverlay from "./components/SubtitleOverlay";
 import ConnectionStatus from "./components/ConnectionStatus";
 import LoginForm from "./components/LoginForm";
 import useWebSocket from "./hooks/useWebSocket";
@@ -30,6 +31,11 @@ const App = () => {
     joinChannel,
     requestChannelGuide,
     lastMessage,
+    subtitleLanguage,
+    subtitleCues,
+    setSubtitles,
+    audioLanguages,
+    setAudioLanguage,
   } = useWebSocket();
 
   // Get current video info for the selected channel
@@ -48,6 +54,33 @@ const App = () => {
     setShowGuide(false);
   };
 
+  // Step through the current video's subtitle languages, then off
+  const cycleSubtitles = () => {
+    const languages = [
+      "",
+      ...(currentVideoInfo.subtitles || []).map((track) => track.language),
+    ];
+    const next = (languages.indexOf(subtitleLanguage) + 1) % languages.length;
+    setSubtitles(languages[next]);
+  };
+
+  // Step through the current video's audio languages, then back to the default
+  const audioLanguage = audioLanguages[currentChannel] || "";
+  const audioLanguageChoices = [
+    "",
+    ...new Set(
+      (currentVideoInfo.audioTracks || [])
+        .map((track) => track.language)
+        .filter(Boolean)
+    ),
+  ];
+  const cycleAudioLanguage = () => {
+    const next =
+      (audioLanguageChoices.indexOf(audioLanguage) + 1) %
+      audioLanguageChoices.length;
+    setAudioLanguage(currentChannel, audioLanguageChoices[next]);
+  };
+
   // Handle volume change
   const changeVolume = (newVolume) => {
     setVolume(Math.max(0, Math.min(100, newVolume)));
@@ -193,8 +226,18 @@ const App = () => {
         muted={muted}
         volume={volume / 100} // Convert to 0-1 range for HTML5 video
         channelNumber={currentChannel}
+        audioTrack={currentVideoInfo.audioTrack || 0}
       />
 
+      {/* Subtitles */}
+      {subtitleLanguage && (
+        <SubtitleOverlay
+          cues={subtitleCues}
+          channel={currentChannel}
+          videoInfo={currentVideoInfo}
+        />
+      )}
+
       {/* Floating Menu (Bottom Right) */}
       <FloatingMenu
         currentChannel={currentChannel}
@@ -206,6 +249,12 @@ const App = () => {
         toggleGuide={toggleGuide}
         toggleAdmin={toggleAdmin}
         isAdmin={isAdmin}
+        subtitleLanguage={subtitleLanguage}
+        hasSubtitles={(currentVideoInfo.subtitles || []).length > 0}
+        cycleSubtitles={cycleSubtitles}
+        audioLanguage={audioLanguage}
+        hasAudioLanguages={audioLanguageChoices.length > 1}
+        cycleAudioLanguage={cycleAudioLanguage}
       />
 
       {/* Unmute Button (Bottom Left) */}
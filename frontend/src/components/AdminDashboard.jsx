@@ -19,6 +19,14 @@ const AdminDashboard = ({ closeAdmin, isOpen }) => {
   const [isEditingChannel, setIsEditingChannel] = useState(false);
   const [editedChannelDetails, setEditedChannelDetails] = useState(null);
   const [isSavingChannel, setIsSavingChannel] = useState(false);
+  const [previewStamp, setPreviewStamp] = useState(Date.now());
+
+  // Refresh the on-air still while the manage tab is open
+  useEffect(() => {
+    if (activeTab !== "manage") return;
+    const timer = setInterval(() => setPreviewStamp(Date.now()), 30000);
+    return () => clearInterval(timer);
+  }, [activeTab]);
 
   // Fetch channel videos when selected channel changes
   useEffect(() => {
@@ -183,10 +191,16 @@ const AdminDashboard = ({ closeAdmin, isOpen }) => {
         }
         throw new Error("Failed to delete video");
       }
+      const data = await response.json();
 
       // Refresh the video list
       await fetchChannelVideos(selectedChannel);
-      toast.success("Video deleted successfully");
+      // success is false when some of the video's files could not be deleted
+      if (data.success) {
+        toast.success(data.message);
+      } else {
+        toast.error(data.message);
+      }
     } catch (error) {
       console.error("Error deleting video:", error);
       toast.error(`Failed to delete video: ${error.message}`);
@@ -400,6 +414,14 @@ const AdminDashboard = ({ closeAdmin, isOpen }) => {
                   </select>
                 </div>
 
+                <img
+                  key={`${selectedChannel}-${previewStamp}`}
+                  src={`/api/channels/${selectedChannel}/preview.jpg?t=${previewStamp}`}
+                  alt={`On air on channel ${selectedChannel}`}
+                  className="h-16 aspect-video object-cover rounded bg-gray-700"
+                  onError={(e) => { e.target.style.visibility = "hidden"; }}
+                />
+
                 <button
                   onClick={handleCreateNewChannel}
                   className="py-2 px-4 rounded-md text-white font-medium bg-blue-600 hover:bg-blue-700"
@@ -70,6 +70,14 @@ const ChannelGuide = ({ channelInfo, currentChannel, changeChannel, closeGuide }
               <div className="flex-shrink-0 w-10 h-10 bg-gray-800 rounded-full flex items-center justify-center mr-3">
                 {channel.number}
               </div>
+              {channel.previewUrl && (
+                <img
+                  src={channel.previewUrl}
+                  alt={`Now on channel ${channel.number}`}
+                  className="flex-shrink-0 w-24 aspect-video object-cover rounded mr-3 bg-gray-800"
+                  onError={(e) => { e.target.style.display = 'none'; }}
+                />
+              )}
               <div className="flex-grow">
                 <h3 className="font-medium">{channel.name}</h3>
                 <p className="text-sm text-gray-400">
@@ -11,6 +11,12 @@ const FloatingMenu = ({
   toggleGuide,
   toggleAdmin,
   isAdmin = false,
+  subtitleLanguage = "",
+  hasSubtitles = false,
+  cycleSubtitles,
+  audioLanguage = "",
+  hasAudioLanguages = false,
+  cycleAudioLanguage,
 }) => {
   const [isOpen, setIsOpen] = useState(false);
   const [isVisible, setIsVisible] = useState(true);
@@ -377,6 +383,42 @@ const FloatingMenu = ({
                 </motion.button>
               )}
 
+              {/* Subtitles Button */}
+              <motion.button
+                whileTap={{ scale: 0.95 }}
+                onClick={cycleSubtitles}
+                className="col-span-2 flex items-center justify-center bg-gray-700 bg-opacity-90 text-white 
+                         rounded-md py-2 px-2 shadow hover:bg-gray-600 transition"
+                disabled={!hasSubtitles && !subtitleLanguage}
+              >
+                <span className="text-xs font-bold border border-white rounded px-1 mr-2">
+                  CC
+                </span>
+                <span className="text-xs">
+                  {subtitleLanguage
+                    ? `Subtitles: ${subtitleLanguage}`
+                    : "Subtitles off"}
+                </span>
+              </motion.button>
+
+              {/* Audio Language Button */}
+              <motion.button
+                whileTap={{ scale: 0.95 }}
+                onClick={cycleAudioLanguage}
+                className="col-span-2 flex items-center justify-center bg-gray-700 bg-opacity-90 text-white 
+                         rounded-md py-2 px-2 shadow hover:bg-gray-600 transition"
+                disabled={!hasAudioLanguages && !audioLanguage}
+              >
+                <span className="text-xs font-bold border border-white rounded px-1 mr-2">
+                  AUD
+                </span>
+                <span className="text-xs">
+                  {audioLanguage
+                    ? `Audio: ${audioLanguage}`
+                    : "Audio: default"}
+                </span>
+              </motion.button>
+
               {/* Channel Info */}
               <div className="col-span-2 mt-2 border-t border-gray-600 pt-2 text-center text-white text-sm">
                 <span className="font-bold">Channel {currentChannel}</span>
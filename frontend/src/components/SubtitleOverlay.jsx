@@ -0,0 +1,52 @@
+import React, { useEffect, useState } from "react";
+
+/**
+ * Shows the subtitle cues on air for the watched channel. Cue times are positions in
+ * the programme, so they are matched against the channel clock from the last
+ * videoUpdate rather than this device's clock.
+ *
+ * Props
+ * • cues       – cues from useWebSocket ({ channel, videoId, start, end, text })
+ * • channel    – channel being watched
+ * • videoInfo  – channelData entry of that channel
+ */
+const SubtitleOverlay = ({ cues, channel, videoInfo }) => {
+  const [now, setNow] = useState(Date.now());
+
+  useEffect(() => {
+    if (!cues.length) return;
+    const timer = setInterval(() => setNow(Date.now()), 100);
+    return () => clearInterval(timer);
+  }, [cues.length]);
+
+  if (!videoInfo?.lastServerSync) return null;
+
+  // Programme position the channel has reached by now
+  const position =
+    (videoInfo.lastServerTime || 0) + (now - videoInfo.lastServerSync) / 1000;
+
+  const onAir = cues.filter(
+    (cue) =>
+      cue.channel === channel &&
+      cue.videoId === videoInfo.videoId &&
+      cue.start <= position &&
+      position < cue.end
+  );
+  if (!onAir.length) return null;
+
+  return (
+    <div className="absolute bottom-16 left-0 right-0 flex flex-col items-center pointer-events-none z-10">
+      {onAir.map((cue) => (
+        <div
+          key={`${cue.start}-${cue.text}`}
+          className="bg-black/75 text-white text-xl px-3 py-1 mt-1 rounded text-center whitespace-pre-line max-w-[80%]"
+        >
+          {/* WebVTT markup such as <i> or <c.yellow> is shown as plain text */}
+          {cue.text.replace(/<[^>]+>/g, "")}
+        </div>
+      ))}
+    </div>
+  );
+};
+
+export default SubtitleOverlay;
@@ -9,11 +9,13 @@ const MAX_BUFFER_SECONDS = 30;
  * Props
  * ───────────────────────────────────────────────────────────
  * • channelNumber   – backend channel id (required)
+ * • audioTrack      – index of the audio track to play (default 0)
  * • autoPlay        – start automatically (default true)
  * • className       – extra css classes for the outer container
  */
 export default function VideoPlayer({
   channelNumber,
+  audioTrack = 0,
   autoPlay = true,
   className = "",
 }) {
@@ -110,7 +112,15 @@ export default function VideoPlayer({
     mp4.onReady = (info) => {
       if (sessionRef.current !== token || ms.readyState !== "open") return;
 
-      info.tracks.forEach((t) => {
+      /* MSE plays one audio SourceBuffer, so only the chosen audio track is
+         * buffered; the first one stands in when the choice is out of range */
+      const audio = info.tracks.filter((t) => t.type === "audio");
+      const chosen = audio[audioTrack] || audio[0];
+      const tracks = info.tracks.filter(
+        (t) => t.type !== "audio" || t === chosen
+      );
+
+      tracks.forEach((t) => {
         const mime = `${t.type === "audio" ? "audio" : "video"}/mp4; codecs="${
           t.codec
         }"`;
@@ -209,7 +219,7 @@ export default function VideoPlayer({
       log("cleanup complete");
     };
     // eslint-disable-next-line react-hooks/exhaustive-deps
-  }, [channelNumber]);
+  }, [channelNumber, audioTrack]);
 
   /* ───────── render ───────── */
   return (
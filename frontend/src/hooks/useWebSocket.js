@@ -7,6 +7,11 @@ const useWebSocket = (path = "ws") => {
   const [channelData, setChannelData] = useState({});
   const [channelGuide, setChannelGuide] = useState([]);
   const [currentChannel, setCurrentChannel] = useState(1);
+  const [subtitleLanguage, setSubtitleLanguage] = useState(""); // "" = subtitles off
+  const [subtitleCues, setSubtitleCues] = useState([]);
+  const subtitleLanguageRef = useRef("");
+  const [audioLanguages, setAudioLanguages] = useState({}); // by channel; missing = channel default
+  const audioLanguagesRef = useRef({});
   const socketRef = useRef(null);
   const reconnectTimeoutRef = useRef(null);
   const sendMessageRef = useRef(null);
@@ -15,7 +20,17 @@ const useWebSocket = (path = "ws") => {
 
   // Handle video update messages from the server
   const handleVideoUpdate = useCallback((message) => {
-    const { channel, url, currentTime, duration, videoId, title } = message;
+    const {
+      channel,
+      url,
+      currentTime,
+      duration,
+      videoId,
+      title,
+      subtitles,
+      audioTracks,
+      audioTrack,
+    } = message;
 
     // If we get an empty URL, preserve the previous URL if available
     // This prevents flickering when channel state has temporary issues
@@ -50,6 +65,9 @@ const useWebSocket = (path = "ws") => {
           title: title || prevChannelData.title || "",
           currentTime: url ? currentTime : prevChannelData.currentTime || 0,
           duration: duration || prevChannelData.duration || 0,
+          subtitles: subtitles || [],
+          audioTracks: audioTracks || [],
+          audioTrack: audioTrack || 0,
           lastUpdated: now,
           lastServerSync: now,
           lastServerTime: currentTime || 0,
@@ -67,6 +85,21 @@ const useWebSocket = (path = "ws") => {
     }
   }, []);
 
+  // Handle subtitle cues, sent shortly before the channel clock reaches them
+  const handleSubtitleCue = useCallback((message) => {
+    const { channel, videoId, language, data } = message;
+    if (!data || language !== subtitleLanguageRef.current) return;
+
+    setSubtitleCues((prevCues) => {
+      // Drop cues that went off air a while ago
+      const cutoff = Date.now() - 5000;
+      return [
+        ...prevCues.filter((cue) => Date.parse(cue.hideAt) > cutoff),
+        { ...data, channel, videoId },
+      ];
+    });
+  }, []);
+
   // Send a message to the WebSocket server
   const sendMessage = useCallback((message) => {
     if (socketRef.current && socketRef.current.readyState === WebSocket.OPEN) {
@@ -98,6 +131,43 @@ const useWebSocket = (path = "ws") => {
     [sendMessage]
   );
 
+  // Choose the subtitle language to receive cues in; "" turns subtitles off
+  const setSubtitles = useCallback(
+    (language) => {
+      subtitleLanguageRef.current = language;
+      setSubtitleLanguage(language);
+      setSubtitleCues([]);
+
+      return sendMessage({
+        type: "setSubtitles",
+        language,
+      });
+    },
+    [sendMessage]
+  );
+
+  // Choose the audio language for a channel; "" returns to the channel default.
+  // The server answers with a videoUpdate naming the track to play
+  const setAudioLanguage = useCallback(
+    (channel, language) => {
+      const next = { ...audioLanguagesRef.current };
+      if (language) {
+        next[channel] = language;
+      } else {
+        delete next[channel];
+      }
+      audioLanguagesRef.current = next;
+      setAudioLanguages(next);
+
+      return sendMessage({
+        type: "setAudioLanguage",
+        channel,
+        language,
+      });
+    },
+    [sendMessage]
+  );
+
   // Request the channel guide from the server
   const requestChannelGuide = useCallback(() => {
     return sendMessage({
@@ -176,6 +246,25 @@ const useWebSocket = (path = "ws") => {
               type: "joinChannel",
               channel: currentChannel,
             });
+
+            // The server forgets the subtitle language when a connection drops
+            if (subtitleLanguageRef.current) {
+              sendMessage({
+                type: "setSubtitles",
+                language: subtitleLanguageRef.current,
+              });
+            }
+
+            // ...and the audio language chosen for each channel
+            Object.entries(audioLanguagesRef.current).forEach(
+              ([channel, language]) => {
+                sendMessage({
+                  type: "setAudioLanguage",
+                  channel: Number(channel),
+                  language,
+                });
+              }
+            );
           }
         }, 500);
       };
@@ -222,6 +311,9 @@ const useWebSocket = (path = "ws") => {
                 case "channelGuide":
                   handleChannelGuide(parsed);
                   break;
+                case "subtitleCue":
+                  handleSubtitleCue(parsed);
+                  break;
                 default:
                   console.log("Received unknown message type:", parsed.type);
               }
@@ -310,6 +402,11 @@ const useWebSocket = (path = "ws") => {
     sendMessage, // General-purpose message sending function
     joinChannel, // Function to join a specific channel
     requestChannelGuide, // Function to request the channel guide
+    subtitleLanguage, // Language subtitles are shown in; "" when off
+    subtitleCues, // Upcoming and current subtitle cues
+    setSubtitles, // Function to choose the subtitle language
+    audioLanguages, // Audio language chosen per channel
+    setAudioLanguage, // Function to choose a channel's audio language
   };
 };
 